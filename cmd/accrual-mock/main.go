@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/lexizz/cumloys/internal/pkg/accrualmock"
+	pkgLogger "github.com/lexizz/cumloys/internal/pkg/logger"
+)
+
+// seed - orders and reward rules which are registered at the start.
+type seed struct {
+	Orders []accrualmock.Order      `json:"orders"`
+	Goods  []accrualmock.RewardRule `json:"goods"`
+}
+
+func main() {
+	logger := pkgLogger.Init()
+
+	address := pflag.StringP("address", "a", ":8081", "address for listening via accrual mock")
+	seedFile := pflag.String("seed-file", "", "JSON file with orders and goods (reward rules) to register at the start")
+	rateLimit := pflag.Int("rate-limit", 0, "requests per minute, the rest get 429 Too Many Requests; 0 - no limit")
+	retryAfter := pflag.Int("retry-after", 0, "seconds in Retry-After; 0 - until the end of the current minute")
+	latency := pflag.Duration("latency", 0, "delay before every response")
+	errorRate := pflag.Float64("error-rate", 0, "probability from 0 to 1 of 500 Internal Server Error")
+
+	pflag.Parse()
+
+	mock := accrualmock.New(accrualmock.Behaviour{
+		RateLimit:  *rateLimit,
+		RetryAfter: *retryAfter,
+		LatencyMs:  int(*latency / time.Millisecond),
+		ErrorRate:  *errorRate,
+	}, logger)
+
+	if *seedFile != "" {
+		errSeed := loadSeed(mock, *seedFile)
+		if errSeed != nil {
+			logger.Errorf("---> ERROR: accrual mock: failed load seed: %v\n", errSeed)
+			os.Exit(1)
+		}
+	}
+
+	server := &http.Server{
+		Addr:              *address,
+		Handler:           mock,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	logger.Infof("=== Accrual mock started on %v ===", *address)
+
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		logger.Errorf("---> ERROR: failed run accrual mock: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func loadSeed(mock *accrualmock.Mock, file string) error {
+	data, errRead := os.ReadFile(file)
+	if errRead != nil {
+		return errRead
+	}
+
+	seedData := seed{}
+
+	errDecode := json.Unmarshal(data, &seedData)
+	if errDecode != nil {
+		return errDecode
+	}
+
+	for _, rule := range seedData.Goods {
+		errRule := mock.AddRewardRule(rule)
+		if errRule != nil {
+			return errRule
+		}
+	}
+
+	for _, order := range seedData.Orders {
+		errOrder := mock.RegisterOrder(order)
+		if errOrder != nil {
+			return errOrder
+		}
+	}
+
+	return nil
+}
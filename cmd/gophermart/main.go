@@ -1,7 +1,11 @@
 package main
 
-import "github.com/lexizz/cumloys/internal/app"
+import (
+	"os"
+
+	"github.com/lexizz/cumloys/internal/app"
+)
 
 func main() {
-	app.Run()
+	os.Exit(app.Execute(os.Args[1:]))
 }
@@ -2,72 +2,131 @@ package app
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
-	"net/url"
 	"os"
 	"os/signal"
-	"strings"
 	"syscall"
-	"time"
 
-	"github.com/golang-migrate/migrate/v4"
-	"github.com/golang-migrate/migrate/v4/database/pgx"
-	_ "github.com/golang-migrate/migrate/v4/source/file" // driver to open file with migrations
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
 
+	"github.com/lexizz/cumloys/internal/client/accrualclient"
+	"github.com/lexizz/cumloys/internal/client/webhookclient"
 	configPackage "github.com/lexizz/cumloys/internal/config"
-	"github.com/lexizz/cumloys/internal/db/dbclient/postgresql"
 	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/bonusrules"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
 	pkgLogger "github.com/lexizz/cumloys/internal/pkg/logger"
-	"github.com/lexizz/cumloys/internal/repository/orderrepository"
-	"github.com/lexizz/cumloys/internal/repository/scorerepository"
-	"github.com/lexizz/cumloys/internal/repository/transactionrepository"
-	"github.com/lexizz/cumloys/internal/repository/userrepository"
+	"github.com/lexizz/cumloys/internal/repository"
 	"github.com/lexizz/cumloys/internal/server"
 	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/adminservice"
 	"github.com/lexizz/cumloys/internal/service/createorderservice"
 	"github.com/lexizz/cumloys/internal/service/createuserservice"
+	"github.com/lexizz/cumloys/internal/service/expirepointsservice"
 	"github.com/lexizz/cumloys/internal/service/findbalanceservice"
 	"github.com/lexizz/cumloys/internal/service/findorderservice"
 	"github.com/lexizz/cumloys/internal/service/finduserservice"
 	"github.com/lexizz/cumloys/internal/service/findwithdrawpointsservice"
 	"github.com/lexizz/cumloys/internal/service/gettingpointsservice"
+	"github.com/lexizz/cumloys/internal/service/healthservice"
+	"github.com/lexizz/cumloys/internal/service/idempotencyservice"
+	"github.com/lexizz/cumloys/internal/service/reversalservice"
+	"github.com/lexizz/cumloys/internal/service/sessionservice"
+	"github.com/lexizz/cumloys/internal/service/tierservice"
+	"github.com/lexizz/cumloys/internal/service/webhookservice"
 	"github.com/lexizz/cumloys/internal/service/withdrawpointsservice"
 	"github.com/lexizz/cumloys/internal/transport/http/handler"
+	"github.com/lexizz/cumloys/internal/worker/accrualworker"
+	"github.com/lexizz/cumloys/internal/worker/expirationworker"
+	"github.com/lexizz/cumloys/internal/worker/webhookworker"
 )
 
-func Run() {
+// userEventsHistorySize - events of all users kept for streams reconnected with Last-Event-ID
+const userEventsHistorySize = 10000
+
+// Run serves the API until a shutdown signal, SIGHUP reloads the config instead.
+// Exit code: 0 - stopped by a signal; 2 - failed to start.
+func Run(args []string) int {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	config := configPackage.Init()
-	logger := pkgLogger.Init()
+	config, isConfigLoaded := loadConfig(&pflag.FlagSet{}, args)
+	if !isConfigLoaded {
+		return exitCodeError
+	}
+
+	logger, isLoggerReady := initLogger(config)
+	if !isLoggerReady {
+		return exitCodeError
+	}
 
-	poolConnection, errorConnectDB := postgresql.NewClient(ctx, 5, config.Postgresql, logger)
-	if errorConnectDB != nil {
-		logger.Errorf("---> ERROR: failed connect to database: %v\n", errorConnectDB)
-		return
+	store, isStorageReady := initStorage(ctx, config, logger)
+	if !isStorageReady {
+		return exitCodeError
 	}
 
-	resultInitialize := InitializingDatabase(config.Postgresql, logger)
-	if !resultInitialize {
-		return
+	jwt, errToken := models.NewJWT(
+		config.JWT.SignatureAlgorithm,
+		config.JWT.SecretKeyJWT,
+		config.JWT.PrivateKeyFiles,
+		config.JWT.ExpiryIn,
+	)
+	if errToken != nil {
+		logger.Errorf("---> ERROR: create token: %v ======\n", errToken)
+		return exitCodeError
 	}
 
-	userRepo := userrepository.New(poolConnection, logger)
-	orderRepo := orderrepository.New(poolConnection, logger)
-	scoreRepo := scorerepository.New(poolConnection, logger)
-	transactionRepo := transactionrepository.New(poolConnection, logger)
+	bonusEngine, errBonusRules := bonusrules.Load(config.Bonus.RulesFile)
+	if errBonusRules != nil {
+		logger.Errorf("---> ERROR: failed load bonus rules: %v\n", errBonusRules)
+		return exitCodeError
+	}
 
-	createUserService := createuserservice.New(userRepo, logger)
-	findUserService := finduserservice.New(userRepo, logger)
-	createOrderService := createorderservice.New(orderRepo, transactionRepo, logger)
-	findOrderService := findorderservice.New(orderRepo, logger)
-	findBalanceService := findbalanceservice.New(scoreRepo, transactionRepo, logger)
-	gettingPointsService := gettingpointsservice.New(config, &http.Client{}, createOrderService, orderRepo, scoreRepo, transactionRepo, logger)
-	withdrawPointsService := withdrawpointsservice.New(orderRepo, scoreRepo, transactionRepo, logger)
-	findWithdrawPointsService := findwithdrawpointsservice.New(transactionRepo, logger)
+	logger.Infof("=== Bonus rules: %d, loyalty tiers: %d ===", len(bonusEngine.Rules()), len(bonusEngine.Tiers()))
+
+	accrualClient := accrualclient.New(config.Accrual.Address, config.Accrual.RequestTimeout, &http.Client{}, logger)
+	eventBus := eventbus.New(userEventsHistorySize, logger)
+
+	grantAdminRole(ctx, store.user, config.Admin.Logins, logger)
+
+	createUserService := createuserservice.New(store.user, logger)
+	findUserService := finduserservice.New(store.user, logger)
+	createOrderService := createorderservice.New(store.order, store.transaction, logger)
+	findOrderService := findorderservice.New(store.order, logger)
+	tierService := tierservice.New(bonusEngine.Tiers(), store.tier, store.transaction, logger)
+	findBalanceService := findbalanceservice.New(config, store.score, store.transaction, store.pointLot, logger)
+	withdrawPointsService := withdrawpointsservice.New(store.unitOfWork, eventBus, logger)
+	findWithdrawPointsService := findwithdrawpointsservice.New(store.transaction, logger)
+	idempotencyService := idempotencyservice.New(config, store.idempotency, logger)
+	sessionService := sessionservice.New(config, store.session, store.user, jwt, logger)
+	healthService := healthservice.New(config, store.schema, accrualClient, store.migrationVersion, logger)
+	webhookService := webhookservice.New(store.webhook, config.Webhook.AllowPrivateNetworks, logger)
+	expirePointsService := expirepointsservice.New(store.unitOfWork, eventBus, logger)
+	adminService := adminservice.New(store.user, store.transaction, store.session, store.unitOfWork, eventBus, logger)
+	reversalService := reversalservice.New(
+		config.Reversal.NegativeBalancePolicy,
+		store.order,
+		store.transaction,
+		store.unitOfWork,
+		tierService,
+		eventBus,
+		logger,
+	)
+
+	gettingPointsService := gettingpointsservice.New(
+		accrualClient,
+		createOrderService,
+		store.order,
+		store.unitOfWork,
+		bonusEngine,
+		tierService,
+		reversalService,
+		eventBus,
+		logger,
+	)
 
 	services := service.Services{
 		CreateUserService:         createUserService,
@@ -78,21 +137,41 @@ func Run() {
 		GettingPointsService:      gettingPointsService,
 		WithdrawPointsService:     withdrawPointsService,
 		FindWithdrawPointsService: findWithdrawPointsService,
+		IdempotencyService:        idempotencyService,
+		SessionService:            sessionService,
+		HealthService:             healthService,
+		WebhookService:            webhookService,
+		TierService:               tierService,
+		AdminService:              adminService,
+		ReversalService:           reversalService,
 	}
 
-	jwt, errToken := models.NewJWT(config.JWT.SignatureAlgorithm, config.JWT.SecretKeyJWT, config.JWT.ExpiryIn)
-	if errToken != nil {
-		logger.Errorf("---> ERROR: create token: %v ======\n", errorConnectDB)
-		return
-	}
+	rateLimiter := handler.NewRateLimiter(config.Limiter)
 
-	handlers := handler.New(config, logger, &services, jwt)
+	handlers := handler.New(config, logger, &services, jwt, eventBus, rateLimiter)
 	srv := server.New(ctx, config, handlers.Init(), logger)
 	if srv == nil {
 		logger.Error("---> ERROR: failed starting server")
-		return
+		return exitCodeError
 	}
 
+	prometheus.MustRegister(store.collectors...)
+
+	adminSrv := server.NewAdmin(ctx, config, handlers.InitAdmin(), logger)
+	if adminSrv == nil {
+		logger.Error("---> ERROR: failed starting admin server")
+		return exitCodeError
+	}
+
+	worker := accrualworker.New(config, accrualClient, store.order, gettingPointsService, logger)
+	worker.Start(ctx)
+
+	webhookWorker := webhookworker.New(config, store.outbox, store.webhook, webhookclient.New(webhookclient.NewHTTPClient(config.Webhook.AllowPrivateNetworks), logger), logger)
+	webhookWorker.Start(ctx)
+
+	expirationWorker := expirationworker.New(config, store.pointLot, expirePointsService, logger)
+	expirationWorker.Start(ctx)
+
 	signalChanel := make(chan os.Signal, 1)
 	defer close(signalChanel)
 
@@ -108,18 +187,44 @@ func Run() {
 		signalChanel <- os.Interrupt
 	}()
 
+	go func() {
+		logger.Info("=== Admin server started ===")
+
+		if err := adminSrv.Run(); !errors.Is(err, http.ErrServerClosed) {
+			logger.Errorf("---> ERROR: failed run admin http server: %s\n", err.Error())
+		}
+	}()
+
 	signal.Notify(signalChanel,
 		syscall.SIGHUP,
 		syscall.SIGINT,
 		syscall.SIGTERM,
 		syscall.SIGQUIT)
 
+	configReloader := &reloader{
+		args:          args,
+		started:       config,
+		current:       config,
+		jwt:           jwt,
+		bonusEngine:   bonusEngine,
+		rateLimiter:   rateLimiter,
+		accrualWorker: worker,
+		logger:        logger,
+	}
+
 	sig := <-signalChanel
+	for sig == syscall.SIGHUP {
+		configReloader.reload()
+
+		sig = <-signalChanel
+	}
+
 	cancel()
+	eventBus.Close()
 
 	logger.Info("=== HandleSignals: signal detected - ", sig.String(), "; Stopping server...")
 
-	ctxTimeout, cancelCtxTimeout := context.WithTimeout(context.Background(), 5*time.Second)
+	ctxTimeout, cancelCtxTimeout := context.WithTimeout(context.Background(), config.HTTP.ShutdownTimeout)
 
 	defer func() {
 		logger.Info("=== soft server shutdown process has started... ===")
@@ -129,53 +234,56 @@ func Run() {
 
 	if err := srv.Stop(ctxTimeout); err != nil {
 		logger.Errorf("---> ERROR: server shutdown failed: %v", err)
-		return
 	}
-}
-
-func InitializingDatabase(cfg configPackage.PostgresqlConfig, logger pkgLogger.Logger) bool {
-	logger.Info("=== Initializing the database... ")
 
-	db, errOpen := sql.Open("pgx", cfg.DSN)
-	if errOpen != nil {
-		logger.Errorf("---> ERROR: MIGRATE: failed open connect to database: %v\n", errOpen)
-		return false
+	if err := adminSrv.Stop(ctxTimeout); err != nil {
+		logger.Errorf("---> ERROR: admin server shutdown failed: %v", err)
 	}
 
-	driver, errPGXInstance := pgx.WithInstance(db, &pgx.Config{})
-	if errPGXInstance != nil {
-		logger.Errorf("---> ERROR: MIGRATE: failed instance pgx driver: %v\n", errPGXInstance)
-		return false
-	}
+	worker.Stop()
+	expirationWorker.Stop()
+	webhookWorker.Stop()
 
-	urlParsed, errParseURL := url.Parse(cfg.DSN)
-	if errParseURL != nil {
-		logger.Errorf("---> ERROR: MIGRATE: failed parse dns for getting dbname: %v\n", errParseURL)
-		return false
+	return exitCodeOK
+}
+
+// loadConfig prints the errors of the config to stderr, so every invalid setting is on a line of its own.
+func loadConfig(flagSet *pflag.FlagSet, args []string) (*configPackage.Config, bool) {
+	config, errConfig := configPackage.InitWithFlagSet(flagSet, args)
+	if errConfig != nil {
+		fmt.Fprintf(os.Stderr, "---> ERROR: %v\n", errConfig)
+		return nil, false
 	}
 
-	dbName := strings.ReplaceAll(urlParsed.Path, "/", "")
+	return config, true
+}
 
-	migrateInstance, errInst := migrate.NewWithDatabaseInstance("file://internal/db/migrations", dbName, driver)
-	// migrateInstance, errInst := migrate.NewWithDatabaseInstance("file://../../internal/db/migrations", dbName, driver)
-	// migrateInstance, errInst := migrate.NewWithDatabaseInstance("file://../cumloys/internal/db/migrations", dbName, driver)
-	if errInst != nil {
-		logger.Errorf("---> ERROR: MIGRATE: failed instance migrate: %v\n", errInst)
-		return false
-	}
+// initLogger creates the logger with the format and the level of config.Log.
+func initLogger(config *configPackage.Config) (pkgLogger.Logger, bool) {
+	logger := pkgLogger.Init()
 
-	errUp := migrateInstance.Up()
-	if errUp != nil {
-		logger.Info("=== changes for migrate not found [" + errUp.Error() + "]")
+	errLogger := pkgLogger.Configure(logger, config.Log.Format, config.Log.LevelName())
+	if errLogger != nil {
+		fmt.Fprintf(os.Stderr, "---> ERROR: configure log: %v\n", errLogger)
+		return nil, false
 	}
 
-	errCloseSource, errDBClose := migrateInstance.Close()
-	if errCloseSource != nil || errDBClose != nil {
-		logger.Errorf("---> ERROR: MIGRATE: failed close db for migrate: %v; %v\n", errCloseSource, errDBClose)
-		return false
-	}
+	return logger, true
+}
 
-	logger.Info("=== Initializing finished === ")
+// grantAdminRole gives the admin role to the already registered users of ADMIN_LOGINS. Registration never
+// grants it: anyone could take a listed login which isn't registered yet, such users get it on the next start
+// or with `user create --admin`.
+func grantAdminRole(ctx context.Context, userRepository repository.UserRepositoryInterface, logins []string, logger pkgLogger.Logger) {
+	for _, login := range logins {
+		isFound, errRole := userRepository.SetRole(ctx, login, models.RoleAdmin)
+		if errRole != nil {
+			logger.Errorf("---> ERROR: failed grant admin role to %v: %v\n", login, errRole)
+			continue
+		}
 
-	return true
+		if isFound {
+			logger.Infof("=== User %v has the admin role ===", login)
+		}
+	}
 }
@@ -0,0 +1,173 @@
+package app
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const binaryName = "gophermart"
+
+// command - a node of the command tree of the binary: either a group of subcommands or a command with run.
+// run gets the arguments after the name of the command and returns the exit code.
+type command struct {
+	name        string
+	usage       string
+	description string
+	subcommands []command
+	run         func(args []string) int
+}
+
+func commandTree() command {
+	return command{
+		name: binaryName,
+		subcommands: []command{
+			{
+				name:        "serve",
+				usage:       "[flags]",
+				description: "serve the API, it is the default command",
+				run:         Run,
+			},
+			{
+				name:        "migrate",
+				usage:       "up | down [N] | goto V | version | force V [flags]",
+				description: "apply or roll back the migrations compiled into the binary",
+				run:         Migrate,
+			},
+			{
+				name:        "user",
+				description: "manage users",
+				subcommands: []command{
+					{
+						name:        "create",
+						usage:       "LOGIN [--password P] [--admin] [flags]",
+						description: "register a user, the password is read from stdin without --password",
+						run:         CreateUser,
+					},
+					{
+						name:        "block",
+						usage:       "LOGIN [flags]",
+						description: "forbid the user to log in and revoke the sessions",
+						run:         func(args []string) int { return BlockUser(args, true) },
+					},
+					{
+						name:        "unblock",
+						usage:       "LOGIN [flags]",
+						description: "allow the blocked user to log in",
+						run:         func(args []string) int { return BlockUser(args, false) },
+					},
+					{
+						name:        "reset-password",
+						usage:       "LOGIN [--password P] [flags]",
+						description: "replace the password and revoke the sessions, the password is read from stdin without --password",
+						run:         ResetPassword,
+					},
+				},
+			},
+			{
+				name:        "balance",
+				description: "manage balances",
+				subcommands: []command{
+					{
+						name:        "adjust",
+						usage:       "LOGIN --sum SUM --reason R --operator ADMIN_LOGIN [flags]",
+						description: "credit a positive SUM or debit a negative one on behalf of the admin, e.g. --sum -30",
+						run:         AdjustBalance,
+					},
+				},
+			},
+			{
+				name:        "orders",
+				description: "manage orders",
+				subcommands: []command{
+					{
+						name:        "resync",
+						usage:       "[NUMBER...] [flags]",
+						description: "make the orders poll the accrual system at once, without numbers - every pending order",
+						run:         ResyncOrders,
+					},
+				},
+			},
+			{
+				name:        "reconcile",
+				usage:       "[--repair] [flags]",
+				description: "compare the score of every user with the sum of the ledger",
+				run:         Reconcile,
+			},
+			{
+				name:        "config",
+				description: "inspect the configuration",
+				subcommands: []command{
+					{
+						name:        "print",
+						usage:       "[--redacted] [flags]",
+						description: "print the configuration loaded from the file, environment and flags in the format of the file",
+						run:         PrintConfig,
+					},
+				},
+			},
+		},
+	}
+}
+
+// Execute runs the command of args. Without a command the API is served,
+// so `gophermart -a :8080 -d <dsn>` works as before the commands appeared.
+func Execute(args []string) int {
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		return Run(args)
+	}
+
+	return commandTree().execute(binaryName, args)
+}
+
+func (cmd command) execute(path string, args []string) int {
+	if cmd.run != nil {
+		return cmd.run(args)
+	}
+
+	if len(args) > 0 && args[0] == "help" {
+		cmd.printUsage(os.Stdout, path)
+		return exitCodeOK
+	}
+
+	if len(args) == 0 {
+		cmd.printUsage(os.Stderr, path)
+		return exitCodeError
+	}
+
+	for _, subcommand := range cmd.subcommands {
+		if subcommand.name == args[0] {
+			return subcommand.execute(path+" "+subcommand.name, args[1:])
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command: %v %v\n", path, args[0])
+	cmd.printUsage(os.Stderr, path)
+
+	return exitCodeError
+}
+
+// printUsage lists the commands of the group with all their subcommands.
+func (cmd command) printUsage(writer io.Writer, path string) {
+	fmt.Fprintf(writer, "Usage: %v <command>\nCommands:\n", path)
+
+	for _, subcommand := range cmd.subcommands {
+		subcommand.printLines(writer, path)
+	}
+
+	fmt.Fprintf(writer, "Flags of the configuration are accepted by every command, see `%v serve --help`.\n", binaryName)
+}
+
+func (cmd command) printLines(writer io.Writer, path string) {
+	path += " " + cmd.name
+
+	if cmd.run != nil {
+		fmt.Fprintf(writer, "  %v %v\n      %v\n", path, cmd.usage, cmd.description)
+		return
+	}
+
+	for _, subcommand := range cmd.subcommands {
+		subcommand.printLines(writer, path)
+	}
+}
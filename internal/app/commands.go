@@ -0,0 +1,334 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/pflag"
+
+	configPackage "github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	pkgLogger "github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/adminservice"
+	"github.com/lexizz/cumloys/internal/service/createuserservice"
+)
+
+var errUserNotFound = errors.New("user not found")
+
+// commandEnv - what a command of the binary works with: the config loaded with the flags of the command,
+// the repositories of the database and the positional arguments.
+type commandEnv struct {
+	ctx    context.Context
+	config *configPackage.Config
+	logger pkgLogger.Logger
+	store  *storage
+	args   []string
+	close  func()
+}
+
+// newCommandFlagSet lets flags follow the positional arguments, e.g. `user block ivan -d <dsn>`.
+func newCommandFlagSet() *pflag.FlagSet {
+	flagSet := &pflag.FlagSet{}
+	flagSet.SetInterspersed(true)
+
+	return flagSet
+}
+
+// initCommand loads the config with the flags of flagSet and connects to the database.
+// The command needs minArgs positional arguments, usage is printed when they are missing.
+func initCommand(flagSet *pflag.FlagSet, args []string, minArgs int, usage string) (*commandEnv, bool) {
+	ctx := context.Background()
+
+	config, isConfigLoaded := loadConfig(flagSet, args)
+	if !isConfigLoaded {
+		return nil, false
+	}
+
+	logger, isLoggerReady := initLogger(config)
+	if !isLoggerReady {
+		return nil, false
+	}
+
+	if flagSet.NArg() < minArgs {
+		fmt.Fprintf(os.Stderr, "Usage: %v %v\n", binaryName, usage)
+		return nil, false
+	}
+
+	store, closeStorage, isStorageReady := initCommandStorage(ctx, config, logger)
+	if !isStorageReady {
+		return nil, false
+	}
+
+	return &commandEnv{
+		ctx:    ctx,
+		config: config,
+		logger: logger,
+		store:  store,
+		args:   flagSet.Args(),
+		close:  closeStorage,
+	}, true
+}
+
+// newAdminService - the events of the balance published by a command don't reach streams of the running service,
+// webhooks get them from the outbox as usual.
+func (env *commandEnv) newAdminService() service.AdminServiceInterface {
+	return adminservice.New(
+		env.store.user,
+		env.store.transaction,
+		env.store.session,
+		env.store.unitOfWork,
+		eventbus.New(0, env.logger),
+		env.logger,
+	)
+}
+
+func (env *commandEnv) getUser(login string) (*models.User, error) {
+	user, errUser := env.store.user.GetUserByLogin(env.ctx, login)
+	if errUser != nil {
+		return nil, errUser
+	}
+
+	if user == nil {
+		return nil, fmt.Errorf("%w: %v", errUserNotFound, login)
+	}
+
+	return user, nil
+}
+
+// CreateUser registers a user like POST /api/user/register does.
+func CreateUser(args []string) int {
+	flagSet := newCommandFlagSet()
+	password := flagSet.String("password", "", "password of the user; it is read from stdin when empty")
+	isAdmin := flagSet.Bool("admin", false, "give the admin role to the user")
+
+	env, isReady := initCommand(flagSet, args, 1, "user create LOGIN [--password P] [--admin]")
+	if !isReady {
+		return exitCodeError
+	}
+
+	defer env.close()
+
+	login := env.args[0]
+
+	newPassword, errPassword := readPassword(*password, os.Stdin)
+	if errPassword != nil {
+		env.logger.Errorf("---> ERROR: user create: %v\n", errPassword)
+		return exitCodeError
+	}
+
+	userID, errCreate := createuserservice.New(env.store.user, env.logger).Handle(env.ctx, login, newPassword)
+	if errCreate != nil {
+		env.logger.Errorf("---> ERROR: user create: %v\n", errCreate)
+		return exitCodeError
+	}
+
+	if *isAdmin {
+		_, errRole := env.store.user.SetRole(env.ctx, login, models.RoleAdmin)
+		if errRole != nil {
+			env.logger.Errorf("---> ERROR: user create: set admin role: %v\n", errRole)
+			return exitCodeError
+		}
+	}
+
+	fmt.Fprintf(os.Stdout, "%v\t%v\n", userID, login)
+
+	return exitCodeOK
+}
+
+// BlockUser blocks the user when isBlocked is true and unblocks otherwise. The command has no operator,
+// so unlike the admin API it can block any account.
+func BlockUser(args []string, isBlocked bool) int {
+	commandName := "user block"
+	if !isBlocked {
+		commandName = "user unblock"
+	}
+
+	env, isReady := initCommand(newCommandFlagSet(), args, 1, commandName+" LOGIN")
+	if !isReady {
+		return exitCodeError
+	}
+
+	defer env.close()
+
+	user, errUser := env.getUser(env.args[0])
+	if errUser != nil {
+		env.logger.Errorf("---> ERROR: %v: %v\n", commandName, errUser)
+		return exitCodeError
+	}
+
+	var errBlock error
+
+	if isBlocked {
+		errBlock = env.newAdminService().Block(env.ctx, user.ID, uuid.Nil)
+	} else {
+		errBlock = env.newAdminService().Unblock(env.ctx, user.ID, uuid.Nil)
+	}
+
+	if errBlock != nil {
+		env.logger.Errorf("---> ERROR: %v: %v\n", commandName, errBlock)
+		return exitCodeError
+	}
+
+	fmt.Fprintf(os.Stdout, "%v\t%v\tblocked: %v\n", user.ID, user.Login, isBlocked)
+
+	return exitCodeOK
+}
+
+// ResetPassword replaces the password of the user and revokes the sessions.
+func ResetPassword(args []string) int {
+	flagSet := newCommandFlagSet()
+	password := flagSet.String("password", "", "new password of the user; it is read from stdin when empty")
+
+	env, isReady := initCommand(flagSet, args, 1, "user reset-password LOGIN [--password P]")
+	if !isReady {
+		return exitCodeError
+	}
+
+	defer env.close()
+
+	user, errUser := env.getUser(env.args[0])
+	if errUser != nil {
+		env.logger.Errorf("---> ERROR: user reset-password: %v\n", errUser)
+		return exitCodeError
+	}
+
+	newPassword, errPassword := readPassword(*password, os.Stdin)
+	if errPassword != nil {
+		env.logger.Errorf("---> ERROR: user reset-password: %v\n", errPassword)
+		return exitCodeError
+	}
+
+	errReset := env.newAdminService().ResetPassword(env.ctx, user.ID, newPassword)
+	if errReset != nil {
+		env.logger.Errorf("---> ERROR: user reset-password: %v\n", errReset)
+		return exitCodeError
+	}
+
+	fmt.Fprintf(os.Stdout, "%v\t%v\tpassword is reset\n", user.ID, user.Login)
+
+	return exitCodeOK
+}
+
+// AdjustBalance credits or debits the balance like POST /api/admin/users/{id}/adjustments does,
+// the ledger keeps the admin given by --operator.
+func AdjustBalance(args []string) int {
+	const usage = "balance adjust LOGIN --sum SUM --reason R --operator ADMIN_LOGIN"
+
+	flagSet := newCommandFlagSet()
+	sum := flagSet.String("sum", "", "points to credit, a negative sum is debited")
+	reason := flagSet.String("reason", "", "reason of the adjustment")
+	operatorLogin := flagSet.String("operator", "", "login of the admin who makes the adjustment")
+
+	env, isReady := initCommand(flagSet, args, 1, usage)
+	if !isReady {
+		return exitCodeError
+	}
+
+	defer env.close()
+
+	points, errPoints := models.ParsePoints(*sum)
+	if errPoints != nil {
+		env.logger.Errorf("---> ERROR: balance adjust: --sum: %v\n", errPoints)
+		return exitCodeError
+	}
+
+	operator, errOperator := env.getUser(*operatorLogin)
+	if errOperator != nil {
+		env.logger.Errorf("---> ERROR: balance adjust: --operator: %v\n", errOperator)
+		return exitCodeError
+	}
+
+	if operator.Role != models.RoleAdmin {
+		env.logger.Errorf("---> ERROR: balance adjust: --operator: %v isn't an admin\n", operator.Login)
+		return exitCodeError
+	}
+
+	user, errUser := env.getUser(env.args[0])
+	if errUser != nil {
+		env.logger.Errorf("---> ERROR: balance adjust: %v\n", errUser)
+		return exitCodeError
+	}
+
+	currentBalance, errAdjust := env.newAdminService().Adjust(env.ctx, user.ID, operator.ID, points, *reason)
+	if errAdjust != nil {
+		env.logger.Errorf("---> ERROR: balance adjust: %v\n", errAdjust)
+		return exitCodeError
+	}
+
+	fmt.Fprintf(os.Stdout, "%v\t%v\tcurrent: %v\n", user.ID, user.Login, currentBalance)
+
+	return exitCodeOK
+}
+
+// ResyncOrders makes the accrual worker of the running service poll the orders at once,
+// e.g. after the accrual system was unavailable for long and the orders have a long backoff.
+func ResyncOrders(args []string) int {
+	env, isReady := initCommand(newCommandFlagSet(), args, 0, "orders resync [NUMBER...]")
+	if !isReady {
+		return exitCodeError
+	}
+
+	defer env.close()
+
+	count, errReset := env.store.order.ResetPolling(env.ctx, env.args)
+	if errReset != nil {
+		env.logger.Errorf("---> ERROR: orders resync: %v\n", errReset)
+		return exitCodeError
+	}
+
+	fmt.Fprintf(os.Stdout, "orders to poll: %v\n", count)
+
+	return exitCodeOK
+}
+
+// PrintConfig prints the config as the service would load it with the same file, environment and flags.
+// The output is in the format of the config file; --redacted hides the secrets.
+func PrintConfig(args []string) int {
+	flagSet := newCommandFlagSet()
+	isRedacted := flagSet.Bool("redacted", false, "replace passwords and keys with asterisks")
+
+	config, isConfigLoaded := loadConfig(flagSet, args)
+	if !isConfigLoaded {
+		return exitCodeError
+	}
+
+	if *isRedacted {
+		config = config.Redacted()
+	}
+
+	errEncode := config.Encode(os.Stdout)
+	if errEncode != nil {
+		fmt.Fprintf(os.Stderr, "---> ERROR: config print: %v\n", errEncode)
+		return exitCodeError
+	}
+
+	return exitCodeOK
+}
+
+// readPassword returns the password of the flag or the first line of reader,
+// so the password doesn't have to be in the shell history.
+func readPassword(flagValue string, reader io.Reader) (string, error) {
+	if len(flagValue) > 0 {
+		return flagValue, nil
+	}
+
+	line, errRead := bufio.NewReader(reader).ReadString('\n')
+	if errRead != nil && !errors.Is(errRead, io.EOF) {
+		return "", errRead
+	}
+
+	password := strings.TrimRight(line, "\r\n")
+	if len(password) == 0 {
+		return "", adminservice.ErrEmptyPassword
+	}
+
+	return password, nil
+}
@@ -0,0 +1,255 @@
+package app
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/golang-migrate/migrate/v4"
+	"github.com/golang-migrate/migrate/v4/database/pgx"
+	"github.com/golang-migrate/migrate/v4/source/iofs"
+
+	configPackage "github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/db/migrations"
+	pkgLogger "github.com/lexizz/cumloys/internal/pkg/logger"
+)
+
+const migrateUsage = `Usage: gophermart migrate <command> [flags]
+Commands:
+  up          apply all new migrations
+  down [N]    roll back N migrations, 1 by default
+  goto V      migrate up or down to the version V
+  version     print the applied version
+  force V     set the version V without running migrations, it clears the dirty state after a failed migration`
+
+// Migrate applies or rolls back the migrations compiled into the binary.
+// Exit code: 0 - success; 2 - error.
+func Migrate(args []string) int {
+	flagSet := newCommandFlagSet()
+
+	config, isConfigLoaded := loadConfig(flagSet, args)
+	if !isConfigLoaded {
+		return exitCodeError
+	}
+
+	logger, isLoggerReady := initLogger(config)
+	if !isLoggerReady {
+		return exitCodeError
+	}
+
+	command := flagSet.Args()
+	if len(command) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return exitCodeError
+	}
+
+	migrateInstance, errInst := newMigrate(config.Postgresql, logger)
+	if errInst != nil {
+		logger.Errorf("---> ERROR: MIGRATE: failed instance migrate: %v\n", errInst)
+		return exitCodeError
+	}
+
+	defer closeMigrate(migrateInstance, logger)
+
+	errCommand := runMigrateCommand(migrateInstance, command)
+	if errors.Is(errCommand, migrate.ErrNoChange) {
+		logger.Info("=== changes for migrate not found ===")
+	} else if errCommand != nil {
+		logger.Errorf("---> ERROR: MIGRATE: %v: %v\n", command[0], errCommand)
+		return exitCodeError
+	}
+
+	version, isDirty, errVersion := migrateInstance.Version()
+	if errors.Is(errVersion, migrate.ErrNilVersion) {
+		fmt.Fprintln(os.Stdout, "no migrations are applied")
+		return exitCodeOK
+	}
+
+	if errVersion != nil {
+		logger.Errorf("---> ERROR: MIGRATE: failed read version: %v\n", errVersion)
+		return exitCodeError
+	}
+
+	fmt.Fprintf(os.Stdout, "version: %v; dirty: %v\n", version, isDirty)
+
+	return exitCodeOK
+}
+
+func runMigrateCommand(migrateInstance *migrate.Migrate, command []string) error {
+	switch command[0] {
+	case "up":
+		return migrateInstance.Up()
+	case "down":
+		steps := 1
+
+		if len(command) > 1 {
+			parsedSteps, errSteps := strconv.Atoi(command[1])
+			if errSteps != nil || parsedSteps < 1 {
+				return fmt.Errorf("number of migrations must be positive: %v", command[1])
+			}
+
+			steps = parsedSteps
+		}
+
+		return migrateInstance.Steps(-steps)
+	case "goto":
+		version, errVersion := parseMigrationVersion(command)
+		if errVersion != nil {
+			return errVersion
+		}
+
+		return migrateInstance.Migrate(uint(version))
+	case "force":
+		version, errVersion := parseMigrationVersion(command)
+		if errVersion != nil {
+			return errVersion
+		}
+
+		return migrateInstance.Force(version)
+	case "version":
+		return nil
+	default:
+		return fmt.Errorf("unknown command\n%v", migrateUsage)
+	}
+}
+
+func parseMigrationVersion(command []string) (int, error) {
+	if len(command) < 2 {
+		return 0, fmt.Errorf("version is required\n%v", migrateUsage)
+	}
+
+	version, errVersion := strconv.Atoi(command[1])
+	if errVersion != nil || version < 0 {
+		return 0, fmt.Errorf("version must be a migration timestamp: %v", command[1])
+	}
+
+	return version, nil
+}
+
+// InitializingDatabase applies new migrations on start. Having nothing to apply is fine,
+// any other error stops the start, e.g. a dirty version after a failed migration.
+func InitializingDatabase(cfg configPackage.PostgresqlConfig, logger pkgLogger.Logger) bool {
+	logger.Info("=== Initializing the database... ")
+
+	migrateInstance, errInst := newMigrate(cfg, logger)
+	if errInst != nil {
+		logger.Errorf("---> ERROR: MIGRATE: failed instance migrate: %v\n", errInst)
+		return false
+	}
+
+	errUp := migrateInstance.Up()
+
+	isClosed := closeMigrate(migrateInstance, logger)
+
+	if errors.Is(errUp, migrate.ErrNoChange) {
+		logger.Info("=== changes for migrate not found ===")
+	} else if errUp != nil {
+		logger.Errorf("---> ERROR: MIGRATE: failed apply migrations: %v\n", errUp)
+		return false
+	}
+
+	if !isClosed {
+		return false
+	}
+
+	logger.Info("=== Initializing finished === ")
+
+	return true
+}
+
+// LatestMigrationVersion returns the version of the newest migration in the binary,
+// the schema is ready when this version is applied.
+func LatestMigrationVersion() (uint, error) {
+	driver, errOpen := iofs.New(migrations.FS, ".")
+	if errOpen != nil {
+		return 0, errOpen
+	}
+
+	defer driver.Close()
+
+	version, errFirst := driver.First()
+	if errFirst != nil {
+		return 0, errFirst
+	}
+
+	for {
+		nextVersion, errNext := driver.Next(version)
+		if errors.Is(errNext, os.ErrNotExist) {
+			return version, nil
+		}
+
+		if errNext != nil {
+			return 0, errNext
+		}
+
+		version = nextVersion
+	}
+}
+
+// newMigrate opens a separate connection to the database of cfg with the embedded migrations as the source.
+func newMigrate(cfg configPackage.PostgresqlConfig, logger pkgLogger.Logger) (*migrate.Migrate, error) {
+	dsn, errDSN := cfg.ConnectionString()
+	if errDSN != nil {
+		return nil, errDSN
+	}
+
+	urlParsed, errParseURL := url.Parse(dsn)
+	if errParseURL != nil {
+		return nil, fmt.Errorf("failed parse dsn for getting dbname: %w", errParseURL)
+	}
+
+	dbName := strings.ReplaceAll(urlParsed.Path, "/", "")
+
+	sourceDriver, errSource := iofs.New(migrations.FS, ".")
+	if errSource != nil {
+		return nil, fmt.Errorf("failed read migrations: %w", errSource)
+	}
+
+	db, errOpen := sql.Open("pgx", dsn)
+	if errOpen != nil {
+		return nil, fmt.Errorf("failed open connect to database: %w", errOpen)
+	}
+
+	driver, errPGXInstance := pgx.WithInstance(db, &pgx.Config{})
+	if errPGXInstance != nil {
+		_ = db.Close()
+		return nil, fmt.Errorf("failed instance pgx driver: %w", errPGXInstance)
+	}
+
+	migrateInstance, errInst := migrate.NewWithInstance("iofs", sourceDriver, dbName, driver)
+	if errInst != nil {
+		_ = driver.Close()
+		return nil, errInst
+	}
+
+	migrateInstance.Log = migrateLogger{logger: logger}
+
+	return migrateInstance, nil
+}
+
+func closeMigrate(migrateInstance *migrate.Migrate, logger pkgLogger.Logger) bool {
+	errCloseSource, errDBClose := migrateInstance.Close()
+	if errCloseSource != nil || errDBClose != nil {
+		logger.Errorf("---> ERROR: MIGRATE: failed close db for migrate: %v; %v\n", errCloseSource, errDBClose)
+		return false
+	}
+
+	return true
+}
+
+// migrateLogger writes applied migrations to the log of the service.
+type migrateLogger struct {
+	logger pkgLogger.Logger
+}
+
+func (log migrateLogger) Printf(format string, args ...interface{}) {
+	log.logger.Infof("=== MIGRATE: "+strings.TrimSpace(format), args...)
+}
+
+func (log migrateLogger) Verbose() bool {
+	return false
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/lexizz/cumloys/internal/service/reconcileservice"
+)
+
+const (
+	exitCodeOK    = 0
+	exitCodeDrift = 1
+	exitCodeError = 2
+)
+
+// Reconcile compares score.total of every user with the sum of the ledger and prints the drift.
+// With --repair the score is replaced by the sum of the ledger.
+// Exit code: 0 - no drift or everything was repaired; 1 - drift was found; 2 - error.
+func Reconcile(args []string) int {
+	flagSet := newCommandFlagSet()
+	repair := flagSet.Bool("repair", false, "set score to the sum of the ledger for every user with drift")
+
+	env, isReady := initCommand(flagSet, args, 0, "reconcile [--repair]")
+	if !isReady {
+		return exitCodeError
+	}
+
+	defer env.close()
+
+	reconcileService := reconcileservice.New(env.store.transaction, env.store.unitOfWork, env.logger)
+
+	drifts, errReconcile := reconcileService.Handle(env.ctx, *repair)
+
+	for _, drift := range drifts {
+		fmt.Fprintf(os.Stdout, "%v\tscore: %v\tledger: %v\tdrift: %v\n",
+			drift.UserID, drift.ScoreTotal, drift.LedgerTotal, drift.Drift())
+	}
+
+	if errReconcile != nil {
+		env.logger.Errorf("---> ERROR: reconcile: %v\n", errReconcile)
+		return exitCodeError
+	}
+
+	fmt.Fprintf(os.Stdout, "users with drift: %v; repaired: %v\n", len(drifts), *repair)
+
+	if len(drifts) > 0 && !*repair {
+		return exitCodeDrift
+	}
+
+	return exitCodeOK
+}
@@ -0,0 +1,136 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/pflag"
+
+	configPackage "github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/bonusrules"
+	pkgLogger "github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/transport/http/handler"
+	"github.com/lexizz/cumloys/internal/worker/accrualworker"
+)
+
+// liveSettings - keys of the config file applied on SIGHUP, a key ending with a dot or an underscore
+// covers every key with this prefix. Changes of the other keys need a restart.
+var liveSettings = []string{
+	"log.",
+	"limiter.",
+	"accrual.poll_",
+	"accrual.max_poll_backoff",
+	"accrual.max_poll_attempts",
+	"jwt.signature_algorithm",
+	"jwt.secret_key",
+	"jwt.private_key_files",
+	"jwt.expiry_in",
+	"bonus.rules_file",
+}
+
+// reloader reads the config again on SIGHUP and applies what can be changed without dropping connections:
+// the level and the format of the log, the rate limits, the polling of the accrual system, the keys of jwt and the bonus rules.
+// The key files and the rules file are read again even when their paths are the same.
+type reloader struct {
+	args []string
+	// started - the config of the start, the changes of the settings which need a restart are counted from it
+	started       *configPackage.Config
+	current       *configPackage.Config
+	jwt           *models.JWT
+	bonusEngine   *bonusrules.Engine
+	rateLimiter   *handler.RateLimiter
+	accrualWorker *accrualworker.AccrualWorker
+	logger        pkgLogger.Logger
+}
+
+// reload applies nothing when the new config, the keys or the rules are invalid, the service keeps the previous ones.
+func (reloader *reloader) reload() {
+	reloader.logger.Info("=== Reload: reading the config ... ===")
+
+	config, errConfig := configPackage.InitWithFlagSet(&pflag.FlagSet{}, reloader.args)
+	if errConfig != nil {
+		reloader.logger.Errorf("---> ERROR: reload: the previous config is kept: %v\n", errConfig)
+		return
+	}
+
+	jwt, errToken := models.NewJWT(
+		config.JWT.SignatureAlgorithm,
+		config.JWT.SecretKeyJWT,
+		config.JWT.PrivateKeyFiles,
+		config.JWT.ExpiryIn,
+	)
+	if errToken != nil {
+		reloader.logger.Errorf("---> ERROR: reload: the previous config is kept: create token: %v\n", errToken)
+		return
+	}
+
+	bonusEngine, errBonusRules := bonusrules.Load(config.Bonus.RulesFile)
+	if errBonusRules != nil {
+		reloader.logger.Errorf("---> ERROR: reload: the previous config is kept: load bonus rules: %v\n", errBonusRules)
+		return
+	}
+
+	errLogger := pkgLogger.Configure(reloader.logger, config.Log.Format, config.Log.LevelName())
+	if errLogger != nil {
+		reloader.logger.Errorf("---> ERROR: reload: the previous config is kept: configure log: %v\n", errLogger)
+		return
+	}
+
+	reloader.rateLimiter.SetConfig(config.Limiter)
+	reloader.accrualWorker.SetConfig(config.Accrual)
+	reloader.jwt.Replace(jwt)
+
+	needRestart := filterSettings(configPackage.Changes(reloader.started, config), false)
+
+	bonusRules := fmt.Sprintf("%d bonus rules are applied", len(bonusEngine.Rules()))
+
+	errReplaceRules := reloader.bonusEngine.ReplaceRules(bonusEngine)
+	if errReplaceRules != nil {
+		reloader.logger.Errorf("---> ERROR: reload: the previous bonus rules are kept: %v\n", errReplaceRules)
+
+		needRestart = append(needRestart, config.Bonus.RulesFile)
+		bonusRules = "the previous bonus rules are kept"
+	}
+
+	applied := filterSettings(configPackage.Changes(reloader.current, config), true)
+	reloader.current = config
+
+	reloader.logger.Infof(
+		"=== Reload: keys of jwt are read again, %v; changed: %v; need a restart: %v ===",
+		bonusRules,
+		joinSettings(applied),
+		joinSettings(needRestart),
+	)
+}
+
+// filterSettings returns the keys which are applied on SIGHUP when isLive is true and the rest otherwise.
+func filterSettings(keys []string, isLive bool) []string {
+	filtered := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if isLiveSetting(key) == isLive {
+			filtered = append(filtered, key)
+		}
+	}
+
+	return filtered
+}
+
+func isLiveSetting(key string) bool {
+	for _, setting := range liveSettings {
+		if key == setting || (strings.HasSuffix(setting, ".") || strings.HasSuffix(setting, "_")) && strings.HasPrefix(key, setting) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func joinSettings(keys []string) string {
+	if len(keys) == 0 {
+		return "nothing"
+	}
+
+	return strings.Join(keys, ", ")
+}
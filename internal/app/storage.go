@@ -0,0 +1,152 @@
+package app
+
+import (
+	"context"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+
+	configPackage "github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/db/dbclient/postgresql"
+	pkgLogger "github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/repository/idempotencyrepository"
+	"github.com/lexizz/cumloys/internal/repository/memoryrepository"
+	"github.com/lexizz/cumloys/internal/repository/orderrepository"
+	"github.com/lexizz/cumloys/internal/repository/outboxrepository"
+	"github.com/lexizz/cumloys/internal/repository/pointlotrepository"
+	"github.com/lexizz/cumloys/internal/repository/schemarepository"
+	"github.com/lexizz/cumloys/internal/repository/scorerepository"
+	"github.com/lexizz/cumloys/internal/repository/sessionrepository"
+	"github.com/lexizz/cumloys/internal/repository/tierrepository"
+	"github.com/lexizz/cumloys/internal/repository/transactionrepository"
+	"github.com/lexizz/cumloys/internal/repository/unitofwork"
+	"github.com/lexizz/cumloys/internal/repository/userrepository"
+	"github.com/lexizz/cumloys/internal/repository/webhookrepository"
+)
+
+// storage - repositories of the backend selected by STORAGE.
+type storage struct {
+	user             repository.UserRepositoryInterface
+	order            repository.OrderRepositoryInterface
+	score            repository.ScoreRepositoryInterface
+	transaction      repository.TransactionRepositoryInterface
+	idempotency      repository.IdempotencyRepositoryInterface
+	session          repository.SessionRepositoryInterface
+	outbox           repository.OutboxRepositoryInterface
+	webhook          repository.WebhookRepositoryInterface
+	pointLot         repository.PointLotRepositoryInterface
+	tier             repository.TierRepositoryInterface
+	schema           repository.SchemaRepositoryInterface
+	unitOfWork       repository.UnitOfWorkInterface
+	migrationVersion uint
+	collectors       []prometheus.Collector
+}
+
+func initStorage(ctx context.Context, config *configPackage.Config, logger pkgLogger.Logger) (*storage, bool) {
+	switch config.Storage.Type {
+	case configPackage.StorageMemory:
+		return initMemoryStorage(logger), true
+	case configPackage.StoragePostgres:
+		return initPostgresStorage(ctx, config, logger)
+	default:
+		logger.Errorf("---> ERROR: unknown storage: %v\n", config.Storage.Type)
+		return nil, false
+	}
+}
+
+func initPostgresStorage(ctx context.Context, config *configPackage.Config, logger pkgLogger.Logger) (*storage, bool) {
+	poolConnection, errorConnectDB := postgresql.NewClient(ctx, config.Postgresql, logger)
+	if errorConnectDB != nil {
+		logger.Errorf("---> ERROR: failed connect to database: %v\n", errorConnectDB)
+		return nil, false
+	}
+
+	if config.Postgresql.AutoMigrate {
+		resultInitialize := InitializingDatabase(config.Postgresql, logger)
+		if !resultInitialize {
+			return nil, false
+		}
+	} else {
+		logger.Warn("=== Auto-migrate is disabled, apply migrations by `gophermart migrate up` ===")
+	}
+
+	migrationVersion, errMigrationVersion := LatestMigrationVersion()
+	if errMigrationVersion != nil {
+		logger.Errorf("---> ERROR: failed read migrations: %v\n", errMigrationVersion)
+		return nil, false
+	}
+
+	store := newPostgresStorage(poolConnection, logger)
+	store.migrationVersion = migrationVersion
+	store.collectors = append(store.collectors, metrics.NewPoolCollector(poolConnection))
+
+	return store, true
+}
+
+// initCommandStorage connects commands of the binary to the database of the running service,
+// the memory storage of the service isn't reachable from another process. The returned function
+// closes the connection.
+func initCommandStorage(ctx context.Context, config *configPackage.Config, logger pkgLogger.Logger) (*storage, func(), bool) {
+	if config.Storage.Type != configPackage.StoragePostgres {
+		logger.Errorf("---> ERROR: the command needs the postgres storage, storage: %v\n", config.Storage.Type)
+		return nil, nil, false
+	}
+
+	poolConnection, errorConnectDB := postgresql.NewClient(ctx, config.Postgresql, logger)
+	if errorConnectDB != nil {
+		logger.Errorf("---> ERROR: failed connect to database: %v\n", errorConnectDB)
+		return nil, nil, false
+	}
+
+	return newPostgresStorage(poolConnection, logger), poolConnection.Close, true
+}
+
+func newPostgresStorage(poolConnection *pgxpool.Pool, logger pkgLogger.Logger) *storage {
+	orderRepo := orderrepository.New(poolConnection, logger)
+
+	return &storage{
+		user:        userrepository.New(poolConnection, logger),
+		order:       orderRepo,
+		score:       scorerepository.New(poolConnection, logger),
+		transaction: transactionrepository.New(poolConnection, logger),
+		idempotency: idempotencyrepository.New(poolConnection, logger),
+		session:     sessionrepository.New(poolConnection, logger),
+		outbox:      outboxrepository.New(poolConnection, logger),
+		webhook:     webhookrepository.New(poolConnection, logger),
+		pointLot:    pointlotrepository.New(poolConnection, logger),
+		tier:        tierrepository.New(poolConnection, logger),
+		schema:      schemarepository.New(poolConnection, logger),
+		unitOfWork:  unitofwork.New(poolConnection, logger),
+		collectors: []prometheus.Collector{
+			metrics.NewOrdersCollector(orderRepo.CountByStatus),
+		},
+	}
+}
+
+// initMemoryStorage keeps everything in the process, the data is lost on restart.
+func initMemoryStorage(logger pkgLogger.Logger) *storage {
+	logger.Warn("=== Storage: memory, the data will be lost on restart ===")
+
+	memoryStorage := memoryrepository.NewStorage()
+	orderRepo := memoryrepository.NewOrderRepository(memoryStorage, logger)
+
+	return &storage{
+		user:        memoryrepository.NewUserRepository(memoryStorage, logger),
+		order:       orderRepo,
+		score:       memoryrepository.NewScoreRepository(memoryStorage, logger),
+		transaction: memoryrepository.NewTransactionRepository(memoryStorage, logger),
+		idempotency: memoryrepository.NewIdempotencyRepository(memoryStorage, logger),
+		session:     memoryrepository.NewSessionRepository(memoryStorage, logger),
+		outbox:      memoryrepository.NewOutboxRepository(memoryStorage, logger),
+		webhook:     memoryrepository.NewWebhookRepository(memoryStorage, logger),
+		pointLot:    memoryrepository.NewPointLotRepository(memoryStorage, logger),
+		tier:        memoryrepository.NewTierRepository(memoryStorage, logger),
+		schema:      memoryrepository.NewSchemaRepository(0),
+		unitOfWork:  memoryrepository.NewUnitOfWork(memoryStorage, logger),
+		collectors: []prometheus.Collector{
+			metrics.NewOrdersCollector(orderRepo.CountByStatus),
+		},
+	}
+}
@@ -0,0 +1,308 @@
+package accrualclient
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/client"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+)
+
+var _ client.AccrualClientInterface = &accrualClient{}
+
+const defaultRetryAfter = 60 * time.Second
+
+var (
+	ErrOrderNotRegistered = errors.New("order isn't registered in the accrual system")
+	ErrTooManyRequests    = errors.New("too many requests to the accrual system")
+	ErrAccrualUnavailable = errors.New("accrual system is unavailable")
+)
+
+var rateLimitPattern = regexp.MustCompile(`No more than (\d+) requests per minute`)
+
+// accrualClient is shared by everyone who talks to the accrual system.
+// After 429 Too Many Requests all requests wait until Retry-After has passed,
+// and then they are spread out according to the limit from the body of the response.
+type accrualClient struct {
+	address         string
+	requestTimeout  time.Duration
+	httpClient      *http.Client
+	logger          logger.Logger
+	mutex           sync.Mutex
+	pausedUntil     time.Time
+	nextRequestAt   time.Time
+	requestInterval time.Duration
+}
+
+// New creates the client; requestTimeout limits every request to the accrual system.
+func New(address string, requestTimeout time.Duration, httpClient *http.Client, logger logger.Logger) *accrualClient {
+	return &accrualClient{
+		address:        address,
+		requestTimeout: requestTimeout,
+		httpClient:     httpClient,
+		logger:         logger,
+	}
+}
+
+func (client *accrualClient) PausedUntil() time.Time {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	return client.pausedUntil
+}
+
+// Ping checks that the accrual system answers at all. It doesn't spend the rate limit during a pause,
+// any response except 5xx means that the system is reachable.
+func (client *accrualClient) Ping(ctx context.Context) error {
+	if client.PausedUntil().After(utils.GetCurrentDatetimeUTC()) {
+		return ErrTooManyRequests
+	}
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, client.requestTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctxTimeout, http.MethodGet, client.address, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	response, err := client.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+
+	_, _ = io.Copy(io.Discard, response.Body)
+
+	errorResponseClose := response.Body.Close()
+	if errorResponseClose != nil {
+		return errorResponseClose
+	}
+
+	if response.StatusCode >= http.StatusInternalServerError {
+		return ErrAccrualUnavailable
+	}
+
+	return nil
+}
+
+// GetOrder waits for its turn, so lookups queued during a pause are resumed after it.
+// ErrTooManyRequests is returned only when the pause lasts longer than the context allows.
+func (client *accrualClient) GetOrder(ctx context.Context, numberOrder string) (*models.AccrualOrder, error) {
+	for {
+		errWait := client.wait(ctx)
+		if errWait != nil {
+			return nil, errWait
+		}
+
+		accrualOrder, errRequest := client.request(ctx, numberOrder)
+		if errors.Is(errRequest, ErrTooManyRequests) {
+			continue
+		}
+
+		return accrualOrder, errRequest
+	}
+}
+
+func (client *accrualClient) request(ctx context.Context, numberOrder string) (*models.AccrualOrder, error) {
+	url := client.address + "/api/orders/" + numberOrder
+
+	client.logger.Debugf("=== Url accrual: %v", url)
+
+	ctxTimeout, cancel := context.WithTimeout(ctx, client.requestTimeout)
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctxTimeout, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		client.logger.Errorf("---> ERROR: accrualClient: NewRequestWithContext: %v\n", err)
+		return nil, err
+	}
+
+	startedAt := time.Now()
+
+	response, err := client.httpClient.Do(request)
+
+	metrics.AccrualRequestDuration.Observe(time.Since(startedAt).Seconds())
+
+	if err != nil {
+		metrics.AccrualRequestsTotal.WithLabelValues(requestErrorOutcome(err)).Inc()
+
+		client.logger.Errorf("---> ERROR: accrualClient: request to accrual: %v\n", err)
+		return nil, err
+	}
+
+	metrics.AccrualRequestsTotal.WithLabelValues(responseOutcome(response.StatusCode)).Inc()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		client.logger.Errorf("---> ERROR: accrualClient: failed read response body: %v\n", err)
+		return nil, err
+	}
+
+	client.logger.Debugf("=== Response accrual status: %v | Body: %v", response.Status, string(body))
+
+	errorResponseClose := response.Body.Close()
+	if errorResponseClose != nil {
+		client.logger.Errorf("---> ERROR: accrualClient: failed response body close: %v\n", errorResponseClose)
+		return nil, errorResponseClose
+	}
+
+	switch response.StatusCode {
+	case http.StatusOK:
+	case http.StatusNoContent:
+		client.logger.Infof("=== points by this number of order not found: %v", response.Status)
+		return nil, ErrOrderNotRegistered
+	case http.StatusTooManyRequests:
+		client.throttle(response.Header.Get("Retry-After"), string(body))
+		return nil, ErrTooManyRequests
+	default:
+		client.logger.Errorf("---> ERROR: accrualClient: failed request: status: %v", response.Status)
+		return nil, ErrAccrualUnavailable
+	}
+
+	accrualOrder := models.AccrualOrder{}
+
+	errDecode := json.Unmarshal(body, &accrualOrder)
+	if errDecode != nil {
+		client.logger.Errorf("---> ERROR: accrualClient: json decode: %v\n", errDecode)
+		return nil, errDecode
+	}
+
+	return &accrualOrder, nil
+}
+
+// wait blocks until the pause is over and the next request fits into the known rate limit.
+func (client *accrualClient) wait(ctx context.Context) error {
+	for {
+		client.mutex.Lock()
+
+		now := utils.GetCurrentDatetimeUTC()
+
+		readyAt := now
+		if client.pausedUntil.After(readyAt) {
+			readyAt = client.pausedUntil
+		}
+
+		if client.nextRequestAt.After(readyAt) {
+			readyAt = client.nextRequestAt
+		}
+
+		if !readyAt.After(now) {
+			client.nextRequestAt = now.Add(client.requestInterval)
+			client.mutex.Unlock()
+
+			return nil
+		}
+
+		isPaused := client.pausedUntil.After(now)
+
+		client.mutex.Unlock()
+
+		if deadline, ok := ctx.Deadline(); ok && isPaused && deadline.Before(readyAt) {
+			return ErrTooManyRequests
+		}
+
+		timer := time.NewTimer(readyAt.Sub(now))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+func (client *accrualClient) throttle(retryAfterHeader string, body string) {
+	retryAfter := parseRetryAfter(retryAfterHeader)
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	pausedUntil := utils.GetCurrentDatetimeUTC().Add(retryAfter)
+	if pausedUntil.After(client.pausedUntil) {
+		client.pausedUntil = pausedUntil
+	}
+
+	metrics.AccrualThrottledTotal.Inc()
+	metrics.AccrualPaused.Set(1)
+
+	time.AfterFunc(retryAfter, func() {
+		if !client.PausedUntil().After(utils.GetCurrentDatetimeUTC()) {
+			metrics.AccrualPaused.Set(0)
+		}
+	})
+
+	requestsPerMinute := parseRateLimit(body)
+	if requestsPerMinute > 0 {
+		client.requestInterval = time.Minute / time.Duration(requestsPerMinute)
+		metrics.AccrualRateLimit.Set(float64(requestsPerMinute))
+	}
+
+	client.logger.Warnf("=== accrualClient: accrual system is throttling: paused for %v, limit: %v requests per minute",
+		retryAfter, requestsPerMinute)
+}
+
+func responseOutcome(statusCode int) string {
+	switch {
+	case statusCode == http.StatusOK:
+		return "ok"
+	case statusCode == http.StatusNoContent:
+		return "no_content"
+	case statusCode == http.StatusTooManyRequests:
+		return "too_many_requests"
+	case statusCode >= http.StatusInternalServerError:
+		return "server_error"
+	default:
+		return "unexpected_status"
+	}
+}
+
+func requestErrorOutcome(err error) string {
+	var netError net.Error
+
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netError) && netError.Timeout()) {
+		return "timeout"
+	}
+
+	return "error"
+}
+
+// parseRetryAfter supports both forms of the header: delay in seconds and HTTP date.
+func parseRetryAfter(header string) time.Duration {
+	if seconds, err := strconv.Atoi(header); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+
+	if date, err := http.ParseTime(header); err == nil {
+		if delay := time.Until(date); delay > 0 {
+			return delay
+		}
+	}
+
+	return defaultRetryAfter
+}
+
+func parseRateLimit(body string) int {
+	matches := rateLimitPattern.FindStringSubmatch(body)
+	if len(matches) < 2 {
+		return 0
+	}
+
+	requestsPerMinute, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return 0
+	}
+
+	return requestsPerMinute
+}
@@ -0,0 +1,18 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/models"
+)
+
+type AccrualClientInterface interface {
+	GetOrder(ctx context.Context, numberOrder string) (*models.AccrualOrder, error)
+	PausedUntil() time.Time
+	Ping(ctx context.Context) error
+}
+
+type WebhookClientInterface interface {
+	Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error)
+}
@@ -0,0 +1,142 @@
+package webhookclient
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/client"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+)
+
+var _ client.WebhookClientInterface = &webhookClient{}
+
+const (
+	EventHeader     = "X-Gophermart-Event"
+	DeliveryHeader  = "X-Gophermart-Delivery"
+	TimestampHeader = "X-Gophermart-Timestamp"
+	SignatureHeader = "X-Gophermart-Signature"
+
+	signaturePrefix = "sha256="
+	userAgent       = "Gophermart-Webhook/1.0"
+
+	dialTimeout = 30 * time.Second
+)
+
+var (
+	ErrUnexpectedStatus = errors.New("webhook answered with unexpected status")
+	ErrForbiddenAddress = errors.New("address of webhook is not public")
+)
+
+type webhookClient struct {
+	httpClient *http.Client
+	logger     logger.Logger
+}
+
+func New(httpClient *http.Client, logger logger.Logger) *webhookClient {
+	return &webhookClient{
+		httpClient: httpClient,
+		logger:     logger,
+	}
+}
+
+// NewHTTPClient returns the client for webhooks. Unless isPrivateAllowed, it connects only to public addresses:
+// the address is checked when the connection is made, after the host is resolved, so a host which resolves
+// to an internal address later (DNS rebinding) is rejected too. Redirects aren't followed, a 3xx is a failed delivery.
+func NewHTTPClient(isPrivateAllowed bool) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: dialTimeout,
+	}
+
+	if !isPrivateAllowed {
+		dialer.Control = rejectPrivateAddress
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// a proxy would be dialed instead of the webhook and the address of the webhook wouldn't be checked
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Transport: transport,
+		CheckRedirect: func(_ *http.Request, _ []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func rejectPrivateAddress(_ string, address string, _ syscall.RawConn) error {
+	host, _, errSplit := net.SplitHostPort(address)
+	if errSplit != nil {
+		return errSplit
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil || !utils.IsPublicIP(ip) {
+		return fmt.Errorf("%w: %v", ErrForbiddenAddress, host)
+	}
+
+	return nil
+}
+
+// Send posts the event of the delivery and returns the status code of the response.
+// Only 2xx means that the event is delivered.
+func (client *webhookClient) Send(ctx context.Context, delivery *models.WebhookDelivery) (int, error) {
+	body, errEncode := json.Marshal(delivery.Event)
+	if errEncode != nil {
+		return 0, errEncode
+	}
+
+	request, errRequest := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if errRequest != nil {
+		return 0, errRequest
+	}
+
+	timestamp := strconv.FormatInt(utils.GetCurrentDatetimeUTC().Unix(), 10)
+
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", userAgent)
+	request.Header.Set(EventHeader, delivery.Event.Type)
+	request.Header.Set(DeliveryHeader, delivery.ID.String())
+	request.Header.Set(TimestampHeader, timestamp)
+	request.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, body))
+
+	response, errDo := client.httpClient.Do(request)
+	if errDo != nil {
+		return 0, errDo
+	}
+
+	defer response.Body.Close()
+
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64*1024))
+
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return response.StatusCode, fmt.Errorf("%w: %v", ErrUnexpectedStatus, response.StatusCode)
+	}
+
+	return response.StatusCode, nil
+}
+
+// Sign returns the value of X-Gophermart-Signature: HMAC-SHA256 of "timestamp.body" with the secret of the webhook.
+// Receivers calculate it the same way and compare, the timestamp protects from replays of old events.
+func Sign(secret string, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
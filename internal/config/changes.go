@@ -0,0 +1,31 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// Changes returns the keys of the config file whose values differ, e.g. limiter.rps.
+func Changes(previous *Config, current *Config) []string {
+	return changedKeys(reflect.ValueOf(*previous), reflect.ValueOf(*current), "")
+}
+
+func changedKeys(previous reflect.Value, current reflect.Value, prefix string) []string {
+	keys := make([]string, 0)
+
+	for index := 0; index < previous.NumField(); index++ {
+		field := previous.Type().Field(index)
+		key := prefix + strings.Split(field.Tag.Get("yaml"), ",")[0]
+
+		if field.Type.Kind() == reflect.Struct {
+			keys = append(keys, changedKeys(previous.Field(index), current.Field(index), key+".")...)
+			continue
+		}
+
+		if !reflect.DeepEqual(previous.Field(index).Interface(), current.Field(index).Interface()) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
@@ -1,11 +1,13 @@
 package config
 
 import (
-	"flag"
+	"errors"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"strconv"
 	"time"
 
 	"github.com/caarlos0/env/v6"
@@ -13,149 +15,376 @@ import (
 )
 
 const (
-	defaultHTTPPort         = "8000"
-	defaultHTTPRWTimeout    = 10 * time.Second
-	defaultRateLimiterRPS   = 10
-	defaultRateLimiterBurst = 2
-	defaultRateLimiterTTL   = 10 * time.Minute
-	defaultStateDebugMode   = true
+	defaultHTTPPort            = "8000"
+	defaultHTTPReadTimeout     = 10 * time.Second
+	defaultHTTPShutdownTimeout = 5 * time.Second
+	defaultRateLimiterRPS      = 10
+	defaultRateLimiterBurst    = 2
+	defaultRateLimiterTTL      = 10 * time.Minute
+	defaultStateDebugMode      = true
+
+	defaultDatabaseConnectAttempts   = 5
+	defaultDatabaseConnectTimeout    = 5 * time.Second
+	defaultDatabaseConnectRetryDelay = 5 * time.Second
+
+	defaultSignatureAlgorithmJWT = "HS256"
+	defaultSecretKeyJWT          = "default-key"
+	defaultExpiryInJWT           = 10 * time.Minute
+	defaultExpiryInRefreshToken  = 30 * 24 * time.Hour
+
+	defaultAccrualSystemAddress   = "http://127.0.0.1:8081"
+	defaultAccrualPollInterval    = 1 * time.Second
+	defaultAccrualPollBatchSize   = 50
+	defaultAccrualPollWorkers     = 4
+	defaultAccrualMaxPollBackoff  = 1 * time.Minute
+	defaultAccrualMaxPollAttempts = 1440
+	defaultAccrualRequestTimeout  = 2 * time.Second
+
+	defaultIdempotencyKeyTTL = 24 * time.Hour
+	defaultIdempotencyLease  = time.Minute
+
+	defaultWebhookDispatchInterval = 1 * time.Second
+	defaultWebhookMaxAttempts      = 10
+	defaultWebhookMaxBackoff       = 1 * time.Hour
+	defaultWebhookTimeout          = 5 * time.Second
+
+	defaultExpirationInterval = 1 * time.Hour
+	defaultExpiringSoonPeriod = 30 * 24 * time.Hour
+
+	defaultAdminPort = "9090"
+
+	defaultHealthAccrualCheckInterval = 10 * time.Second
+
+	defaultReversalNegativeBalancePolicy = "block"
+
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+
+	EnvironmentDevelopment = "development"
+	EnvironmentProduction  = "production"
+
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+
+	logLevelDebug = "debug"
+	logLevelInfo  = "info"
 )
 
+var ErrNoDatabaseDSN = errors.New("postgresql.dsn or postgresql.username and postgresql.password are required")
+
+// Config is filled in layers: defaults, the config file, the environment and the flags, each next layer wins.
+// The env tags name the variables of the environment, the yaml tags - the keys of the config file.
 type (
 	Config struct {
-		HTTP           HTTPConfig
-		Postgresql     PostgresqlConfig
-		IncomingParams IncomingParams
-		Limiter        LimiterConfig
-		JWT            JWTConfig
-	}
-
-	IncomingParams struct {
-		ServerAddress         string        `env:"RUN_ADDRESS"`
-		DatabaseDSN           string        `env:"DATABASE_URI"`
-		AccrualSystemAddress  string        `env:"ACCRUAL_SYSTEM_ADDRESS"`
-		IsDebugModeEnabled    bool          `env:"DEBUG_ENABLED"`
-		SignatureAlgorithmJWT string        `env:"ALG_JWT"`
-		SecretKeyJWT          string        `env:"SECRET_KEY_JWT"`
-		ExpiryInJWT           time.Duration `env:"EXPIRY_JWT"`
+		// Environment - development or production; production forbids the unsafe defaults, e.g. the default key of jwt
+		Environment string            `env:"APP_ENV" yaml:"environment"`
+		HTTP        HTTPConfig        `yaml:"http"`
+		Postgresql  PostgresqlConfig  `yaml:"postgresql"`
+		Log         LogConfig         `yaml:"log"`
+		Limiter     LimiterConfig     `yaml:"limiter"`
+		JWT         JWTConfig         `yaml:"jwt"`
+		Accrual     AccrualConfig     `yaml:"accrual"`
+		Ledger      LedgerConfig      `yaml:"ledger"`
+		Idempotency IdempotencyConfig `yaml:"idempotency"`
+		Admin       AdminConfig       `yaml:"admin"`
+		Health      HealthConfig      `yaml:"health"`
+		Storage     StorageConfig     `yaml:"storage"`
+		Webhook     WebhookConfig     `yaml:"webhook"`
+		Expiration  ExpirationConfig  `yaml:"expiration"`
+		Bonus       BonusConfig       `yaml:"bonus"`
+		Reversal    ReversalConfig    `yaml:"reversal"`
 	}
 
 	PostgresqlConfig struct {
-		DSN      string
-		Username string
-		Password string
-		Host     string
-		Port     string
-		Database string
+		DSN string `env:"DATABASE_URI" yaml:"dsn"`
+		// Username, Password, Host, Port, Database - the parts of the DSN, they are used when the DSN is empty
+		Username string `yaml:"username"`
+		Password string `yaml:"password"`
+		Host     string `yaml:"host"`
+		Port     string `yaml:"port"`
+		Database string `yaml:"database"`
+		// ConnectAttempts - the service gives up connecting to the database after so many attempts
+		ConnectAttempts   int           `env:"DATABASE_CONNECT_ATTEMPTS" yaml:"connect_attempts"`
+		ConnectTimeout    time.Duration `env:"DATABASE_CONNECT_TIMEOUT" yaml:"connect_timeout"`
+		ConnectRetryDelay time.Duration `env:"DATABASE_CONNECT_RETRY_DELAY" yaml:"connect_retry_delay"`
+		// AutoMigrate - apply new migrations on start; without it they are applied by `gophermart migrate up`.
+		// The environment turns it off with DISABLE_AUTO_MIGRATE.
+		AutoMigrate bool `yaml:"auto_migrate"`
 	}
 
 	HTTPConfig struct {
-		Address            string
-		ReadTimeout        time.Duration
-		WriteTimeout       time.Duration
-		MaxHeaderMegabytes int
+		Address     string        `env:"RUN_ADDRESS" yaml:"address"`
+		ReadTimeout time.Duration `env:"HTTP_READ_TIMEOUT" yaml:"read_timeout"`
+		// WriteTimeout - 0 means no timeout; a timeout cuts the streams of /api/user/events
+		WriteTimeout    time.Duration `env:"HTTP_WRITE_TIMEOUT" yaml:"write_timeout"`
+		MaxHeaderBytes  int           `env:"HTTP_MAX_HEADER_BYTES" yaml:"max_header_bytes"`
+		ShutdownTimeout time.Duration `env:"HTTP_SHUTDOWN_TIMEOUT" yaml:"shutdown_timeout"`
 	}
 
+	LogConfig struct {
+		// Debug - show additional logs, it is the debug level when Level is empty
+		Debug bool `env:"DEBUG_ENABLED" yaml:"debug"`
+		// Level - debug, info, warn or error; it wins over Debug
+		Level string `env:"LOG_LEVEL" yaml:"level"`
+		// Format - text or json
+		Format string `env:"LOG_FORMAT" yaml:"format"`
+	}
+
+	// LimiterConfig - limit of requests to the API from one client address
 	LimiterConfig struct {
-		RPS   int
-		Burst int
-		TTL   time.Duration
+		RPS   int `env:"RATE_LIMIT_RPS" yaml:"rps"`
+		Burst int `env:"RATE_LIMIT_BURST" yaml:"burst"`
+		// TTL - the limiter of a client is forgotten when the client makes no requests so long
+		TTL time.Duration `env:"RATE_LIMIT_TTL" yaml:"ttl"`
 	}
 
 	JWTConfig struct {
-		SignatureAlgorithm string
-		SecretKeyJWT       string
-		ExpiryIn           time.Duration
+		SignatureAlgorithm string        `env:"ALG_JWT" yaml:"signature_algorithm"`
+		SecretKeyJWT       string        `env:"SECRET_KEY_JWT" yaml:"secret_key"`
+		PrivateKeyFiles    []string      `env:"PRIVATE_KEY_FILES_JWT" envSeparator:"," yaml:"private_key_files"`
+		ExpiryIn           time.Duration `env:"EXPIRY_JWT" yaml:"expiry_in"`
+		RefreshExpiryIn    time.Duration `env:"EXPIRY_REFRESH_TOKEN" yaml:"refresh_expiry_in"`
 	}
-)
 
-func Init() *Config {
-	var config Config
+	AccrualConfig struct {
+		Address        string        `env:"ACCRUAL_SYSTEM_ADDRESS" yaml:"address"`
+		PollInterval   time.Duration `env:"ACCRUAL_POLL_INTERVAL" yaml:"poll_interval"`
+		PollBatchSize  int           `env:"ACCRUAL_POLL_BATCH_SIZE" yaml:"poll_batch_size"`
+		PollWorkers    int           `env:"ACCRUAL_POLL_WORKERS" yaml:"poll_workers"`
+		MaxPollBackoff time.Duration `env:"ACCRUAL_MAX_POLL_BACKOFF" yaml:"max_poll_backoff"`
+		// MaxPollAttempts - an order without a final status after so many answers of the accrual system becomes INVALID,
+		// with the default backoff it is about a day; failed requests aren't counted, so an outage doesn't make orders INVALID
+		MaxPollAttempts int `env:"ACCRUAL_MAX_POLL_ATTEMPTS" yaml:"max_poll_attempts"`
+		// RequestTimeout - of one request to the accrual system
+		RequestTimeout time.Duration `env:"ACCRUAL_REQUEST_TIMEOUT" yaml:"request_timeout"`
+	}
 
-	fillConfigByEnvironments(&config)
+	LedgerConfig struct {
+		// BalanceFromLedger - calculate the current balance from the transactions instead of the score table
+		BalanceFromLedger bool `env:"BALANCE_FROM_LEDGER" yaml:"balance_from_ledger"`
+	}
 
-	fillConfigByFlags(&config)
+	IdempotencyConfig struct {
+		// TTL - how long the response to a request with Idempotency-Key is replayed
+		TTL time.Duration `env:"IDEMPOTENCY_KEY_TTL" yaml:"ttl"`
+		// Lease - how long a request in progress holds its key, a retry takes over the key after it
+		// (e.g. when the service crashed in the middle of the request)
+		Lease time.Duration `env:"IDEMPOTENCY_KEY_LEASE" yaml:"lease"`
+	}
 
-	config.HTTP = HTTPConfig{
-		Address:            config.IncomingParams.ServerAddress,
-		ReadTimeout:        defaultHTTPRWTimeout,
-		WriteTimeout:       defaultHTTPRWTimeout,
-		MaxHeaderMegabytes: http.DefaultMaxHeaderBytes,
+	AdminConfig struct {
+		// Address - listener for /metrics, it is separated from the public API
+		Address string `env:"ADMIN_ADDRESS" yaml:"address"`
+		// Logins - registered users who get the admin role at the start of the service
+		Logins []string `env:"ADMIN_LOGINS" envSeparator:"," yaml:"logins"`
 	}
 
-	config.Postgresql.DSN = config.IncomingParams.DatabaseDSN
+	HealthConfig struct {
+		// AccrualRequired - /readyz fails instead of reporting degraded state when the accrual system is unreachable
+		AccrualRequired bool `env:"READINESS_REQUIRES_ACCRUAL" yaml:"accrual_required"`
+		// AccrualCheckInterval - how long the result of the accrual check is reused by the following probes
+		AccrualCheckInterval time.Duration `env:"READINESS_ACCRUAL_CHECK_INTERVAL" yaml:"accrual_check_interval"`
+	}
 
-	config.Limiter = LimiterConfig{
-		RPS:   defaultRateLimiterRPS,
-		Burst: defaultRateLimiterBurst,
-		TTL:   defaultRateLimiterTTL,
+	StorageConfig struct {
+		// Type - postgres or memory; memory keeps everything in the process and needs no database
+		Type string `env:"STORAGE" yaml:"type"`
 	}
 
-	config.JWT = JWTConfig{
-		SignatureAlgorithm: config.IncomingParams.SignatureAlgorithmJWT,
-		SecretKeyJWT:       config.IncomingParams.SecretKeyJWT,
-		ExpiryIn:           config.IncomingParams.ExpiryInJWT,
+	WebhookConfig struct {
+		// DispatchInterval - how often the outbox is drained, it is also the delay before the first retry
+		DispatchInterval time.Duration `env:"WEBHOOK_DISPATCH_INTERVAL" yaml:"dispatch_interval"`
+		// MaxAttempts - after so many failed attempts the delivery is moved to the dead letters
+		MaxAttempts int           `env:"WEBHOOK_MAX_ATTEMPTS" yaml:"max_attempts"`
+		MaxBackoff  time.Duration `env:"WEBHOOK_MAX_BACKOFF" yaml:"max_backoff"`
+		// Timeout - of one request to the webhook
+		Timeout time.Duration `env:"WEBHOOK_TIMEOUT" yaml:"timeout"`
+		// AllowPrivateNetworks - let webhooks point to loopback, private and link-local addresses, e.g. for local development
+		AllowPrivateNetworks bool `env:"WEBHOOK_ALLOW_PRIVATE_NETWORKS" yaml:"allow_private_networks"`
 	}
 
-	return &config
-}
+	ExpirationConfig struct {
+		// Months - points expire so many months after they were earned; 0 - points never expire
+		Months int `env:"POINTS_EXPIRATION_MONTHS" yaml:"months"`
+		// Interval - how often expired points are written off
+		Interval time.Duration `env:"POINTS_EXPIRATION_INTERVAL" yaml:"interval"`
+		// SoonPeriod - points expiring within this period are shown in the balance as expiring soon
+		SoonPeriod time.Duration `env:"POINTS_EXPIRING_SOON_PERIOD" yaml:"soon_period"`
+	}
 
-func fillConfigByEnvironments(config *Config) {
-	errEnv := env.Parse(&config.IncomingParams)
-	if errEnv != nil {
-		log.Println("=== ERROR FILLING FROM ENVIRONMENT", errEnv)
+	BonusConfig struct {
+		// RulesFile - JSON file with the bonus rules; empty - only the accrual of the accrual system is credited
+		RulesFile string `env:"BONUS_RULES_FILE" yaml:"rules_file"`
 	}
-}
 
-func fillConfigByFlags(config *Config) {
-	flagSet := pflag.FlagSet{}
+	ReversalConfig struct {
+		// NegativeBalancePolicy - what a reversal of spent points does: block - refuses it;
+		// debt - lets the balance become negative; clamp - debits only what is left on the balance
+		NegativeBalancePolicy string `env:"REVERSAL_NEGATIVE_BALANCE_POLICY" yaml:"negative_balance_policy"`
+	}
+)
 
-	address := flagSet.StringP("http-address", "a", ":"+defaultHTTPPort, "address for listening via server")
-	databaseDSN := flagSet.StringP("db-dsn", "d", "", "DSN of database")
-	accrualAddress := flagSet.StringP("accrual-system-address", "r", "http://127.0.0.1:8081", "address of the accrual system")
+// InitWithFlagSet parses args with flagSet, so a command can register its own flags beside the flags of the config.
+// The config file is given by -c or CONFIG_FILE. The error tells what is wrong with every invalid setting.
+func InitWithFlagSet(flagSet *pflag.FlagSet, args []string) (*Config, error) {
+	configFile, errFlags := parseFlags(flagSet, args)
+	if errFlags != nil {
+		return nil, fmt.Errorf("flags: %w", errFlags)
+	}
 
-	signatureAlgorithmJWT := flagSet.StringP("signature-alg-jwt", "g", "HS256", "signature algorithm for jwt")
-	secretKeyJWT := flagSet.StringP("secret-key-jwt", "k", "default-key", "secret key for authentificate client")
-	expiryInJWT := flagSet.DurationP("expiry-in-jwt", "e", 10*time.Minute, "expiry in for jwt")
+	config := newDefaultConfig()
 
-	flagSet.BoolVar(&config.IncomingParams.IsDebugModeEnabled, "debug-mode-enabled", defaultStateDebugMode, "show additional logs")
+	if !flagSet.Changed(configFileFlag) {
+		configFile = os.Getenv("CONFIG_FILE")
+	}
 
-	flagSet.Usage = func() {
-		_, err := fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n")
-		if err != nil {
-			return
+	if len(configFile) > 0 {
+		errFile := fillConfigByFile(config, configFile)
+		if errFile != nil {
+			return nil, errFile
 		}
+	}
+
+	errEnv := fillConfigByEnvironments(config)
+	if errEnv != nil {
+		return nil, fmt.Errorf("environment: %w", errEnv)
+	}
+
+	errOverride := fillConfigByFlags(config, flagSet)
+	if errOverride != nil {
+		return nil, fmt.Errorf("flags: %w", errOverride)
+	}
+
+	errValidate := config.Validate()
+	if errValidate != nil {
+		return nil, errValidate
+	}
 
-		flagSet.PrintDefaults()
-		os.Exit(0)
+	return config, nil
+}
+
+func newDefaultConfig() *Config {
+	return &Config{
+		Environment: EnvironmentDevelopment,
+		HTTP: HTTPConfig{
+			Address:         ":" + defaultHTTPPort,
+			ReadTimeout:     defaultHTTPReadTimeout,
+			MaxHeaderBytes:  http.DefaultMaxHeaderBytes,
+			ShutdownTimeout: defaultHTTPShutdownTimeout,
+		},
+		Postgresql: PostgresqlConfig{
+			ConnectAttempts:   defaultDatabaseConnectAttempts,
+			ConnectTimeout:    defaultDatabaseConnectTimeout,
+			ConnectRetryDelay: defaultDatabaseConnectRetryDelay,
+			AutoMigrate:       true,
+		},
+		Log: LogConfig{
+			Debug:  defaultStateDebugMode,
+			Format: LogFormatText,
+		},
+		Limiter: LimiterConfig{
+			RPS:   defaultRateLimiterRPS,
+			Burst: defaultRateLimiterBurst,
+			TTL:   defaultRateLimiterTTL,
+		},
+		JWT: JWTConfig{
+			SignatureAlgorithm: defaultSignatureAlgorithmJWT,
+			SecretKeyJWT:       defaultSecretKeyJWT,
+			ExpiryIn:           defaultExpiryInJWT,
+			RefreshExpiryIn:    defaultExpiryInRefreshToken,
+		},
+		Accrual: AccrualConfig{
+			Address:         defaultAccrualSystemAddress,
+			PollInterval:    defaultAccrualPollInterval,
+			PollBatchSize:   defaultAccrualPollBatchSize,
+			PollWorkers:     defaultAccrualPollWorkers,
+			MaxPollBackoff:  defaultAccrualMaxPollBackoff,
+			MaxPollAttempts: defaultAccrualMaxPollAttempts,
+			RequestTimeout:  defaultAccrualRequestTimeout,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:   defaultIdempotencyKeyTTL,
+			Lease: defaultIdempotencyLease,
+		},
+		Admin: AdminConfig{
+			Address: ":" + defaultAdminPort,
+		},
+		Health: HealthConfig{
+			AccrualCheckInterval: defaultHealthAccrualCheckInterval,
+		},
+		Storage: StorageConfig{
+			Type: StoragePostgres,
+		},
+		Webhook: WebhookConfig{
+			DispatchInterval: defaultWebhookDispatchInterval,
+			MaxAttempts:      defaultWebhookMaxAttempts,
+			MaxBackoff:       defaultWebhookMaxBackoff,
+			Timeout:          defaultWebhookTimeout,
+		},
+		Expiration: ExpirationConfig{
+			Interval:   defaultExpirationInterval,
+			SoonPeriod: defaultExpiringSoonPeriod,
+		},
+		Reversal: ReversalConfig{
+			NegativeBalancePolicy: defaultReversalNegativeBalancePolicy,
+		},
 	}
+}
 
-	errFlag := flagSet.Parse(os.Args[1:])
-	if errFlag != nil {
-		log.Printf("---> ERROR: failed flag parse: %+v; Args: %v\n", errFlag, os.Args)
+// fillConfigByEnvironments overrides the settings whose variables are set and not empty.
+func fillConfigByEnvironments(config *Config) error {
+	errEnv := env.Parse(config)
+	if errEnv != nil {
+		return errEnv
 	}
 
-	if config.IncomingParams.ServerAddress == "" {
-		config.IncomingParams.ServerAddress = *address
+	if disableAutoMigrate := os.Getenv("DISABLE_AUTO_MIGRATE"); len(disableAutoMigrate) > 0 {
+		isDisabled, errParse := strconv.ParseBool(disableAutoMigrate)
+		if errParse != nil {
+			return fmt.Errorf(`DISABLE_AUTO_MIGRATE must be true or false, got "%v"`, disableAutoMigrate)
+		}
+
+		config.Postgresql.AutoMigrate = !isDisabled
 	}
 
-	if config.IncomingParams.DatabaseDSN == "" {
-		config.IncomingParams.DatabaseDSN = *databaseDSN
+	return nil
+}
+
+// LevelName returns the level of the log: Level when it is set, otherwise debug or info by Debug.
+func (config LogConfig) LevelName() string {
+	if len(config.Level) > 0 {
+		return config.Level
+	}
+
+	if config.Debug {
+		return logLevelDebug
 	}
 
-	if config.IncomingParams.AccrualSystemAddress == "" {
-		config.IncomingParams.AccrualSystemAddress = *accrualAddress
+	return logLevelInfo
+}
+
+// ConnectionString returns the DSN, or builds it from the parts when it is empty. The database client
+// and the migrations use it, so both connect to the same database.
+func (cfg PostgresqlConfig) ConnectionString() (string, error) {
+	if len(cfg.DSN) > 0 {
+		return cfg.DSN, nil
 	}
 
-	if config.IncomingParams.SignatureAlgorithmJWT == "" {
-		config.IncomingParams.SignatureAlgorithmJWT = *signatureAlgorithmJWT
+	if len(cfg.Username) == 0 || len(cfg.Password) == 0 {
+		return "", ErrNoDatabaseDSN
 	}
 
-	if config.IncomingParams.SecretKeyJWT == "" {
-		config.IncomingParams.SecretKeyJWT = *secretKeyJWT
+	host := cfg.Host
+	if len(cfg.Port) > 0 {
+		host = net.JoinHostPort(cfg.Host, cfg.Port)
 	}
 
-	if config.IncomingParams.ExpiryInJWT == 0 {
-		config.IncomingParams.ExpiryInJWT = *expiryInJWT
+	dsn := url.URL{
+		Scheme: "postgresql",
+		User:   url.UserPassword(cfg.Username, cfg.Password),
+		Host:   host,
+		Path:   "/" + cfg.Database,
 	}
+
+	return dsn.String(), nil
 }
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+)
+
+// fillConfigByFile overrides the settings present in the YAML file. Unknown keys are errors,
+// so a misspelt setting doesn't silently keep its default. Durations are written like 10s or 1h30m.
+func fillConfigByFile(config *Config, path string) error {
+	content, errRead := os.ReadFile(path)
+	if errRead != nil {
+		return fmt.Errorf("config file: %w", errRead)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(content))
+	decoder.KnownFields(true)
+
+	errDecode := decoder.Decode(config)
+	if errDecode != nil && !errors.Is(errDecode, io.EOF) {
+		return fmt.Errorf("config file %v: %w", path, errDecode)
+	}
+
+	return nil
+}
+
+// Encode writes config in the format of the config file, so the output can be used as the file.
+func (config *Config) Encode(writer io.Writer) error {
+	encoder := yaml.NewEncoder(writer)
+	encoder.SetIndent(2)
+
+	errEncode := encoder.Encode(config)
+	if errEncode != nil {
+		return errEncode
+	}
+
+	return encoder.Close()
+}
+
+// Redacted returns a copy of config without secrets, it can be shown or logged.
+func (config *Config) Redacted() *Config {
+	redacted := *config
+
+	redacted.Postgresql.DSN = utils.Redact(config.Postgresql.DSN)
+
+	if len(config.Postgresql.Password) > 0 {
+		redacted.Postgresql.Password = utils.RedactedValue
+	}
+
+	if len(config.JWT.SecretKeyJWT) > 0 {
+		redacted.JWT.SecretKeyJWT = utils.RedactedValue
+	}
+
+	return &redacted
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/spf13/pflag"
+)
+
+const configFileFlag = "config"
+
+// parseFlags parses args and returns the path of the config file. The flags of the config are bound
+// to a config of their own, fillConfigByFlags copies the given ones over the other layers.
+func parseFlags(flagSet *pflag.FlagSet, args []string) (string, error) {
+	configFile := flagSet.StringP(configFileFlag, "c", "", "YAML file of the configuration, CONFIG_FILE by default")
+
+	bindFlags(flagSet, newDefaultConfig())
+
+	flagSet.Usage = func() {
+		_, err := fmt.Fprintf(flag.CommandLine.Output(), "Usage:\n")
+		if err != nil {
+			return
+		}
+
+		flagSet.PrintDefaults()
+		os.Exit(0)
+	}
+
+	errFlag := flagSet.Parse(args)
+	if errFlag != nil {
+		return "", errFlag
+	}
+
+	return *configFile, nil
+}
+
+func bindFlags(flagSet *pflag.FlagSet, config *Config) {
+	flagSet.StringVar(&config.Environment, "environment", config.Environment, "development or production")
+	flagSet.StringVarP(&config.HTTP.Address, "http-address", "a", config.HTTP.Address, "address for listening via server")
+	flagSet.StringVar(&config.Storage.Type, "storage", config.Storage.Type, "storage backend: postgres or memory")
+	flagSet.StringVarP(&config.Postgresql.DSN, "db-dsn", "d", config.Postgresql.DSN, "DSN of database")
+	flagSet.StringVar(&config.Admin.Address, "admin-address", config.Admin.Address, "address for listening via admin server with metrics")
+	flagSet.StringVarP(&config.Accrual.Address, "accrual-system-address", "r", config.Accrual.Address, "address of the accrual system")
+
+	flagSet.StringVarP(&config.JWT.SignatureAlgorithm, "signature-alg-jwt", "g", config.JWT.SignatureAlgorithm, "signature algorithm for jwt")
+	flagSet.StringVarP(&config.JWT.SecretKeyJWT, "secret-key-jwt", "k", config.JWT.SecretKeyJWT, "secret key for authentificate client")
+	flagSet.StringSliceVar(&config.JWT.PrivateKeyFiles, "private-key-files-jwt", config.JWT.PrivateKeyFiles, "PEM private keys for jwt, the first one signs")
+	flagSet.DurationVarP(&config.JWT.ExpiryIn, "expiry-in-jwt", "e", config.JWT.ExpiryIn, "expiry in for jwt")
+	flagSet.DurationVar(&config.JWT.RefreshExpiryIn, "expiry-in-refresh-token", config.JWT.RefreshExpiryIn, "expiry in for refresh token")
+
+	flagSet.BoolVar(&config.Log.Debug, "debug-mode-enabled", config.Log.Debug, "show additional logs")
+	flagSet.StringVar(&config.Log.Level, "log-level", config.Log.Level, "level of the log: debug, info, warn or error")
+	flagSet.StringVar(&config.Log.Format, "log-format", config.Log.Format, "format of the log: text or json")
+	flagSet.VarPF(
+		(*invertedBool)(&config.Postgresql.AutoMigrate),
+		"disable-auto-migrate",
+		"",
+		"don't apply migrations on start",
+	).NoOptDefVal = "true"
+}
+
+// fillConfigByFlags copies the flags given in the command line into config.
+func fillConfigByFlags(config *Config, flagSet *pflag.FlagSet) error {
+	targets := &pflag.FlagSet{}
+	bindFlags(targets, config)
+
+	var errSet error
+
+	flagSet.Visit(func(given *pflag.Flag) {
+		target := targets.Lookup(given.Name)
+		if target == nil || errSet != nil {
+			return
+		}
+
+		if slice, isSlice := given.Value.(pflag.SliceValue); isSlice {
+			errSet = target.Value.(pflag.SliceValue).Replace(slice.GetSlice())
+			return
+		}
+
+		errSet = target.Value.Set(given.Value.String())
+	})
+
+	return errSet
+}
+
+// invertedBool - a flag which turns off the setting, e.g. --disable-auto-migrate.
+type invertedBool bool
+
+func (value *invertedBool) Set(raw string) error {
+	parsed, errParse := strconv.ParseBool(raw)
+	if errParse != nil {
+		return errParse
+	}
+
+	*value = invertedBool(!parsed)
+
+	return nil
+}
+
+func (value *invertedBool) String() string {
+	return strconv.FormatBool(!bool(*value))
+}
+
+func (value *invertedBool) Type() string {
+	return "bool"
+}
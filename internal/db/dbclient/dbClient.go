@@ -13,3 +13,9 @@ type ClientInterface interface {
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
 	Begin(ctx context.Context) (pgx.Tx, error)
 }
+
+// PoolInterface is the pool of connections, unlike a transaction it can be pinged.
+type PoolInterface interface {
+	ClientInterface
+	Ping(ctx context.Context) error
+}
@@ -3,8 +3,6 @@ package postgresql
 import (
 	"context"
 	"errors"
-	"fmt"
-	"time"
 
 	"github.com/jackc/pgx/v4/pgxpool"
 
@@ -13,33 +11,23 @@ import (
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 )
 
-func NewClient(ctx context.Context, maxAttempts int, config config.PostgresqlConfig, logger logger.Logger) (*pgxpool.Pool, error) {
-	var dsn string
-
+// NewClient connects to the database, it makes config.ConnectAttempts attempts before giving up.
+func NewClient(ctx context.Context, config config.PostgresqlConfig, logger logger.Logger) (*pgxpool.Pool, error) {
 	logger.Info("=== GET DSN ...")
 
-	switch {
-	case config.DSN != "":
-		dsn = config.DSN
-	case config.Username != "" && config.Password != "":
-		dsn = fmt.Sprintf("postgresql://%s:%s@%s:%s/%s",
-			config.Username,
-			config.Password,
-			config.Host,
-			config.Port,
-			config.Database)
-	default:
-		return nil, errors.New("data for connect not found")
+	dsn, errDSN := config.ConnectionString()
+	if errDSN != nil {
+		return nil, errDSN
 	}
 
-	logger.Infof("=== DSN: %v", dsn)
+	logger.Infof("=== DSN: %v", utils.Redact(dsn))
 
 	var pool *pgxpool.Pool
 
 	errDoWithTries := utils.DoWithTries(func() error {
 		logger.Info("=== Getting a connect to db ...")
 
-		ctxTimeout, cancelCtxTimeout := context.WithTimeout(ctx, 5*time.Second)
+		ctxTimeout, cancelCtxTimeout := context.WithTimeout(ctx, config.ConnectTimeout)
 		defer cancelCtxTimeout()
 
 		var err error
@@ -52,7 +40,7 @@ func NewClient(ctx context.Context, maxAttempts int, config config.PostgresqlCon
 		logger.Info("=== Getting a connect to db - done")
 
 		return nil
-	}, maxAttempts, 5*time.Second)
+	}, config.ConnectAttempts, config.ConnectRetryDelay)
 
 	if errDoWithTries != nil {
 		logger.Error("---> ERROR at the time connecting:", errDoWithTries)
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS public.IDX_POLLING_ORDERS;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS is_withdrawal;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS next_poll_at;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS answered_polls;
+ALTER TABLE public.orders DROP COLUMN IF EXISTS poll_attempts;
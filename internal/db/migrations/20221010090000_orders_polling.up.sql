@@ -0,0 +1,13 @@
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS poll_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS answered_polls INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS next_poll_at TIMESTAMP NULL;
+ALTER TABLE public.orders ADD COLUMN IF NOT EXISTS is_withdrawal BOOLEAN NOT NULL DEFAULT false;
+COMMENT ON COLUMN orders.answered_polls IS 'Polls which the accrual system has answered without the final status, failed requests are not counted';
+COMMENT ON COLUMN orders.next_poll_at IS 'Time of the next request to the accrual system; NULL - as soon as possible';
+COMMENT ON COLUMN orders.is_withdrawal IS 'Order created by a withdrawal, the accrual system has no points for it and it is not polled';
+CREATE INDEX IF NOT EXISTS IDX_POLLING_ORDERS ON public.orders (status, next_poll_at);
+-- the orders which are still polled and have a withdrawal, but no accrual, were created by withdrawals
+UPDATE public.orders SET is_withdrawal = true
+WHERE status IN ('NEW', 'PROCESSING')
+  AND id IN (SELECT order_id FROM public.transactions WHERE type = 2)
+  AND id NOT IN (SELECT order_id FROM public.transactions WHERE type = 1);
@@ -0,0 +1,3 @@
+ALTER TABLE public.transactions ALTER COLUMN points TYPE NUMERIC(8, 2);
+ALTER TABLE public.score ALTER COLUMN total TYPE NUMERIC(8, 2);
+ALTER TABLE public.orders ALTER COLUMN points TYPE NUMERIC(8, 2);
@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS TRG_TRANSACTIONS_APPEND_ONLY ON public.transactions;
+DROP FUNCTION IF EXISTS public.forbid_transactions_update();
+DROP INDEX IF EXISTS public.IDX_USER_SEQ_TRANSACTIONS;
+DROP INDEX IF EXISTS public.IDX_USER_CREATEDAT_TRANSACTIONS;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS seq;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS balance_after;
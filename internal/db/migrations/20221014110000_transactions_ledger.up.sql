@@ -0,0 +1,33 @@
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS balance_after NUMERIC(16, 2) NOT NULL DEFAULT 0;
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+COMMENT ON COLUMN transactions.balance_after IS 'Balance of the user after this transaction';
+COMMENT ON COLUMN transactions.seq IS 'Order of the ledger, balance_after continues the previous transaction of the user by seq';
+
+UPDATE public.transactions AS t SET (seq, balance_after) = (ledger.seq, ledger.balance)
+FROM (
+    SELECT id,
+        ROW_NUMBER() OVER (ORDER BY created_at, id) AS seq,
+        SUM(CASE WHEN type = 2 THEN -points ELSE points END)
+            OVER (PARTITION BY user_id ORDER BY created_at, id) AS balance
+    FROM public.transactions
+) AS ledger
+WHERE ledger.id = t.id;
+
+SELECT setval(
+    pg_get_serial_sequence('public.transactions', 'seq'),
+    (SELECT COALESCE(MAX(seq), 0) + 1 FROM public.transactions),
+    false
+);
+
+CREATE INDEX IF NOT EXISTS IDX_USER_CREATEDAT_TRANSACTIONS ON public.transactions (user_id, created_at);
+CREATE INDEX IF NOT EXISTS IDX_USER_SEQ_TRANSACTIONS ON public.transactions (user_id, seq);
+
+CREATE OR REPLACE FUNCTION public.forbid_transactions_update() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'transactions is an append-only ledger, write a compensating transaction instead';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER TRG_TRANSACTIONS_APPEND_ONLY
+    BEFORE UPDATE ON public.transactions
+    FOR EACH ROW EXECUTE FUNCTION public.forbid_transactions_update();
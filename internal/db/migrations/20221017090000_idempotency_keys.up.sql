@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS public.idempotency_keys (
+    user_id UUID NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_method VARCHAR(10) NOT NULL,
+    request_path VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status_code INTEGER NULL,
+    content_type VARCHAR(255) NULL,
+    response_body BYTEA NULL,
+    created_at TIMESTAMP NOT NULL,
+    lease_until TIMESTAMP NULL,
+    committed_at TIMESTAMP NULL,
+    PRIMARY KEY (user_id, key),
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+COMMENT ON COLUMN idempotency_keys.status_code IS 'NULL while the first request with this key is in progress';
+COMMENT ON COLUMN idempotency_keys.lease_until IS 'The request in progress holds the key until the time, a retry takes the key over after it';
+COMMENT ON COLUMN idempotency_keys.committed_at IS 'The request has committed its changes, the key is never freed or taken over after it';
+CREATE INDEX IF NOT EXISTS IDX_CREATEDAT_IDEMPOTENCY_KEYS ON public.idempotency_keys (created_at);
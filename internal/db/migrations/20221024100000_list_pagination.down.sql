@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS public.IDX_USER_TYPE_CREATEDAT_ID_TRANSACTIONS;
+DROP INDEX IF EXISTS public.IDX_USER_CREATEDAT_ID_ORDERS;
@@ -0,0 +1,2 @@
+CREATE INDEX IF NOT EXISTS IDX_USER_CREATEDAT_ID_ORDERS ON public.orders (user_id, created_at, id);
+CREATE INDEX IF NOT EXISTS IDX_USER_TYPE_CREATEDAT_ID_TRANSACTIONS ON public.transactions (user_id, type, created_at, id);
@@ -0,0 +1,4 @@
+DROP TABLE IF EXISTS public.webhook_deliveries;
+DROP TYPE IF EXISTS status_webhook_delivery;
+DROP TABLE IF EXISTS public.outbox_events;
+DROP TABLE IF EXISTS public.webhooks;
@@ -0,0 +1,39 @@
+CREATE TABLE IF NOT EXISTS public.webhooks (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    url VARCHAR(2048) NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IDX_USER_WEBHOOKS ON public.webhooks (user_id);
+
+CREATE TABLE IF NOT EXISTS public.outbox_events (
+    id UUID PRIMARY KEY,
+    user_id UUID NOT NULL,
+    type VARCHAR(64) NOT NULL,
+    data JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    dispatched_at TIMESTAMP,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+COMMENT ON COLUMN outbox_events.dispatched_at IS 'Deliveries to the webhooks of the user are created';
+CREATE INDEX IF NOT EXISTS IDX_UNDISPATCHED_OUTBOX_EVENTS ON public.outbox_events (created_at) WHERE dispatched_at IS NULL;
+
+CREATE TYPE status_webhook_delivery AS ENUM ('PENDING', 'DELIVERED', 'DEAD');
+CREATE TABLE IF NOT EXISTS public.webhook_deliveries (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    event_id UUID NOT NULL,
+    webhook_id UUID NOT NULL,
+    status status_webhook_delivery NOT NULL DEFAULT 'PENDING',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_status_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (event_id) REFERENCES outbox_events (id) ON DELETE CASCADE,
+    FOREIGN KEY (webhook_id) REFERENCES webhooks (id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS IDX_PENDING_WEBHOOK_DELIVERIES ON public.webhook_deliveries (status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS IDX_WEBHOOK_WEBHOOK_DELIVERIES ON public.webhook_deliveries (webhook_id);
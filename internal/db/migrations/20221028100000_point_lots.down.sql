@@ -0,0 +1,5 @@
+-- expiration transactions stay in the append-only ledger
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease';
+DROP INDEX IF EXISTS public.IDX_EARNEDAT_POINT_LOTS;
+DROP INDEX IF EXISTS public.IDX_USER_EARNEDAT_POINT_LOTS;
+DROP TABLE IF EXISTS public.point_lots;
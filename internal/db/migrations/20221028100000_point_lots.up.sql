@@ -0,0 +1,33 @@
+CREATE TABLE IF NOT EXISTS public.point_lots (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    user_id UUID NOT NULL,
+    order_id UUID NOT NULL,
+    points NUMERIC(16, 2) NOT NULL,
+    remaining NUMERIC(16, 2) NOT NULL,
+    earned_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE,
+    CONSTRAINT CHK_REMAINING_POINT_LOTS CHECK (remaining >= 0 AND remaining <= points)
+);
+COMMENT ON TABLE point_lots IS 'Accrued points: withdrawals consume the oldest lots first, the rest expires';
+COMMENT ON COLUMN point_lots.remaining IS 'Points of the lot which are neither withdrawn nor expired';
+
+CREATE INDEX IF NOT EXISTS IDX_USER_EARNEDAT_POINT_LOTS ON public.point_lots (user_id, earned_at, id) WHERE remaining > 0;
+CREATE INDEX IF NOT EXISTS IDX_EARNEDAT_POINT_LOTS ON public.point_lots (earned_at) WHERE remaining > 0;
+
+-- a lot for every accrual of the ledger, the withdrawals of the user have consumed the oldest ones
+INSERT INTO public.point_lots (user_id, order_id, points, remaining, earned_at, updated_at)
+SELECT accruals.user_id, accruals.order_id, accruals.points,
+    LEAST(accruals.points, GREATEST(0, accruals.running_total - COALESCE(withdrawals.total, 0))),
+    accruals.created_at, accruals.created_at
+FROM (
+    SELECT user_id, order_id, points, created_at,
+        SUM(points) OVER (PARTITION BY user_id ORDER BY created_at, id) AS running_total
+    FROM public.transactions
+    WHERE type = 1
+) AS accruals
+LEFT JOIN (
+    SELECT user_id, SUM(points) AS total FROM public.transactions WHERE type = 2 GROUP BY user_id
+) AS withdrawals ON withdrawals.user_id = accruals.user_id;
+
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration';
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS public.IDX_USER_RULE_CREATEDAT_TRANSACTIONS;
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration';
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS rule_id;
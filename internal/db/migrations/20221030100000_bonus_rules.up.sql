@@ -0,0 +1,6 @@
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS rule_id VARCHAR(64);
+COMMENT ON COLUMN transactions.rule_id IS 'Bonus rule which has given the points of the transaction';
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration; 4-bonus';
+
+CREATE INDEX IF NOT EXISTS IDX_USER_RULE_CREATEDAT_TRANSACTIONS ON public.transactions (user_id, rule_id, created_at)
+    WHERE rule_id IS NOT NULL;
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS public.user_tiers (
+    user_id UUID PRIMARY KEY,
+    tier VARCHAR(64) NOT NULL,
+    accrued_points NUMERIC(16, 2) NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users (id) ON DELETE CASCADE
+);
+COMMENT ON TABLE user_tiers IS 'Loyalty tier of the user as of the last accrual';
+COMMENT ON COLUMN user_tiers.accrued_points IS 'Points accrued during the last 12 months';
@@ -0,0 +1,16 @@
+-- the ledger is append-only: the rollback stops instead of deleting manual adjustments,
+-- the balances and the lots are built on them
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM public.transactions WHERE type IN (5, 6)) THEN
+        RAISE EXCEPTION 'the ledger has manual adjustments, the migration can''t be rolled back';
+    END IF;
+END $$;
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration; 4-bonus';
+ALTER TABLE public.transactions DROP CONSTRAINT IF EXISTS CHK_ORDER_TRANSACTIONS;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS operator_id;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS reason;
+ALTER TABLE public.transactions ALTER COLUMN order_id SET NOT NULL;
+
+ALTER TABLE public.users DROP COLUMN IF EXISTS blocked_at;
+ALTER TABLE public.users DROP COLUMN IF EXISTS role;
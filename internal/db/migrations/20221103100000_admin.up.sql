@@ -0,0 +1,13 @@
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user';
+ALTER TABLE public.users ADD COLUMN IF NOT EXISTS blocked_at TIMESTAMP;
+COMMENT ON COLUMN users.role IS 'Roles: user; admin - access to the admin API';
+COMMENT ON COLUMN users.blocked_at IS 'Blocked users can neither log in nor refresh tokens';
+
+-- manual adjustments of the balance aren't linked to an order
+ALTER TABLE public.transactions ALTER COLUMN order_id DROP NOT NULL;
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS reason VARCHAR(255);
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS operator_id UUID;
+ALTER TABLE public.transactions ADD CONSTRAINT CHK_ORDER_TRANSACTIONS CHECK (order_id IS NOT NULL OR type IN (5, 6));
+COMMENT ON COLUMN transactions.reason IS 'Reason of the manual adjustment';
+COMMENT ON COLUMN transactions.operator_id IS 'Admin who has made the manual adjustment';
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration; 4-bonus; 5-adjustment credit; 6-adjustment debit';
@@ -0,0 +1,14 @@
+-- the ledger is append-only: the rollback stops instead of deleting reversals,
+-- the balances and the lots are built on them
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM public.transactions WHERE type IN (7, 8)) THEN
+        RAISE EXCEPTION 'the ledger has reversals, the migration can''t be rolled back';
+    END IF;
+END $$;
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration; 4-bonus; 5-adjustment credit; 6-adjustment debit';
+ALTER TABLE public.transactions DROP CONSTRAINT IF EXISTS CHK_REVERSAL_TRANSACTIONS;
+ALTER TABLE public.transactions DROP CONSTRAINT IF EXISTS CHK_ORDER_TRANSACTIONS;
+ALTER TABLE public.transactions ADD CONSTRAINT CHK_ORDER_TRANSACTIONS CHECK (order_id IS NOT NULL OR type IN (5, 6));
+DROP INDEX IF EXISTS UQ_TRANSACTIONS_REVERSAL_OF;
+ALTER TABLE public.transactions DROP COLUMN IF EXISTS reversal_of;
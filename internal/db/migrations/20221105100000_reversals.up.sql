@@ -0,0 +1,8 @@
+-- a reversal keeps the order of the reversed transaction, so reversals of adjustments aren't linked to an order too
+ALTER TABLE public.transactions ADD COLUMN IF NOT EXISTS reversal_of UUID REFERENCES public.transactions (id);
+CREATE UNIQUE INDEX IF NOT EXISTS UQ_TRANSACTIONS_REVERSAL_OF ON public.transactions (reversal_of) WHERE reversal_of IS NOT NULL;
+ALTER TABLE public.transactions DROP CONSTRAINT IF EXISTS CHK_ORDER_TRANSACTIONS;
+ALTER TABLE public.transactions ADD CONSTRAINT CHK_ORDER_TRANSACTIONS CHECK (order_id IS NOT NULL OR type IN (5, 6, 7, 8));
+ALTER TABLE public.transactions ADD CONSTRAINT CHK_REVERSAL_TRANSACTIONS CHECK ((reversal_of IS NOT NULL) = (type IN (7, 8)));
+COMMENT ON COLUMN transactions.reversal_of IS 'Transaction which is compensated by this one, a transaction can be reversed once';
+COMMENT ON COLUMN transactions.type IS 'Type transaction: 1-increase; 2-decrease; 3-expiration; 4-bonus; 5-adjustment credit; 6-adjustment debit; 7-reversal debit; 8-reversal credit';
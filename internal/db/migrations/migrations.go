@@ -0,0 +1,7 @@
+// Package migrations compiles the SQL migrations into the binary, so it doesn't depend on the working directory.
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,8 @@
+package models
+
+// AccrualOrder is a state of the order in the accrual system.
+type AccrualOrder struct {
+	Number string `json:"order,omitempty"`
+	Status string `json:"status,omitempty"`
+	Points Points `json:"accrual,omitempty"`
+}
@@ -0,0 +1,22 @@
+package models
+
+const (
+	HealthStatusUp       = "up"
+	HealthStatusDegraded = "degraded"
+	HealthStatusDown     = "down"
+)
+
+// Health is the result of the readiness check: the overall status is the worst status of the components.
+type Health struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+type ComponentHealth struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func (health *Health) IsReady() bool {
+	return health.Status != HealthStatusDown
+}
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// IdempotencyKey keeps the response to the first request with the key, so retries get the same response.
+// StatusCode is 0 while the first request is in progress, the request holds the key until LeaseUntil.
+// CommittedAt is set by the unit of work of the request in the same transaction as its changes.
+type IdempotencyKey struct {
+	UserID        uuid.UUID `json:"-"`
+	Key           string    `json:"key"`
+	RequestMethod string    `json:"-"`
+	RequestPath   string    `json:"-"`
+	RequestHash   string    `json:"-"`
+	StatusCode    int       `json:"-"`
+	ContentType   string    `json:"-"`
+	ResponseBody  []byte    `json:"-"`
+	CreatedAt     time.Time `json:"-"`
+	LeaseUntil    time.Time `json:"-"`
+	CommittedAt   time.Time `json:"-"`
+}
+
+type idempotencyContextKey struct{}
+
+func (key *IdempotencyKey) IsCompleted() bool {
+	return key.StatusCode != 0
+}
+
+// IsCommitted - the request has committed its changes, so it mustn't be executed again
+func (key *IdempotencyKey) IsCommitted() bool {
+	return !key.CommittedAt.IsZero()
+}
+
+// IsAbandoned - the request in progress hasn't finished within its lease, e.g. the service crashed
+func (key *IdempotencyKey) IsAbandoned(now time.Time) bool {
+	return !key.IsCompleted() && key.LeaseUntil.Before(now)
+}
+
+// WithIdempotencyKey passes the key of the request to the units of work which the request runs.
+func WithIdempotencyKey(ctx context.Context, userID uuid.UUID, key string) context.Context {
+	return context.WithValue(ctx, idempotencyContextKey{}, IdempotencyKey{UserID: userID, Key: key})
+}
+
+// IdempotencyKeyFromContext returns the key of the request, false - the request has no Idempotency-Key.
+func IdempotencyKeyFromContext(ctx context.Context) (IdempotencyKey, bool) {
+	key, ok := ctx.Value(idempotencyContextKey{}).(IdempotencyKey)
+
+	return key, ok
+}
@@ -6,12 +6,33 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	OrderStatusNew        string = "NEW"
+	OrderStatusProcessing string = "PROCESSING"
+	OrderStatusInvalid    string = "INVALID"
+	OrderStatusProcessed  string = "PROCESSED"
+)
+
 type Order struct {
-	ID        uuid.UUID `json:"-"`
-	Number    string    `json:"number,omitempty"`
-	UserID    uuid.UUID `json:"-"`
-	Points    float32   `json:"accrual,omitempty"`
-	Status    string    `json:"status,omitempty"`
-	CreatedAt time.Time `json:"-"`
-	UpdatedAt time.Time `json:"uploaded_at,omitempty"`
+	ID           uuid.UUID `json:"-"`
+	Number       string    `json:"number,omitempty"`
+	UserID       uuid.UUID `json:"-"`
+	Points       Points    `json:"accrual,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	PollAttempts int       `json:"-"`
+	// AnsweredPolls - polls which the accrual system has answered without the final status
+	AnsweredPolls int `json:"-"`
+	// IsWithdrawal - the order was created by a withdrawal, it isn't polled in the accrual system
+	IsWithdrawal bool      `json:"-"`
+	CreatedAt    time.Time `json:"-"`
+	UpdatedAt    time.Time `json:"uploaded_at,omitempty"`
+}
+
+// IsFinalOrderStatus reports whether the accrual system will not change the order any more.
+func IsFinalOrderStatus(status string) bool {
+	return status == OrderStatusInvalid || status == OrderStatusProcessed
+}
+
+func OrderStatuses() []string {
+	return []string{OrderStatusNew, OrderStatusProcessing, OrderStatusInvalid, OrderStatusProcessed}
 }
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	SortAsc  = "asc"
+	SortDesc = "desc"
+
+	MaxPageLimit = 1000
+)
+
+var ErrWrongCursor = errors.New("cursor is malformed")
+
+// Cursor points at the last row of a page: rows are ordered by (created_at, id),
+// so the next page starts right after it even when rows are added meanwhile.
+type Cursor struct {
+	CreatedAt time.Time
+	ID        uuid.UUID
+}
+
+// Encode returns the opaque value of the `cursor` query parameter.
+func (cursor Cursor) Encode() string {
+	value := cursor.CreatedAt.UTC().Format(time.RFC3339Nano) + "|" + cursor.ID.String()
+
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
+
+func DecodeCursor(encoded string) (*Cursor, error) {
+	value, errDecode := base64.RawURLEncoding.DecodeString(encoded)
+	if errDecode != nil {
+		return nil, ErrWrongCursor
+	}
+
+	parts := strings.SplitN(string(value), "|", 2)
+	if len(parts) != 2 {
+		return nil, ErrWrongCursor
+	}
+
+	createdAt, errTime := time.Parse(time.RFC3339Nano, parts[0])
+	if errTime != nil {
+		return nil, ErrWrongCursor
+	}
+
+	id, errID := uuid.Parse(parts[1])
+	if errID != nil {
+		return nil, ErrWrongCursor
+	}
+
+	return &Cursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+// ListFilter narrows down a list of orders or withdrawals. The zero value returns every row
+// from the oldest to the newest one, as the API did before pagination.
+type ListFilter struct {
+	// Limit - size of the page; 0 - no limit
+	Limit  int
+	Cursor *Cursor
+	// Statuses - statuses of orders; empty - any status
+	Statuses []string
+	// From and To - bounds of the creation time, From is inclusive and To is exclusive
+	From *time.Time
+	To   *time.Time
+	Sort string
+}
+
+func (filter ListFilter) IsDesc() bool {
+	return filter.Sort == SortDesc
+}
+
+// Matches reports whether the row created at createdAt with id passes the filter and follows the cursor.
+// Repositories which can't filter in the query use it.
+func (filter ListFilter) Matches(createdAt time.Time, id uuid.UUID, status string) bool {
+	if len(filter.Statuses) > 0 && !containsString(filter.Statuses, status) {
+		return false
+	}
+
+	if filter.From != nil && createdAt.Before(*filter.From) {
+		return false
+	}
+
+	if filter.To != nil && !createdAt.Before(*filter.To) {
+		return false
+	}
+
+	if filter.Cursor == nil {
+		return true
+	}
+
+	if filter.IsDesc() {
+		return IsBeforeCursor(createdAt, id, *filter.Cursor)
+	}
+
+	return IsBeforeCursor(filter.Cursor.CreatedAt, filter.Cursor.ID, Cursor{CreatedAt: createdAt, ID: id})
+}
+
+// IsBeforeCursor compares rows the same way as ORDER BY created_at, id does.
+func IsBeforeCursor(createdAt time.Time, id uuid.UUID, cursor Cursor) bool {
+	if !createdAt.Equal(cursor.CreatedAt) {
+		return createdAt.Before(cursor.CreatedAt)
+	}
+
+	return id.String() < cursor.ID.String()
+}
+
+func containsString(values []string, value string) bool {
+	for _, current := range values {
+		if current == value {
+			return true
+		}
+	}
+
+	return false
+}
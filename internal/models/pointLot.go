@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PointLot - points of one accrual. Withdrawals consume lots from the oldest one,
+// the points left in a lot expire when it gets older than the expiration period.
+type PointLot struct {
+	ID        uuid.UUID
+	UserID    uuid.UUID
+	OrderID   uuid.UUID
+	Points    Points
+	Remaining Points
+	EarnedAt  time.Time
+}
+
+// ExpiresAt returns the moment when the points left in the lot expire.
+func (lot PointLot) ExpiresAt(expirationMonths int) time.Time {
+	return lot.EarnedAt.AddDate(0, expirationMonths, 0)
+}
+
+// EarnedBeforeToExpire returns the bound of earned_at of lots which have expired at the moment.
+func EarnedBeforeToExpire(moment time.Time, expirationMonths int) time.Time {
+	return moment.AddDate(0, -expirationMonths, 0)
+}
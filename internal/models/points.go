@@ -0,0 +1,209 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/jackc/pgtype"
+)
+
+// pointsScale - number of hundredths in one point.
+const pointsScale = 100
+
+var ErrWrongPoints = errors.New("wrong value of points")
+
+// Points is an exact amount of loyalty points stored as an integer number of hundredths (kopecks).
+// In JSON it is written as a number like the specification requires: 500, 500.5, 751.13.
+// In the database it is NUMERIC with scale 2.
+type Points int64
+
+// ParsePoints parses a decimal number; digits after the second decimal place are rounded half away from zero.
+func ParsePoints(value string) (Points, error) {
+	rat, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrWrongPoints, value)
+	}
+
+	return pointsFromRat(rat)
+}
+
+func (points Points) Add(other Points) Points {
+	return points + other
+}
+
+func (points Points) Sub(other Points) Points {
+	return points - other
+}
+
+// MulRatio multiplies points by numerator/denominator rounding half away from zero.
+func (points Points) MulRatio(numerator int64, denominator int64) Points {
+	if denominator == 0 {
+		return 0
+	}
+
+	// the products are built in big.Int, so they don't overflow int64
+	product := new(big.Int).Mul(big.NewInt(int64(points)), big.NewInt(numerator))
+	scaledDenominator := new(big.Int).Mul(big.NewInt(denominator), big.NewInt(pointsScale))
+
+	rat := new(big.Rat).SetFrac(product, scaledDenominator)
+
+	result, err := pointsFromRat(rat)
+	if err != nil {
+		return 0
+	}
+
+	return result
+}
+
+func (points Points) IsPositive() bool {
+	return points > 0
+}
+
+func (points Points) IsZero() bool {
+	return points == 0
+}
+
+// Float64 is only for reports and metrics, never use it for calculations.
+func (points Points) Float64() float64 {
+	return float64(points) / pointsScale
+}
+
+func (points Points) String() string {
+	sign := ""
+	value := int64(points)
+
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	integer := strconv.FormatInt(value/pointsScale, 10)
+	fraction := value % pointsScale
+
+	switch {
+	case fraction == 0:
+		return sign + integer
+	case fraction%10 == 0:
+		return sign + integer + "." + strconv.FormatInt(fraction/10, 10)
+	default:
+		return fmt.Sprintf("%s%s.%02d", sign, integer, fraction)
+	}
+}
+
+func (points Points) MarshalJSON() ([]byte, error) {
+	return []byte(points.String()), nil
+}
+
+func (points *Points) UnmarshalJSON(data []byte) error {
+	value := strings.Trim(string(data), `"`)
+	if value == "null" || value == "" {
+		*points = 0
+		return nil
+	}
+
+	parsed, err := ParsePoints(value)
+	if err != nil {
+		return err
+	}
+
+	*points = parsed
+
+	return nil
+}
+
+// EncodeText makes pgx send points as a decimal literal, so NUMERIC gets the exact value.
+func (points Points) EncodeText(_ *pgtype.ConnInfo, buf []byte) ([]byte, error) {
+	return append(buf, points.String()...), nil
+}
+
+func (points Points) Value() (driver.Value, error) {
+	return points.String(), nil
+}
+
+func (points *Points) DecodeText(ci *pgtype.ConnInfo, src []byte) error {
+	if src == nil {
+		*points = 0
+		return nil
+	}
+
+	parsed, err := ParsePoints(string(src))
+	if err != nil {
+		return err
+	}
+
+	*points = parsed
+
+	return nil
+}
+
+func (points *Points) DecodeBinary(ci *pgtype.ConnInfo, src []byte) error {
+	numeric := pgtype.Numeric{}
+
+	errDecode := numeric.DecodeBinary(ci, src)
+	if errDecode != nil {
+		return errDecode
+	}
+
+	if numeric.Status == pgtype.Null {
+		*points = 0
+		return nil
+	}
+
+	if numeric.NaN || numeric.InfinityModifier != pgtype.None {
+		return fmt.Errorf("%w: not a finite number", ErrWrongPoints)
+	}
+
+	rat := new(big.Rat).SetInt(numeric.Int)
+
+	exponent := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(numeric.Exp))), nil)
+	if numeric.Exp >= 0 {
+		rat.Mul(rat, new(big.Rat).SetInt(exponent))
+	} else {
+		rat.Quo(rat, new(big.Rat).SetInt(exponent))
+	}
+
+	parsed, err := pointsFromRat(rat)
+	if err != nil {
+		return err
+	}
+
+	*points = parsed
+
+	return nil
+}
+
+func pointsFromRat(rat *big.Rat) (Points, error) {
+	scaled := new(big.Rat).Mul(rat, big.NewRat(pointsScale, 1))
+
+	quotient, remainder := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	// round half away from zero
+	doubledRemainder := new(big.Int).Abs(remainder)
+	doubledRemainder.Lsh(doubledRemainder, 1)
+
+	if doubledRemainder.Cmp(scaled.Denom()) >= 0 {
+		if scaled.Sign() < 0 {
+			quotient.Sub(quotient, big.NewInt(1))
+		} else {
+			quotient.Add(quotient, big.NewInt(1))
+		}
+	}
+
+	if !quotient.IsInt64() {
+		return 0, fmt.Errorf("%w: value is out of range", ErrWrongPoints)
+	}
+
+	return Points(quotient.Int64()), nil
+}
+
+func abs(value int32) int32 {
+	if value < 0 {
+		return -value
+	}
+
+	return value
+}
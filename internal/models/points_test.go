@@ -0,0 +1,263 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/jackc/pgtype"
+)
+
+func TestParsePoints(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Points
+		wantErr bool
+	}{
+		{name: "integer", value: "500", want: 50000},
+		{name: "one decimal", value: "500.5", want: 50050},
+		{name: "two decimals", value: "751.13", want: 75113},
+		{name: "spaces", value: " 1.5 ", want: 150},
+		{name: "round half up", value: "0.005", want: 1},
+		{name: "round down", value: "0.0049", want: 0},
+		{name: "more than 2 decimals", value: "1.23456", want: 123},
+		{name: "negative", value: "-12.3", want: -1230},
+		{name: "negative round half away from zero", value: "-0.005", want: -1},
+		{name: "negative round down", value: "-0.0049", want: 0},
+		{name: "exponent", value: "1e2", want: 10000},
+		{name: "max", value: "92233720368547758.07", want: math.MaxInt64},
+		{name: "overflow", value: "92233720368547758.08", wantErr: true},
+		{name: "negative overflow", value: "-92233720368547758.09", wantErr: true},
+		{name: "empty", value: "", wantErr: true},
+		{name: "not a number", value: "abc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParsePoints(tt.value)
+			if tt.wantErr {
+				if !errors.Is(err, ErrWrongPoints) {
+					t.Fatalf("ParsePoints(%q) error = %v, want %v", tt.value, err, ErrWrongPoints)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParsePoints(%q) unexpected error: %v", tt.value, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("ParsePoints(%q) = %d, want %d", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointsMulRatio(t *testing.T) {
+	tests := []struct {
+		name        string
+		points      Points
+		numerator   int64
+		denominator int64
+		want        Points
+	}{
+		{name: "factor 100", points: 12345, numerator: 100, denominator: 100, want: 12345},
+		{name: "factor 110", points: 10000, numerator: 110, denominator: 100, want: 11000},
+		{name: "round half up", points: 1, numerator: 1, denominator: 2, want: 1},
+		{name: "round down", points: 1, numerator: 1, denominator: 3, want: 0},
+		{name: "negative round half away from zero", points: -1, numerator: 1, denominator: 2, want: -1},
+		{name: "negative denominator", points: 100, numerator: 1, denominator: -4, want: -25},
+		{name: "zero denominator", points: 100, numerator: 1, denominator: 0, want: 0},
+		{name: "big product", points: math.MaxInt64, numerator: 3, denominator: 3, want: math.MaxInt64},
+		{name: "overflow", points: math.MaxInt64, numerator: 2, denominator: 1, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.points.MulRatio(tt.numerator, tt.denominator)
+			if got != tt.want {
+				t.Errorf("%d.MulRatio(%d, %d) = %d, want %d", tt.points, tt.numerator, tt.denominator, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointsString(t *testing.T) {
+	tests := []struct {
+		points Points
+		want   string
+	}{
+		{points: 0, want: "0"},
+		{points: 50000, want: "500"},
+		{points: 50050, want: "500.5"},
+		{points: 75113, want: "751.13"},
+		{points: 5, want: "0.05"},
+		{points: -5, want: "-0.05"},
+		{points: -1230, want: "-12.3"},
+		{points: math.MaxInt64, want: "92233720368547758.07"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.points.String(); got != tt.want {
+				t.Errorf("Points(%d).String() = %q, want %q", tt.points, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPointsJSON(t *testing.T) {
+	type payload struct {
+		Sum Points `json:"sum"`
+	}
+
+	tests := []struct {
+		name    string
+		data    string
+		want    Points
+		wantErr bool
+	}{
+		{name: "number", data: `{"sum":751.13}`, want: 75113},
+		{name: "string", data: `{"sum":"751.13"}`, want: 75113},
+		{name: "more than 2 decimals", data: `{"sum":0.125}`, want: 13},
+		{name: "negative", data: `{"sum":-0.5}`, want: -50},
+		{name: "null", data: `{"sum":null}`, want: 0},
+		{name: "overflow", data: `{"sum":1e20}`, wantErr: true},
+		{name: "not a number", data: `{"sum":"abc"}`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := payload{}
+
+			err := json.Unmarshal([]byte(tt.data), &got)
+			if tt.wantErr {
+				if !errors.Is(err, ErrWrongPoints) {
+					t.Fatalf("json.Unmarshal(%s) error = %v, want %v", tt.data, err, ErrWrongPoints)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("json.Unmarshal(%s) unexpected error: %v", tt.data, err)
+			}
+
+			if got.Sum != tt.want {
+				t.Errorf("json.Unmarshal(%s) = %d, want %d", tt.data, got.Sum, tt.want)
+			}
+		})
+	}
+
+	encoded, err := json.Marshal(payload{Sum: 50050})
+	if err != nil {
+		t.Fatalf("json.Marshal unexpected error: %v", err)
+	}
+
+	if string(encoded) != `{"sum":500.5}` {
+		t.Errorf("json.Marshal = %s, want %s", encoded, `{"sum":500.5}`)
+	}
+}
+
+func TestPointsText(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     []byte
+		want    Points
+		wantErr bool
+	}{
+		{name: "numeric", src: []byte("751.13"), want: 75113},
+		{name: "numeric scale 4", src: []byte("0.1250"), want: 13},
+		{name: "negative", src: []byte("-3.10"), want: -310},
+		{name: "null", src: nil, want: 0},
+		{name: "overflow", src: []byte("100000000000000000000"), wantErr: true},
+		{name: "NaN", src: []byte("NaN"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Points
+
+			err := got.DecodeText(nil, tt.src)
+			if tt.wantErr {
+				if !errors.Is(err, ErrWrongPoints) {
+					t.Fatalf("DecodeText(%q) error = %v, want %v", tt.src, err, ErrWrongPoints)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("DecodeText(%q) unexpected error: %v", tt.src, err)
+			}
+
+			if got != tt.want {
+				t.Errorf("DecodeText(%q) = %d, want %d", tt.src, got, tt.want)
+			}
+
+			if tt.src == nil {
+				return
+			}
+
+			encoded, errEncode := got.EncodeText(nil, nil)
+			if errEncode != nil {
+				t.Fatalf("EncodeText(%d) unexpected error: %v", got, errEncode)
+			}
+
+			if string(encoded) != got.String() {
+				t.Errorf("EncodeText(%d) = %q, want %q", got, encoded, got.String())
+			}
+		})
+	}
+}
+
+func TestPointsBinary(t *testing.T) {
+	connInfo := pgtype.NewConnInfo()
+
+	tests := []struct {
+		name    string
+		numeric pgtype.Numeric
+		want    Points
+		wantErr bool
+	}{
+		{name: "two decimals", numeric: numeric(75113, -2), want: 75113},
+		{name: "more than 2 decimals", numeric: numeric(125, -3), want: 13},
+		{name: "positive exponent", numeric: numeric(12, 3), want: 1200000},
+		{name: "negative", numeric: numeric(-5, -3), want: -1},
+		{name: "overflow", numeric: numeric(1, 20), wantErr: true},
+		{name: "NaN", numeric: pgtype.Numeric{NaN: true, Status: pgtype.Present}, wantErr: true},
+		{name: "null", numeric: pgtype.Numeric{Status: pgtype.Null}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src, errEncode := tt.numeric.EncodeBinary(connInfo, nil)
+			if errEncode != nil {
+				t.Fatalf("Numeric.EncodeBinary unexpected error: %v", errEncode)
+			}
+
+			got := Points(1)
+
+			err := got.DecodeBinary(connInfo, src)
+			if tt.wantErr {
+				if !errors.Is(err, ErrWrongPoints) {
+					t.Fatalf("DecodeBinary error = %v, want %v", err, ErrWrongPoints)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("DecodeBinary unexpected error: %v", err)
+			}
+
+			if got != tt.want {
+				t.Errorf("DecodeBinary = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func numeric(value int64, exp int32) pgtype.Numeric {
+	return pgtype.Numeric{Int: big.NewInt(value), Exp: exp, Status: pgtype.Present}
+}
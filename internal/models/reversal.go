@@ -0,0 +1,25 @@
+package models
+
+const (
+	// ReversalPolicyBlock refuses the reversal of a credit which has already been spent
+	ReversalPolicyBlock = "block"
+	// ReversalPolicyDebt debits the whole sum, the balance becomes negative
+	ReversalPolicyDebt = "debt"
+	// ReversalPolicyClamp debits only what is left on the balance
+	ReversalPolicyClamp = "clamp"
+)
+
+// ReversalResult - compensating transactions written by one reversal and the balance after them.
+type ReversalResult struct {
+	Reversals []Transaction `json:"reversals"`
+	Current   Points        `json:"current"`
+}
+
+func IsReversalPolicy(policy string) bool {
+	switch policy {
+	case ReversalPolicyBlock, ReversalPolicyDebt, ReversalPolicyClamp:
+		return true
+	default:
+		return false
+	}
+}
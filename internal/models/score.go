@@ -8,19 +8,23 @@ import (
 
 type Score struct {
 	ID        uuid.UUID `json:"id,omitempty"`
-	Total     float32   `json:"total,omitempty"`
+	Total     Points    `json:"total,omitempty"`
 	UserID    uuid.UUID `json:"userId,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
 type TotalScoreWithdraw struct {
-	Total    float32 `json:"current"`
-	Withdraw float32 `json:"withdrawn"`
+	Total    Points `json:"current"`
+	Withdraw Points `json:"withdrawn"`
+	// ExpiringSoon - points which expire within the warning period, it is omitted when there are none
+	ExpiringSoon Points `json:"expiring_soon,omitempty"`
+	// NextExpirationAt - when the oldest of ExpiringSoon points expire
+	NextExpirationAt *time.Time `json:"next_expiration_at,omitempty"`
 }
 
 type ScoreWithdraw struct {
 	NumberOrder string    `json:"order,omitempty"`
-	SumWithdraw float32   `json:"sum,omitempty"`
+	SumWithdraw Points    `json:"sum,omitempty"`
 	CreatedAt   time.Time `json:"processed_at,omitempty"`
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TierPeriodMonths - tiers are computed from the points accrued during so many last months.
+const TierPeriodMonths = 12
+
+// Tier - loyalty level which the user reaches by accruing MinPoints during TierPeriodMonths.
+// Factor multiplies the accrual of every processed order of the user at this tier.
+type Tier struct {
+	Name      string `json:"name"`
+	MinPoints Points `json:"min_points"`
+	Factor    Points `json:"factor"`
+}
+
+// UserTier - tier of the user as of the last calculation.
+type UserTier struct {
+	UserID        uuid.UUID
+	Tier          string
+	AccruedPoints Points
+	UpdatedAt     time.Time
+}
+
+type TierProgress struct {
+	Tier   string `json:"tier"`
+	Factor Points `json:"multiplier"`
+	// AccruedPoints - accrued during the last TierPeriodMonths
+	AccruedPoints    Points `json:"accrued_points"`
+	NextTier         string `json:"next_tier,omitempty"`
+	PointsToNextTier Points `json:"points_to_next_tier,omitempty"`
+	// ProgressPercent - the way from the current tier to the next one, 100 at the highest tier
+	ProgressPercent int `json:"progress_percent"`
+}
+
+// DefaultTiers are used when the rules file has no tiers. They don't multiply accruals:
+// points above the accrual system's are given only by the tiers which the operator has configured.
+func DefaultTiers() []Tier {
+	return []Tier{
+		{Name: "BRONZE", MinPoints: 0, Factor: 100},
+		{Name: "SILVER", MinPoints: 1000 * 100, Factor: 100},
+		{Name: "GOLD", MinPoints: 5000 * 100, Factor: 100},
+	}
+}
+
+// TierFor returns the highest of tiers sorted by MinPoints which accruedPoints reach.
+func TierFor(tiers []Tier, accruedPoints Points) Tier {
+	current := tiers[0]
+
+	for _, tier := range tiers[1:] {
+		if accruedPoints < tier.MinPoints {
+			break
+		}
+
+		current = tier
+	}
+
+	return current
+}
+
+// TierPeriodStart returns the start of the period whose accruals count for the tier.
+func TierPeriodStart(moment time.Time) time.Time {
+	return moment.AddDate(0, -TierPeriodMonths, 0)
+}
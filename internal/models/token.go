@@ -1,53 +1,284 @@
 package models
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
 	"errors"
 	"fmt"
+	"os"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/lestrrat-go/jwx/jwa"
+	"github.com/lestrrat-go/jwx/jwk"
 	"github.com/lestrrat-go/jwx/jwt"
 )
 
+var (
+	ErrSecretKeyEmpty     = errors.New("secret key empty")
+	ErrPrivateKeyRequired = errors.New("private key is required for asymmetric signature algorithm")
+	ErrWrongKeyAlgorithm  = errors.New("private key doesn't match signature algorithm")
+)
+
+// JWT signs access tokens with the first (active) key and verifies them with any of the configured keys,
+// so a new key can be rolled out while tokens signed by the previous one are still valid.
+// Every key has kid - its RFC 7638 thumbprint, tokens carry it in the header.
 type JWT struct {
-	Auth     *jwtauth.JWTAuth
 	ExpiryIn time.Duration
-}
 
-func NewJWT(alg string, secretKey string, expiryIn time.Duration) (*JWT, error) {
-	fmt.Println("----------------------------------------------------------------")
-	if len(secretKey) == 0 {
-		return nil, errors.New("secret key empty")
-	}
+	mutex      sync.RWMutex
+	algorithm  jwa.SignatureAlgorithm
+	signingKey jwk.Key
+	verifyKeys jwk.Set
+	publicKeys jwk.Set
+}
 
+// NewJWT creates HMAC signer from secretKey for HS* algorithms.
+// For RS*, PS*, ES* and EdDSA privateKeyFiles are PEM files, the first one signs tokens,
+// the rest are only used to verify tokens during rotation.
+func NewJWT(alg string, secretKey string, privateKeyFiles []string, expiryIn time.Duration) (*JWT, error) {
 	if len(alg) == 0 {
 		alg = "HS256"
 	}
 
-	jwt := &JWT{
-		Auth:     jwtauth.New(alg, []byte(secretKey), nil),
-		ExpiryIn: expiryIn,
+	var algorithm jwa.SignatureAlgorithm
+
+	errAlg := algorithm.Accept(alg)
+	if errAlg != nil {
+		return nil, errAlg
+	}
+
+	token := &JWT{
+		ExpiryIn:   expiryIn,
+		algorithm:  algorithm,
+		verifyKeys: jwk.NewSet(),
+		publicKeys: jwk.NewSet(),
+	}
+
+	if strings.HasPrefix(alg, "HS") {
+		errSymmetric := token.initSymmetricKey(secretKey)
+		if errSymmetric != nil {
+			return nil, errSymmetric
+		}
+
+		return token, nil
+	}
+
+	if len(privateKeyFiles) == 0 {
+		return nil, ErrPrivateKeyRequired
+	}
+
+	for index, file := range privateKeyFiles {
+		errKey := token.addPrivateKey(strings.TrimSpace(file), index == 0)
+		if errKey != nil {
+			return nil, fmt.Errorf("private key %v: %w", file, errKey)
+		}
 	}
 
-	return jwt, nil
+	return token, nil
+}
+
+// Replace takes the keys and the expiry of source, e.g. after the key files were rotated.
+// Tokens signed by a key which isn't in source any more stop being valid.
+func (auth *JWT) Replace(source *JWT) {
+	source.mutex.RLock()
+	defer source.mutex.RUnlock()
+
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+
+	auth.ExpiryIn = source.ExpiryIn
+	auth.algorithm = source.algorithm
+	auth.signingKey = source.signingKey
+	auth.verifyKeys = source.verifyKeys
+	auth.publicKeys = source.publicKeys
 }
 
-func (jwt *JWT) Encode(claims map[string]interface{}) (string, error) {
-	jwtauth.SetExpiryIn(claims, jwt.ExpiryIn)
+// PublicKeys - keys for /.well-known/jwks.json. It is empty for HS* algorithms, the secret is never published.
+func (auth *JWT) PublicKeys() jwk.Set {
+	auth.mutex.RLock()
+	defer auth.mutex.RUnlock()
+
+	return auth.publicKeys
+}
+
+func (auth *JWT) Encode(claims map[string]interface{}) (string, error) {
+	auth.mutex.RLock()
+	defer auth.mutex.RUnlock()
+
+	jwtauth.SetExpiryIn(claims, auth.ExpiryIn)
 
-	_, resToken, errToken := jwt.Auth.Encode(claims)
-	if errToken != nil {
-		return "", errors.New("Failed generation token: " + errToken.Error())
+	token := jwt.New()
+
+	for name, value := range claims {
+		errSet := token.Set(name, value)
+		if errSet != nil {
+			return "", errors.New("Failed generation token: " + errSet.Error())
+		}
+	}
+
+	signed, errSign := jwt.Sign(token, auth.algorithm, auth.signingKey)
+	if errSign != nil {
+		return "", errors.New("Failed generation token: " + errSign.Error())
 	}
 
-	return resToken, nil
+	return string(signed), nil
 }
 
-func (jwt *JWT) Parse(jwtToken string) (jwt.Token, error) {
-	token, errDecode := jwt.Auth.Decode(jwtToken)
+// Parse verifies the signature by the key from kid of the token. Tokens without kid are accepted
+// only when there is a single key, like the ones issued before keys got identifiers.
+func (auth *JWT) Parse(jwtToken string) (jwt.Token, error) {
+	auth.mutex.RLock()
+	defer auth.mutex.RUnlock()
+
+	token, errDecode := jwt.Parse([]byte(jwtToken), jwt.WithKeySet(auth.verifyKeys), jwt.UseDefaultKey(true))
 	if errDecode != nil {
 		return nil, errors.New("Failed devode token from string: " + errDecode.Error())
 	}
 
 	return token, nil
 }
+
+// Verify parses the token and validates its claims, errors are the same as jwtauth.VerifyToken returns.
+func (auth *JWT) Verify(jwtToken string) (jwt.Token, error) {
+	if len(jwtToken) == 0 {
+		return nil, jwtauth.ErrNoTokenFound
+	}
+
+	token, errParse := auth.Parse(jwtToken)
+	if errParse != nil {
+		return token, jwtauth.ErrUnauthorized
+	}
+
+	errValidate := jwt.Validate(token)
+	if errValidate != nil {
+		return token, jwtauth.ErrorReason(errValidate)
+	}
+
+	return token, nil
+}
+
+func (auth *JWT) initSymmetricKey(secretKey string) error {
+	if len(secretKey) == 0 {
+		return ErrSecretKeyEmpty
+	}
+
+	key, errKey := jwk.New([]byte(secretKey))
+	if errKey != nil {
+		return errKey
+	}
+
+	errSetup := setupKey(key, auth.algorithm)
+	if errSetup != nil {
+		return errSetup
+	}
+
+	auth.signingKey = key
+	auth.verifyKeys.Add(key)
+
+	return nil
+}
+
+func (auth *JWT) addPrivateKey(file string, isSigning bool) error {
+	data, errRead := os.ReadFile(file)
+	if errRead != nil {
+		return errRead
+	}
+
+	privateKey, errParse := jwk.ParseKey(data, jwk.WithPEM(true))
+	if errParse != nil {
+		return errParse
+	}
+
+	algorithm, errAlgorithm := algorithmForKey(privateKey, auth.algorithm)
+	if errAlgorithm != nil {
+		return errAlgorithm
+	}
+
+	if isSigning && algorithm != auth.algorithm {
+		return fmt.Errorf("%w: %v", ErrWrongKeyAlgorithm, auth.algorithm)
+	}
+
+	publicKey, errPublic := jwk.PublicKeyOf(privateKey)
+	if errPublic != nil {
+		return errPublic
+	}
+
+	for _, key := range []jwk.Key{privateKey, publicKey} {
+		errSetup := setupKey(key, algorithm)
+		if errSetup != nil {
+			return errSetup
+		}
+	}
+
+	if isSigning {
+		auth.signingKey = privateKey
+	}
+
+	auth.verifyKeys.Add(publicKey)
+	auth.publicKeys.Add(publicKey)
+
+	return nil
+}
+
+func setupKey(key jwk.Key, algorithm jwa.SignatureAlgorithm) error {
+	thumbprint, errThumbprint := key.Thumbprint(crypto.SHA256)
+	if errThumbprint != nil {
+		return errThumbprint
+	}
+
+	values := map[string]interface{}{
+		jwk.KeyIDKey:     base64.RawURLEncoding.EncodeToString(thumbprint),
+		jwk.AlgorithmKey: algorithm,
+		jwk.KeyUsageKey:  jwk.ForSignature,
+	}
+
+	for name, value := range values {
+		errSet := key.Set(name, value)
+		if errSet != nil {
+			return errSet
+		}
+	}
+
+	return nil
+}
+
+// algorithmForKey keeps the configured algorithm when it suits the key,
+// otherwise the algorithm is taken from the type of the key, so keys of a previous algorithm still verify tokens.
+func algorithmForKey(key jwk.Key, configured jwa.SignatureAlgorithm) (jwa.SignatureAlgorithm, error) {
+	var raw interface{}
+
+	errRaw := key.Raw(&raw)
+	if errRaw != nil {
+		return "", errRaw
+	}
+
+	switch rawKey := raw.(type) {
+	case *rsa.PrivateKey:
+		switch configured {
+		case jwa.RS256, jwa.RS384, jwa.RS512, jwa.PS256, jwa.PS384, jwa.PS512:
+			return configured, nil
+		default:
+			return jwa.RS256, nil
+		}
+	case *ecdsa.PrivateKey:
+		switch rawKey.Curve {
+		case elliptic.P256():
+			return jwa.ES256, nil
+		case elliptic.P384():
+			return jwa.ES384, nil
+		case elliptic.P521():
+			return jwa.ES512, nil
+		}
+	case ed25519.PrivateKey:
+		return jwa.EdDSA, nil
+	}
+
+	return "", fmt.Errorf("%w: unsupported key type %T", ErrWrongKeyAlgorithm, raw)
+}
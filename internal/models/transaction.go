@@ -9,13 +9,85 @@ import (
 const (
 	IncreasePointsType int = 1
 	DecreasePointsType int = 2
+	// ExpirePointsType - points of a lot which weren't spent during the expiration period
+	ExpirePointsType int = 3
+	// BonusPointsType - points given for the order by a bonus rule over the accrual
+	BonusPointsType int = 4
+	// AdjustmentCreditType and AdjustmentDebitType - manual corrections of the balance by support staff,
+	// they aren't linked to an order and carry the reason and the operator
+	AdjustmentCreditType int = 5
+	AdjustmentDebitType  int = 6
+	// ReversalDebitType and ReversalCreditType - compensating transactions which cancel a credit or a debit,
+	// ReversalOf links them to the reversed transaction
+	ReversalDebitType  int = 7
+	ReversalCreditType int = 8
 )
 
 type Transaction struct {
-	ID        uuid.UUID `json:"id,omitempty"`
-	UserID    uuid.UUID `json:"userId,omitempty"`
-	OrderID   uuid.UUID `json:"orderId,omitempty"`
-	Points    float32   `json:"points,omitempty"`
-	Type      int       `json:"type,omitempty"` // пополнение или списание баллов
-	CreatedAt time.Time `json:"createdAt"`
+	ID           uuid.UUID `json:"id,omitempty"`
+	UserID       uuid.UUID `json:"userId,omitempty"`
+	OrderID      uuid.UUID `json:"orderId,omitempty"`
+	Points       Points    `json:"points,omitempty"`
+	BalanceAfter Points    `json:"balanceAfter"`
+	Type         int       `json:"type,omitempty"` // пополнение или списание баллов
+	RuleID       string    `json:"ruleId,omitempty"`
+	// OrderNumber - number of the order of the transaction, it is filled by lists of transactions
+	OrderNumber string     `json:"order,omitempty"`
+	Reason      string     `json:"reason,omitempty"`
+	OperatorID  *uuid.UUID `json:"operatorId,omitempty"`
+	ReversalOf  *uuid.UUID `json:"reversalOf,omitempty"`
+	// ReversedBy - the compensating transaction, it is filled by reading queries
+	ReversedBy *uuid.UUID `json:"reversedBy,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+}
+
+func (transaction *Transaction) IsReversed() bool {
+	return transaction.ReversedBy != nil
+}
+
+// LedgerBalance compares the running total of the user with the sum of the ledger.
+type LedgerBalance struct {
+	UserID      uuid.UUID `json:"userId"`
+	ScoreTotal  Points    `json:"scoreTotal"`
+	LedgerTotal Points    `json:"ledgerTotal"`
+}
+
+func (balance LedgerBalance) Drift() Points {
+	return balance.ScoreTotal.Sub(balance.LedgerTotal)
+}
+
+// DebitPointsTypes returns types of transactions which take points away from the user.
+func DebitPointsTypes() []int {
+	return []int{DecreasePointsType, ExpirePointsType, AdjustmentDebitType, ReversalDebitType}
+}
+
+func IsDebitPointsType(typeTransaction int) bool {
+	for _, debitType := range DebitPointsTypes() {
+		if debitType == typeTransaction {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SignedPoints returns points of the transaction with the sign of its effect on the balance.
+func SignedPoints(points Points, typeTransaction int) Points {
+	if IsDebitPointsType(typeTransaction) {
+		return -points
+	}
+
+	return points
+}
+
+// ReversalType returns the type of the compensating transaction. Expirations and reversals can't be reversed.
+func ReversalType(typeTransaction int) (int, bool) {
+	switch typeTransaction {
+	case IncreasePointsType, BonusPointsType, AdjustmentCreditType:
+		return ReversalDebitType, true
+	case DecreasePointsType, AdjustmentDebitType:
+		return ReversalCreditType, true
+	default:
+		return 0, false
+	}
 }
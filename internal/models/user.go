@@ -6,11 +6,22 @@ import (
 	"github.com/google/uuid"
 )
 
+const (
+	RoleUser = "user"
+	// RoleAdmin - support staff with access to /api/admin, the role is carried in the `role` claim of the access token
+	RoleAdmin = "admin"
+)
+
 type User struct {
-	ID        uuid.UUID `json:"id,omitempty"`
-	Login     string    `json:"login,omitempty"`
-	Password  string    `json:"password,omitempty"`
-	Token     JWT       `json:"token,omitempty"`
-	CreatedAt time.Time `json:"createdAt,omitempty"`
-	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+	ID        uuid.UUID  `json:"id,omitempty"`
+	Login     string     `json:"login,omitempty"`
+	Password  string     `json:"password,omitempty"`
+	Role      string     `json:"role,omitempty"`
+	BlockedAt *time.Time `json:"blockedAt,omitempty"`
+	CreatedAt time.Time  `json:"createdAt,omitempty"`
+	UpdatedAt time.Time  `json:"updatedAt,omitempty"`
+}
+
+func (user *User) IsBlocked() bool {
+	return user.BlockedAt != nil
 }
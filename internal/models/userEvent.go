@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	UserEventOrder   = "order"
+	UserEventBalance = "balance"
+	// UserEventResync - events since Last-Event-ID are lost, the client must reload orders and the balance
+	UserEventResync = "resync"
+)
+
+// UserEvent is pushed to the browser of the user by GET /api/user/events.
+type UserEvent struct {
+	ID        uint64
+	UserID    uuid.UUID
+	Type      string
+	Data      json.RawMessage
+	CreatedAt time.Time
+}
+
+type BalanceEventData struct {
+	Current Points `json:"current"`
+	// Change - accrued points are positive, withdrawn ones are negative
+	Change Points `json:"change"`
+}
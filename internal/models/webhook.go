@@ -0,0 +1,110 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	EventOrderProcessed  = "order.processed"
+	EventOrderInvalid    = "order.invalid"
+	EventPointsWithdrawn = "points.withdrawn"
+	EventPointsExpired   = "points.expired"
+	EventPointsAdjusted  = "points.adjusted"
+	EventPointsReversed  = "points.reversed"
+
+	DeliveryStatusPending   = "PENDING"
+	DeliveryStatusDelivered = "DELIVERED"
+	DeliveryStatusDead      = "DEAD"
+)
+
+type Webhook struct {
+	ID     uuid.UUID `json:"id"`
+	UserID uuid.UUID `json:"-"`
+	URL    string    `json:"url"`
+	// Secret - key of HMAC signatures, it is shown only once when the webhook is created
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OutboxEvent is written in the transaction of the change it describes,
+// so an event is sent if and only if the change is committed.
+type OutboxEvent struct {
+	ID        uuid.UUID       `json:"id"`
+	UserID    uuid.UUID       `json:"-"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// WebhookDelivery - an attempt to deliver the event to one webhook. It becomes DEAD
+// after the last unsuccessful attempt and stays in the dead-letter list until it is redelivered.
+type WebhookDelivery struct {
+	ID             uuid.UUID   `json:"id"`
+	WebhookID      uuid.UUID   `json:"webhook_id"`
+	URL            string      `json:"url"`
+	Secret         string      `json:"-"`
+	Event          OutboxEvent `json:"event"`
+	Status         string      `json:"status"`
+	Attempts       int         `json:"attempts"`
+	LastStatusCode int         `json:"last_status_code,omitempty"`
+	LastError      string      `json:"last_error,omitempty"`
+	NextAttemptAt  time.Time   `json:"next_attempt_at"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+}
+
+type OrderEventData struct {
+	Number  string `json:"number"`
+	Status  string `json:"status"`
+	Accrual Points `json:"accrual,omitempty"`
+}
+
+type WithdrawalEventData struct {
+	Order string `json:"order"`
+	Sum   Points `json:"sum"`
+}
+
+type ExpirationEventData struct {
+	Sum Points `json:"sum"`
+}
+
+// ReversalEventData - Sum is negative when a credit is reversed.
+type ReversalEventData struct {
+	Transaction uuid.UUID `json:"transaction"`
+	Order       string    `json:"order,omitempty"`
+	Sum         Points    `json:"sum"`
+	Reason      string    `json:"reason"`
+}
+
+// AdjustmentEventData - Sum is negative when the points are taken away.
+type AdjustmentEventData struct {
+	Sum    Points `json:"sum"`
+	Reason string `json:"reason"`
+}
+
+func NewOutboxEvent(userID uuid.UUID, eventType string, data interface{}, createdAt time.Time) (*OutboxEvent, error) {
+	encoded, errEncode := json.Marshal(data)
+	if errEncode != nil {
+		return nil, errEncode
+	}
+
+	return &OutboxEvent{
+		ID:        uuid.New(),
+		UserID:    userID,
+		Type:      eventType,
+		Data:      encoded,
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// OrderEventType returns the event of the final status of the order.
+func OrderEventType(status string) string {
+	if status == OrderStatusInvalid {
+		return EventOrderInvalid
+	}
+
+	return EventOrderProcessed
+}
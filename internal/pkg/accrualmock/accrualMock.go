@@ -0,0 +1,379 @@
+// Package accrualmock simulates the accrual system for local development and tests.
+// Mock is an http.Handler, so it can be served by cmd/accrual-mock or by httptest.NewServer:
+//
+//	mock := accrualmock.New(accrualmock.Behaviour{RateLimit: 10}, logger)
+//	server := httptest.NewServer(mock)
+//	defer server.Close()
+//
+//	_ = mock.RegisterOrder(accrualmock.Order{Number: "12345678903", Accrual: &points})
+package accrualmock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+)
+
+const (
+	StatusRegistered = "REGISTERED"
+	StatusInvalid    = "INVALID"
+	StatusProcessing = "PROCESSING"
+	StatusProcessed  = "PROCESSED"
+
+	RewardTypePercent = "%"
+	RewardTypePoints  = "pt"
+)
+
+var (
+	ErrOrderExists      = errors.New("order is already registered")
+	ErrRewardRuleExists = errors.New("reward rule with this match is already registered")
+	ErrWrongOrder       = errors.New("order number is required")
+	ErrWrongStatus      = errors.New("unknown status")
+	ErrWrongRewardRule  = errors.New("reward rule requires match and reward type % or pt")
+)
+
+var defaultOrderStatuses = []string{StatusRegistered, StatusProcessing, StatusProcessed}
+
+type Good struct {
+	Description string        `json:"description"`
+	Price       models.Points `json:"price"`
+}
+
+// RewardRule gives the reward for every good whose description contains Match:
+// Reward percent of the price for "%" and Reward points for "pt".
+type RewardRule struct {
+	Match      string        `json:"match"`
+	Reward     models.Points `json:"reward"`
+	RewardType string        `json:"reward_type"`
+}
+
+type Order struct {
+	Number string `json:"order"`
+	Goods  []Good `json:"goods,omitempty"`
+	// Statuses - answers to consecutive requests, the last one is repeated;
+	// REGISTERED, PROCESSING, PROCESSED by default
+	Statuses []string `json:"statuses,omitempty"`
+	// Accrual - fixed reward instead of the one calculated by the rules
+	Accrual *models.Points `json:"accrual,omitempty"`
+}
+
+type orderState struct {
+	order Order
+	step  int
+}
+
+type Mock struct {
+	router    http.Handler
+	mutex     sync.Mutex
+	orders    map[string]*orderState
+	rules     []RewardRule
+	behaviour Behaviour
+	limiter   limiter
+	requests  int
+	random    *rand.Rand
+	logger    logger.Logger
+}
+
+func New(behaviour Behaviour, logger logger.Logger) *Mock {
+	mock := &Mock{
+		orders:    make(map[string]*orderState),
+		rules:     make([]RewardRule, 0),
+		behaviour: behaviour,
+		random:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		logger:    logger,
+	}
+
+	router := chi.NewRouter()
+
+	router.Get("/api/orders/{number}", mock.getOrderHandler)
+	router.Post("/api/orders", mock.registerOrderHandler)
+	router.Post("/api/goods", mock.registerRewardRuleHandler)
+	router.Get("/admin/behaviour", mock.getBehaviourHandler)
+	router.Put("/admin/behaviour", mock.setBehaviourHandler)
+
+	mock.router = router
+
+	return mock
+}
+
+func (mock *Mock) ServeHTTP(writer http.ResponseWriter, request *http.Request) {
+	mock.router.ServeHTTP(writer, request)
+}
+
+func (mock *Mock) RegisterOrder(order Order) error {
+	if len(order.Number) == 0 {
+		return ErrWrongOrder
+	}
+
+	if len(order.Statuses) == 0 {
+		order.Statuses = defaultOrderStatuses
+	}
+
+	for _, status := range order.Statuses {
+		switch status {
+		case StatusRegistered, StatusInvalid, StatusProcessing, StatusProcessed:
+		default:
+			return fmt.Errorf("%w: %v", ErrWrongStatus, status)
+		}
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	if _, ok := mock.orders[order.Number]; ok {
+		return ErrOrderExists
+	}
+
+	mock.orders[order.Number] = &orderState{order: order}
+
+	return nil
+}
+
+func (mock *Mock) AddRewardRule(rule RewardRule) error {
+	if len(rule.Match) == 0 || (rule.RewardType != RewardTypePercent && rule.RewardType != RewardTypePoints) {
+		return ErrWrongRewardRule
+	}
+
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	for _, existing := range mock.rules {
+		if existing.Match == rule.Match {
+			return ErrRewardRuleExists
+		}
+	}
+
+	mock.rules = append(mock.rules, rule)
+
+	return nil
+}
+
+func (mock *Mock) SetBehaviour(behaviour Behaviour) {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	mock.behaviour = behaviour
+}
+
+func (mock *Mock) Behaviour() Behaviour {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	return mock.behaviour
+}
+
+// Requests returns the number of GET /api/orders/{number} requests, also the rejected ones.
+func (mock *Mock) Requests() int {
+	mock.mutex.Lock()
+	defer mock.mutex.Unlock()
+
+	return mock.requests
+}
+
+func (mock *Mock) getOrderHandler(writer http.ResponseWriter, request *http.Request) {
+	number := chi.URLParam(request, "number")
+
+	latency := mock.Behaviour().latency()
+	if latency > 0 {
+		timer := time.NewTimer(latency)
+
+		select {
+		case <-request.Context().Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+
+	mock.mutex.Lock()
+
+	mock.requests++
+
+	isAllowed, retryAfter := mock.limiter.allow(&mock.behaviour, time.Now())
+	if !isAllowed {
+		rateLimit := mock.behaviour.RateLimit
+		mock.mutex.Unlock()
+
+		mock.logger.Infof("=== accrualMock: order %v: too many requests", number)
+
+		writer.Header().Set("Content-Type", "text/plain")
+		writer.Header().Set("Retry-After", retryAfter)
+		writer.WriteHeader(http.StatusTooManyRequests)
+		_, _ = fmt.Fprintf(writer, "No more than %d requests per minute allowed", rateLimit)
+
+		return
+	}
+
+	if mock.behaviour.shouldFail(mock.random) {
+		mock.mutex.Unlock()
+
+		mock.logger.Infof("=== accrualMock: order %v: injected internal server error", number)
+		http.Error(writer, "internal server error", http.StatusInternalServerError)
+
+		return
+	}
+
+	state, ok := mock.orders[number]
+	if !ok {
+		mock.mutex.Unlock()
+
+		writer.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	accrualOrder := mock.nextState(state)
+
+	mock.mutex.Unlock()
+
+	mock.logger.Infof("=== accrualMock: order %v: %v %v", number, accrualOrder.Status, accrualOrder.Points)
+
+	writeJSON(writer, http.StatusOK, accrualOrder, mock.logger)
+}
+
+// nextState answers with the current step of the order and moves it to the next one. The mutex must be locked.
+func (mock *Mock) nextState(state *orderState) models.AccrualOrder {
+	stepIndex := state.step
+	if stepIndex >= len(state.order.Statuses) {
+		stepIndex = len(state.order.Statuses) - 1
+	}
+
+	state.step++
+
+	accrualOrder := models.AccrualOrder{
+		Number: state.order.Number,
+		Status: state.order.Statuses[stepIndex],
+	}
+
+	if accrualOrder.Status == StatusProcessed {
+		accrualOrder.Points = mock.calculateAccrual(state.order)
+	}
+
+	return accrualOrder
+}
+
+func (mock *Mock) calculateAccrual(order Order) models.Points {
+	if order.Accrual != nil {
+		return *order.Accrual
+	}
+
+	var accrual models.Points
+
+	for _, good := range order.Goods {
+		for _, rule := range mock.rules {
+			if !strings.Contains(good.Description, rule.Match) {
+				continue
+			}
+
+			if rule.RewardType == RewardTypePercent {
+				accrual = accrual.Add(good.Price.MulRatio(int64(rule.Reward), 100*100))
+			} else {
+				accrual = accrual.Add(rule.Reward)
+			}
+
+			break
+		}
+	}
+
+	return accrual
+}
+
+func (mock *Mock) registerOrderHandler(writer http.ResponseWriter, request *http.Request) {
+	order := Order{}
+
+	if !decodeJSON(writer, request, &order) {
+		return
+	}
+
+	errRegister := mock.RegisterOrder(order)
+
+	switch {
+	case errors.Is(errRegister, ErrOrderExists):
+		http.Error(writer, errRegister.Error(), http.StatusConflict)
+	case errRegister != nil:
+		http.Error(writer, errRegister.Error(), http.StatusBadRequest)
+	default:
+		writer.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func (mock *Mock) registerRewardRuleHandler(writer http.ResponseWriter, request *http.Request) {
+	rule := RewardRule{}
+
+	if !decodeJSON(writer, request, &rule) {
+		return
+	}
+
+	errAdd := mock.AddRewardRule(rule)
+
+	switch {
+	case errors.Is(errAdd, ErrRewardRuleExists):
+		http.Error(writer, errAdd.Error(), http.StatusConflict)
+	case errAdd != nil:
+		http.Error(writer, errAdd.Error(), http.StatusBadRequest)
+	default:
+		writer.WriteHeader(http.StatusOK)
+	}
+}
+
+func (mock *Mock) getBehaviourHandler(writer http.ResponseWriter, _ *http.Request) {
+	writeJSON(writer, http.StatusOK, mock.Behaviour(), mock.logger)
+}
+
+func (mock *Mock) setBehaviourHandler(writer http.ResponseWriter, request *http.Request) {
+	behaviour := Behaviour{}
+
+	if !decodeJSON(writer, request, &behaviour) {
+		return
+	}
+
+	mock.SetBehaviour(behaviour)
+
+	mock.logger.Infof("=== accrualMock: behaviour is changed: %+v", behaviour)
+
+	writeJSON(writer, http.StatusOK, behaviour, mock.logger)
+}
+
+func decodeJSON(writer http.ResponseWriter, request *http.Request, value interface{}) bool {
+	body, errRead := io.ReadAll(request.Body)
+	if errRead != nil {
+		http.Error(writer, errRead.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	errDecode := json.Unmarshal(body, value)
+	if errDecode != nil {
+		http.Error(writer, errDecode.Error(), http.StatusBadRequest)
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(writer http.ResponseWriter, statusCode int, value interface{}, logger logger.Logger) {
+	body, errEncode := json.Marshal(value)
+	if errEncode != nil {
+		logger.Errorf("---> ERROR: accrualMock: json encode: %v\n", errEncode)
+		http.Error(writer, errEncode.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(statusCode)
+
+	_, errWrite := writer.Write(body)
+	if errWrite != nil {
+		logger.Errorf("---> ERROR: accrualMock: write: %v\n", errWrite)
+	}
+}
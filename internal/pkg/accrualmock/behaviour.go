@@ -0,0 +1,81 @@
+package accrualmock
+
+import (
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Behaviour - faults which the mock injects into GET /api/orders/{number}.
+// It can be set by Options, by flags of cmd/accrual-mock and at runtime by PUT /admin/behaviour.
+type Behaviour struct {
+	// RateLimit - requests per minute, the rest get 429 Too Many Requests; 0 - no limit
+	RateLimit int `json:"rate_limit"`
+	// RetryAfter - seconds in the Retry-After header; 0 - until the end of the current minute
+	RetryAfter int `json:"retry_after"`
+	// ThrottleNext - the next N requests get 429 regardless of the rate limit
+	ThrottleNext int `json:"throttle_next"`
+	// LatencyMs - delay before every response
+	LatencyMs int `json:"latency_ms"`
+	// ErrorRate - probability from 0 to 1 of 500 Internal Server Error
+	ErrorRate float64 `json:"error_rate"`
+	// FailNext - the next N requests get 500 Internal Server Error
+	FailNext int `json:"fail_next"`
+}
+
+func (behaviour Behaviour) latency() time.Duration {
+	return time.Duration(behaviour.LatencyMs) * time.Millisecond
+}
+
+// limiter counts requests in the current minute like the real accrual system does.
+type limiter struct {
+	windowStartedAt time.Time
+	requests        int
+}
+
+// allow returns false and the value of Retry-After when the request exceeds the limit.
+func (limiter *limiter) allow(behaviour *Behaviour, now time.Time) (bool, string) {
+	if behaviour.ThrottleNext > 0 {
+		behaviour.ThrottleNext--
+		return false, retryAfter(behaviour, time.Minute)
+	}
+
+	if behaviour.RateLimit <= 0 {
+		return true, ""
+	}
+
+	if now.Sub(limiter.windowStartedAt) >= time.Minute {
+		limiter.windowStartedAt = now
+		limiter.requests = 0
+	}
+
+	limiter.requests++
+
+	if limiter.requests <= behaviour.RateLimit {
+		return true, ""
+	}
+
+	return false, retryAfter(behaviour, limiter.windowStartedAt.Add(time.Minute).Sub(now))
+}
+
+func (behaviour *Behaviour) shouldFail(random *rand.Rand) bool {
+	if behaviour.FailNext > 0 {
+		behaviour.FailNext--
+		return true
+	}
+
+	return behaviour.ErrorRate > 0 && random.Float64() < behaviour.ErrorRate
+}
+
+func retryAfter(behaviour *Behaviour, untilWindowEnd time.Duration) string {
+	if behaviour.RetryAfter > 0 {
+		return strconv.Itoa(behaviour.RetryAfter)
+	}
+
+	seconds := int(untilWindowEnd.Round(time.Second) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+
+	return strconv.Itoa(seconds)
+}
@@ -0,0 +1,380 @@
+// Package bonusrules adds marketing bonuses to the accrual of a processed order.
+// Rules are read from a JSON file set by BONUS_RULES_FILE:
+//
+//	{
+//	  "timezone": "Europe/Moscow",
+//	  "rules": [
+//	    {"id": "weekend-x2", "type": "multiplier", "factor": 2, "weekdays": ["saturday", "sunday"], "monthly_cap": 1000},
+//	    {"id": "first-order", "type": "bonus", "points": 100, "first_order": true}
+//	  ],
+//	  "tiers": [
+//	    {"name": "BRONZE", "min_points": 0, "factor": 1},
+//	    {"name": "SILVER", "min_points": 1000, "factor": 1.1}
+//	  ]
+//	}
+//
+// Conditions are checked at the moment the order was uploaded, in the timezone of the file (UTC by default).
+// A multiplier gives the extra points over the base accrual, so "factor": 2 doubles it; multipliers don't compound.
+// monthly_cap limits the points which the rule gives one user in a calendar month.
+// Tiers are sorted by min_points, the first one starts from 0; without them models.DefaultTiers are used.
+// The rules can be replaced while the service is running, the tiers are read only at start.
+package bonusrules
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/models"
+)
+
+const (
+	RuleTypeMultiplier = "multiplier"
+	RuleTypeBonus      = "bonus"
+
+	// TierRulePrefix - rules of the ledger rows with the points of tier multipliers
+	TierRulePrefix = "tier:"
+
+	maxRuleIDLength = 64
+
+	// onePoint - Points keep hundredths of a point
+	onePoint = models.Points(100)
+)
+
+var (
+	ErrWrongRule      = errors.New("wrong bonus rule")
+	ErrDuplicateRule  = errors.New("bonus rule with this id already exists")
+	ErrWrongTimezone  = errors.New("unknown timezone of bonus rules")
+	ErrWrongRulesFile = errors.New("bonus rules file is malformed")
+	ErrWrongTiers     = errors.New("wrong loyalty tiers")
+	ErrTiersChanged   = errors.New("loyalty tiers have changed, they are applied only at the start")
+)
+
+type Rule struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// Factor - multiplier of the base accrual, it must be greater than 1
+	Factor models.Points `json:"factor,omitempty"`
+	// Points - fixed bonus
+	Points models.Points `json:"points,omitempty"`
+	// Weekdays - days when the rule works, in English: "monday", ..., "sunday"; empty - every day
+	Weekdays []string `json:"weekdays,omitempty"`
+	// FirstOrder - the rule works only for the first processed order of the user
+	FirstOrder bool `json:"first_order,omitempty"`
+	// MinAccrual - the least base accrual which the rule needs
+	MinAccrual models.Points `json:"min_accrual,omitempty"`
+	// From and To - period when the rule works, From is inclusive and To is exclusive
+	From *time.Time `json:"from,omitempty"`
+	To   *time.Time `json:"to,omitempty"`
+	// MonthlyCap - the most points the rule gives one user in a calendar month; 0 - no limit
+	MonthlyCap models.Points `json:"monthly_cap,omitempty"`
+
+	weekdays map[time.Weekday]bool
+}
+
+// Bonus - points which a rule has given for the order, every bonus is a separate row of the ledger.
+type Bonus struct {
+	RuleID string
+	Points models.Points
+}
+
+// HistoryInterface answers questions about the past of the user which the rules depend on.
+// It is called inside the transaction which credits the order.
+type HistoryInterface interface {
+	IsFirstProcessedOrder(ctx context.Context) (bool, error)
+	GetBonusSince(ctx context.Context, ruleID string, since time.Time) (models.Points, error)
+}
+
+type EvaluatorInterface interface {
+	Evaluate(ctx context.Context, accrual models.Points, orderedAt time.Time, history HistoryInterface) ([]Bonus, error)
+}
+
+type rulesFile struct {
+	Timezone string        `json:"timezone"`
+	Rules    []Rule        `json:"rules"`
+	Tiers    []models.Tier `json:"tiers"`
+}
+
+type Engine struct {
+	mutex    sync.RWMutex
+	location *time.Location
+	rules    []Rule
+	tiers    []models.Tier
+}
+
+var _ EvaluatorInterface = &Engine{}
+
+// Load reads the rules from the file; without the file the engine gives no bonuses.
+func Load(file string) (*Engine, error) {
+	if len(file) == 0 {
+		return &Engine{location: time.UTC, rules: make([]Rule, 0), tiers: models.DefaultTiers()}, nil
+	}
+
+	data, errRead := os.ReadFile(file)
+	if errRead != nil {
+		return nil, errRead
+	}
+
+	return Parse(data)
+}
+
+func Parse(data []byte) (*Engine, error) {
+	parsed := rulesFile{}
+
+	errDecode := json.Unmarshal(data, &parsed)
+	if errDecode != nil {
+		return nil, fmt.Errorf("%w: %v", ErrWrongRulesFile, errDecode)
+	}
+
+	location := time.UTC
+
+	if len(parsed.Timezone) > 0 {
+		var errLocation error
+
+		location, errLocation = time.LoadLocation(parsed.Timezone)
+		if errLocation != nil {
+			return nil, fmt.Errorf("%w: %v", ErrWrongTimezone, parsed.Timezone)
+		}
+	}
+
+	isUsedID := make(map[string]bool, len(parsed.Rules))
+
+	for index := range parsed.Rules {
+		rule := &parsed.Rules[index]
+
+		errValidate := rule.validate()
+		if errValidate != nil {
+			return nil, errValidate
+		}
+
+		if isUsedID[rule.ID] {
+			return nil, fmt.Errorf("%w: %v", ErrDuplicateRule, rule.ID)
+		}
+
+		isUsedID[rule.ID] = true
+	}
+
+	tiers := parsed.Tiers
+	if len(tiers) == 0 {
+		tiers = models.DefaultTiers()
+	}
+
+	errTiers := validateTiers(tiers)
+	if errTiers != nil {
+		return nil, errTiers
+	}
+
+	return &Engine{location: location, rules: parsed.Rules, tiers: tiers}, nil
+}
+
+func (engine *Engine) Rules() []Rule {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	return engine.rules
+}
+
+// ReplaceRules takes the rules and the timezone of source, orders credited after it get the new bonuses.
+// The tiers can't be replaced, so nothing is applied when the tiers of source differ: the file needs a restart.
+func (engine *Engine) ReplaceRules(source *Engine) error {
+	if !reflect.DeepEqual(engine.tiers, source.tiers) {
+		return ErrTiersChanged
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	engine.location = source.location
+	engine.rules = source.rules
+
+	return nil
+}
+
+// Tiers returns the loyalty tiers sorted by MinPoints.
+func (engine *Engine) Tiers() []models.Tier {
+	return engine.tiers
+}
+
+// Evaluate returns the bonuses of the order in the order of the rules. The base accrual itself isn't included.
+func (engine *Engine) Evaluate(
+	ctx context.Context,
+	accrual models.Points,
+	orderedAt time.Time,
+	history HistoryInterface,
+) ([]Bonus, error) {
+	bonuses := make([]Bonus, 0)
+
+	engine.mutex.RLock()
+	location, rules := engine.location, engine.rules
+	engine.mutex.RUnlock()
+
+	if len(rules) == 0 {
+		return bonuses, nil
+	}
+
+	localOrderedAt := orderedAt.In(location)
+	now := time.Now().In(location)
+	monthStartedAt := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, location)
+
+	var isFirstOrder *bool
+
+	for _, rule := range rules {
+		if !rule.matches(accrual, localOrderedAt) {
+			continue
+		}
+
+		if rule.FirstOrder {
+			if isFirstOrder == nil {
+				isFirst, errHistory := history.IsFirstProcessedOrder(ctx)
+				if errHistory != nil {
+					return nil, errHistory
+				}
+
+				isFirstOrder = &isFirst
+			}
+
+			if !*isFirstOrder {
+				continue
+			}
+		}
+
+		points := rule.Points
+		if rule.Type == RuleTypeMultiplier {
+			points = accrual.MulRatio(int64(rule.Factor.Sub(onePoint)), int64(onePoint))
+		}
+
+		if rule.MonthlyCap > 0 {
+			given, errHistory := history.GetBonusSince(ctx, rule.ID, monthStartedAt.UTC())
+			if errHistory != nil {
+				return nil, errHistory
+			}
+
+			if left := rule.MonthlyCap.Sub(given); points > left {
+				points = left
+			}
+		}
+
+		if points <= 0 {
+			continue
+		}
+
+		bonuses = append(bonuses, Bonus{RuleID: rule.ID, Points: points})
+	}
+
+	return bonuses, nil
+}
+
+func (rule *Rule) validate() error {
+	if len(rule.ID) == 0 || len(rule.ID) > maxRuleIDLength {
+		return fmt.Errorf("%w: id must have from 1 to %d characters", ErrWrongRule, maxRuleIDLength)
+	}
+
+	if strings.HasPrefix(rule.ID, TierRulePrefix) {
+		return fmt.Errorf("%w: %v: prefix %v is reserved for tiers", ErrWrongRule, rule.ID, TierRulePrefix)
+	}
+
+	switch rule.Type {
+	case RuleTypeMultiplier:
+		if rule.Factor <= onePoint {
+			return fmt.Errorf("%w: %v: factor must be greater than 1", ErrWrongRule, rule.ID)
+		}
+	case RuleTypeBonus:
+		if rule.Points <= 0 {
+			return fmt.Errorf("%w: %v: points must be positive", ErrWrongRule, rule.ID)
+		}
+	default:
+		return fmt.Errorf("%w: %v: unknown type %q", ErrWrongRule, rule.ID, rule.Type)
+	}
+
+	if rule.MonthlyCap < 0 || rule.MinAccrual < 0 {
+		return fmt.Errorf("%w: %v: monthly_cap and min_accrual can't be negative", ErrWrongRule, rule.ID)
+	}
+
+	if rule.From != nil && rule.To != nil && !rule.From.Before(*rule.To) {
+		return fmt.Errorf("%w: %v: from must be before to", ErrWrongRule, rule.ID)
+	}
+
+	rule.weekdays = make(map[time.Weekday]bool, len(rule.Weekdays))
+
+	for _, name := range rule.Weekdays {
+		weekday, ok := parseWeekday(name)
+		if !ok {
+			return fmt.Errorf("%w: %v: unknown weekday %q", ErrWrongRule, rule.ID, name)
+		}
+
+		rule.weekdays[weekday] = true
+	}
+
+	return nil
+}
+
+func (rule *Rule) matches(accrual models.Points, orderedAt time.Time) bool {
+	if accrual < rule.MinAccrual {
+		return false
+	}
+
+	if rule.From != nil && orderedAt.Before(*rule.From) {
+		return false
+	}
+
+	if rule.To != nil && !orderedAt.Before(*rule.To) {
+		return false
+	}
+
+	return len(rule.weekdays) == 0 || rule.weekdays[orderedAt.Weekday()]
+}
+
+func validateTiers(tiers []models.Tier) error {
+	if tiers[0].MinPoints != 0 {
+		return fmt.Errorf("%w: the first tier must start from 0 points", ErrWrongTiers)
+	}
+
+	isUsedName := make(map[string]bool, len(tiers))
+
+	for index, tier := range tiers {
+		// the name is written to the ledger as the rule of the tier bonus
+		if len(tier.Name) == 0 || len(tier.Name) > maxRuleIDLength-len(TierRulePrefix) {
+			return fmt.Errorf("%w: name must have from 1 to %d characters", ErrWrongTiers, maxRuleIDLength-len(TierRulePrefix))
+		}
+
+		if isUsedName[tier.Name] {
+			return fmt.Errorf("%w: %v is repeated", ErrWrongTiers, tier.Name)
+		}
+
+		isUsedName[tier.Name] = true
+
+		if tier.Factor < onePoint {
+			return fmt.Errorf("%w: %v: factor can't be less than 1", ErrWrongTiers, tier.Name)
+		}
+
+		if index > 0 && tier.MinPoints <= tiers[index-1].MinPoints {
+			return fmt.Errorf("%w: %v: tiers must be sorted by min_points", ErrWrongTiers, tier.Name)
+		}
+	}
+
+	return nil
+}
+
+// TierBonus returns the extra points of the tier for the accrual; its rule in the ledger is TierRulePrefix and the tier.
+func TierBonus(tier models.Tier, accrual models.Points) Bonus {
+	return Bonus{
+		RuleID: TierRulePrefix + tier.Name,
+		Points: accrual.MulRatio(int64(tier.Factor.Sub(onePoint)), int64(onePoint)),
+	}
+}
+
+func parseWeekday(name string) (time.Weekday, bool) {
+	for weekday := time.Sunday; weekday <= time.Saturday; weekday++ {
+		if strings.EqualFold(weekday.String(), name) {
+			return weekday, true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,189 @@
+// Package eventbus delivers events of a user to the streams of this user inside the process.
+// It keeps the latest events, so a stream which was reconnected with Last-Event-ID gets the events it missed.
+package eventbus
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+)
+
+const subscriptionBuffer = 64
+
+// PublisherInterface is used by services, they don't need to know about subscribers.
+type PublisherInterface interface {
+	Publish(userID uuid.UUID, eventType string, data interface{})
+}
+
+type Bus struct {
+	mutex       sync.Mutex
+	lastID      uint64
+	history     []models.UserEvent
+	historySize int
+	subscribers map[uuid.UUID]map[*Subscription]struct{}
+	isClosed    bool
+	logger      logger.Logger
+}
+
+var _ PublisherInterface = &Bus{}
+
+type Subscription struct {
+	Events <-chan models.UserEvent
+
+	events chan models.UserEvent
+	userID uuid.UUID
+	bus    *Bus
+}
+
+// New creates the bus which keeps historySize latest events of all users, 0 - no history.
+// IDs of events start from the start time in microseconds, so IDs from a previous run of the process
+// are always older than the history and such streams get resync.
+func New(historySize int, logger logger.Logger) *Bus {
+	return &Bus{
+		lastID:      uint64(time.Now().UnixMicro()),
+		history:     make([]models.UserEvent, 0, historySize),
+		historySize: historySize,
+		subscribers: make(map[uuid.UUID]map[*Subscription]struct{}),
+		logger:      logger,
+	}
+}
+
+// Publish never blocks: a subscriber which doesn't read its events is dropped,
+// the client reconnects and gets the missed events by Last-Event-ID.
+func (bus *Bus) Publish(userID uuid.UUID, eventType string, data interface{}) {
+	encoded, errEncode := json.Marshal(data)
+	if errEncode != nil {
+		bus.logger.Errorf("---> ERROR: eventBus: encode %v event: %v", eventType, errEncode)
+		return
+	}
+
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	if bus.isClosed {
+		return
+	}
+
+	bus.lastID++
+
+	event := models.UserEvent{
+		ID:        bus.lastID,
+		UserID:    userID,
+		Type:      eventType,
+		Data:      encoded,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	// the bus without history, e.g. of a command, only passes the events to the subscribers
+	if bus.historySize > 0 {
+		if len(bus.history) == bus.historySize {
+			copy(bus.history, bus.history[1:])
+			bus.history = bus.history[:len(bus.history)-1]
+		}
+
+		bus.history = append(bus.history, event)
+	}
+
+	for subscription := range bus.subscribers[userID] {
+		select {
+		case subscription.events <- event:
+		default:
+			bus.logger.Warnf("=== eventBus: subscriber of user %v is too slow, it is dropped", userID)
+			bus.unsubscribe(subscription)
+		}
+	}
+}
+
+// Subscribe returns the subscription and the events of the user published after lastEventID;
+// 0 means a new stream without missed events. isComplete is false when some of the missed events
+// aren't in the history any more.
+func (bus *Bus) Subscribe(userID uuid.UUID, lastEventID uint64) (*Subscription, []models.UserEvent, bool) {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	events := make(chan models.UserEvent, subscriptionBuffer)
+
+	subscription := &Subscription{
+		Events: events,
+		events: events,
+		userID: userID,
+		bus:    bus,
+	}
+
+	if bus.isClosed {
+		close(events)
+		return subscription, nil, true
+	}
+
+	if bus.subscribers[userID] == nil {
+		bus.subscribers[userID] = make(map[*Subscription]struct{})
+	}
+
+	bus.subscribers[userID][subscription] = struct{}{}
+
+	if lastEventID == 0 {
+		return subscription, nil, true
+	}
+
+	oldestID := bus.lastID + 1
+	if len(bus.history) > 0 {
+		oldestID = bus.history[0].ID
+	}
+
+	isComplete := lastEventID <= bus.lastID && lastEventID+1 >= oldestID
+
+	missed := make([]models.UserEvent, 0)
+
+	for _, event := range bus.history {
+		if event.UserID == userID && event.ID > lastEventID {
+			missed = append(missed, event)
+		}
+	}
+
+	return subscription, missed, isComplete
+}
+
+func (subscription *Subscription) Close() {
+	subscription.bus.mutex.Lock()
+	defer subscription.bus.mutex.Unlock()
+
+	subscription.bus.unsubscribe(subscription)
+}
+
+// Close ends all streams, it is called on shutdown, so the server doesn't wait for them.
+func (bus *Bus) Close() {
+	bus.mutex.Lock()
+	defer bus.mutex.Unlock()
+
+	bus.isClosed = true
+
+	for _, subscriptions := range bus.subscribers {
+		for subscription := range subscriptions {
+			bus.unsubscribe(subscription)
+		}
+	}
+}
+
+// unsubscribe closes the channel of the subscription once. The mutex must be locked.
+func (bus *Bus) unsubscribe(subscription *Subscription) {
+	subscriptions, ok := bus.subscribers[subscription.userID]
+	if !ok {
+		return
+	}
+
+	if _, ok := subscriptions[subscription]; !ok {
+		return
+	}
+
+	delete(subscriptions, subscription)
+	close(subscription.events)
+
+	if len(subscriptions) == 0 {
+		delete(bus.subscribers, subscription.userID)
+	}
+}
@@ -0,0 +1,54 @@
+package logger
+
+import (
+	"context"
+	"sync"
+)
+
+type contextKey struct{}
+
+// contextFields are shared by the whole request: a middleware deeper in the chain, e.g. the one which
+// verifies the token, adds user_id, and it is seen by the middleware which logs the request at the end.
+type contextFields struct {
+	mutex  sync.RWMutex
+	fields Fields
+}
+
+// NewContext returns ctx which carries the fields of the request for WithContext.
+func NewContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, &contextFields{fields: make(Fields)})
+}
+
+// AddContextField adds the field to the request of ctx; without NewContext the field is dropped.
+func AddContextField(ctx context.Context, key string, value interface{}) {
+	holder, isFound := ctx.Value(contextKey{}).(*contextFields)
+	if !isFound {
+		return
+	}
+
+	holder.mutex.Lock()
+	defer holder.mutex.Unlock()
+
+	holder.fields[key] = value
+}
+
+func fieldsFromContext(ctx context.Context) Fields {
+	if ctx == nil {
+		return nil
+	}
+
+	holder, isFound := ctx.Value(contextKey{}).(*contextFields)
+	if !isFound {
+		return nil
+	}
+
+	holder.mutex.RLock()
+	defer holder.mutex.RUnlock()
+
+	fields := make(Fields, len(holder.fields))
+	for key, value := range holder.fields {
+		fields[key] = value
+	}
+
+	return fields
+}
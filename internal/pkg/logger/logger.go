@@ -1,9 +1,20 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+
 	"github.com/sirupsen/logrus"
 )
 
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Fields - structured data of a record, in the JSON format they are keys of the record.
+type Fields map[string]interface{}
+
 type Logger interface {
 	Debug(msg ...interface{})
 	Debugf(format string, args ...interface{})
@@ -13,14 +24,108 @@ type Logger interface {
 	Warnf(format string, args ...interface{})
 	Error(msg ...interface{})
 	Errorf(format string, args ...interface{})
+	// WithField returns a logger which adds the field to every record
+	WithField(key string, value interface{}) Logger
+	WithFields(fields Fields) Logger
+	// WithContext returns a logger which adds the fields of the request from ctx, e.g. request_id and user_id
+	WithContext(ctx context.Context) Logger
+}
+
+type logrusLogger struct {
+	entry *logrus.Entry
 }
 
+var _ Logger = &logrusLogger{}
+
+// Init creates the logger with the text format and the info level, Configure changes them.
+// Passwords, tokens and credentials of DSN are scrubbed from every record.
 func Init() Logger {
 	log := logrus.New()
-	log.SetFormatter(&logrus.TextFormatter{
+	log.SetFormatter(newFormatter(FormatText))
+	log.AddHook(redactionHook{})
+
+	return &logrusLogger{entry: logrus.NewEntry(log)}
+}
+
+// Configure sets the format (text or json) and the level (debug, info, warn or error),
+// it is safe to call while the service is running.
+func Configure(log Logger, format string, level string) error {
+	logrusLog, isLogrus := log.(*logrusLogger)
+	if !isLogrus {
+		return nil
+	}
+
+	parsedLevel, errLevel := logrus.ParseLevel(level)
+	if errLevel != nil {
+		return errLevel
+	}
+
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown format of log: %v", format)
+	}
+
+	logrusLog.entry.Logger.SetFormatter(newFormatter(format))
+	logrusLog.entry.Logger.SetLevel(parsedLevel)
+
+	return nil
+}
+
+func newFormatter(format string) logrus.Formatter {
+	if format == FormatJSON {
+		return &logrus.JSONFormatter{}
+	}
+
+	return &logrus.TextFormatter{
 		DisableColors: false,
 		FullTimestamp: true,
-	})
+	}
+}
+
+func (log *logrusLogger) WithField(key string, value interface{}) Logger {
+	return &logrusLogger{entry: log.entry.WithField(key, value)}
+}
+
+func (log *logrusLogger) WithFields(fields Fields) Logger {
+	return &logrusLogger{entry: log.entry.WithFields(logrus.Fields(fields))}
+}
+
+func (log *logrusLogger) WithContext(ctx context.Context) Logger {
+	fields := fieldsFromContext(ctx)
+	if len(fields) == 0 {
+		return log
+	}
+
+	return log.WithFields(fields)
+}
+
+func (log *logrusLogger) Debug(msg ...interface{}) {
+	log.entry.Debug(msg...)
+}
+
+func (log *logrusLogger) Debugf(format string, args ...interface{}) {
+	log.entry.Debugf(format, args...)
+}
+
+func (log *logrusLogger) Info(msg ...interface{}) {
+	log.entry.Info(msg...)
+}
+
+func (log *logrusLogger) Infof(format string, args ...interface{}) {
+	log.entry.Infof(format, args...)
+}
+
+func (log *logrusLogger) Warn(msg ...interface{}) {
+	log.entry.Warn(msg...)
+}
+
+func (log *logrusLogger) Warnf(format string, args ...interface{}) {
+	log.entry.Warnf(format, args...)
+}
+
+func (log *logrusLogger) Error(msg ...interface{}) {
+	log.entry.Error(msg...)
+}
 
-	return log
+func (log *logrusLogger) Errorf(format string, args ...interface{}) {
+	log.entry.Errorf(format, args...)
 }
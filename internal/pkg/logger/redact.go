@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+)
+
+// secretFieldNames - fields whose values are replaced entirely, whatever they look like.
+var secretFieldNames = []string{"password", "token", "secret", "authorization"}
+
+// redactionHook scrubs every record before it is formatted, so a secret doesn't leak
+// even when a caller logs a raw body or a DSN.
+type redactionHook struct{}
+
+func (hook redactionHook) Levels() []logrus.Level {
+	return logrus.AllLevels
+}
+
+func (hook redactionHook) Fire(entry *logrus.Entry) error {
+	entry.Message = utils.Redact(entry.Message)
+
+	for key, value := range entry.Data {
+		if isSecretField(key) {
+			entry.Data[key] = utils.RedactedValue
+			continue
+		}
+
+		switch typedValue := value.(type) {
+		case string:
+			entry.Data[key] = utils.Redact(typedValue)
+		case error:
+			entry.Data[key] = utils.Redact(typedValue.Error())
+		}
+	}
+
+	return nil
+}
+
+func isSecretField(key string) bool {
+	lowerKey := strings.ToLower(key)
+
+	for _, name := range secretFieldNames {
+		if strings.Contains(lowerKey, name) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const collectTimeout = 3 * time.Second
+
+var _ prometheus.Collector = &poolCollector{}
+
+// poolCollector reads statistics of the pool of connections at the time of scraping.
+type poolCollector struct {
+	pool *pgxpool.Pool
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquireCount      *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	emptyAcquireCount *prometheus.Desc
+	canceledAcquire   *prometheus.Desc
+}
+
+func NewPoolCollector(pool *pgxpool.Pool) *poolCollector {
+	desc := func(name string, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "db_pool", name), help, nil, nil)
+	}
+
+	return &poolCollector{
+		pool:              pool,
+		acquiredConns:     desc("acquired_connections", "Connections currently in use."),
+		idleConns:         desc("idle_connections", "Idle connections in the pool."),
+		totalConns:        desc("total_connections", "All connections in the pool."),
+		maxConns:          desc("max_connections", "Maximum size of the pool."),
+		acquireCount:      desc("acquire_total", "Successful acquires of connections."),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Total time spent acquiring connections."),
+		emptyAcquireCount: desc("empty_acquire_total", "Acquires which had to wait for a connection."),
+		canceledAcquire:   desc("canceled_acquire_total", "Acquires canceled by the context."),
+	}
+}
+
+func (collector *poolCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.acquiredConns
+	descs <- collector.idleConns
+	descs <- collector.totalConns
+	descs <- collector.maxConns
+	descs <- collector.acquireCount
+	descs <- collector.acquireDuration
+	descs <- collector.emptyAcquireCount
+	descs <- collector.canceledAcquire
+}
+
+func (collector *poolCollector) Collect(metrics chan<- prometheus.Metric) {
+	stat := collector.pool.Stat()
+
+	metrics <- prometheus.MustNewConstMetric(collector.acquiredConns, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	metrics <- prometheus.MustNewConstMetric(collector.idleConns, prometheus.GaugeValue, float64(stat.IdleConns()))
+	metrics <- prometheus.MustNewConstMetric(collector.totalConns, prometheus.GaugeValue, float64(stat.TotalConns()))
+	metrics <- prometheus.MustNewConstMetric(collector.maxConns, prometheus.GaugeValue, float64(stat.MaxConns()))
+	metrics <- prometheus.MustNewConstMetric(collector.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(collector.acquireDuration, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	metrics <- prometheus.MustNewConstMetric(collector.emptyAcquireCount, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+	metrics <- prometheus.MustNewConstMetric(collector.canceledAcquire, prometheus.CounterValue, float64(stat.CanceledAcquireCount()))
+}
+
+var _ prometheus.Collector = &ordersCollector{}
+
+// ordersCollector counts orders by status at the time of scraping,
+// so the numbers are right for every instance of the service and after restarts.
+type ordersCollector struct {
+	countByStatus func(ctx context.Context) (map[string]int64, error)
+	ordersDesc    *prometheus.Desc
+}
+
+func NewOrdersCollector(countByStatus func(ctx context.Context) (map[string]int64, error)) *ordersCollector {
+	return &ordersCollector{
+		countByStatus: countByStatus,
+		ordersDesc: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "orders", "by_status"),
+			"Number of orders by status.",
+			[]string{"status"},
+			nil,
+		),
+	}
+}
+
+func (collector *ordersCollector) Describe(descs chan<- *prometheus.Desc) {
+	descs <- collector.ordersDesc
+}
+
+func (collector *ordersCollector) Collect(metrics chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+
+	counts, err := collector.countByStatus(ctx)
+	if err != nil {
+		metrics <- prometheus.NewInvalidMetric(collector.ordersDesc, err)
+		return
+	}
+
+	for status, count := range counts {
+		metrics <- prometheus.MustNewConstMetric(collector.ordersDesc, prometheus.GaugeValue, float64(count), status)
+	}
+}
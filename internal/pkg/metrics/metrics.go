@@ -0,0 +1,131 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "gophermart"
+
+var (
+	AccrualThrottledTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "throttled_total",
+		Help:      "Number of 429 Too Many Requests responses from the accrual system.",
+	})
+
+	AccrualRateLimit = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "rate_limit_per_minute",
+		Help:      "Requests per minute allowed by the accrual system; 0 - no limit is known.",
+	})
+
+	AccrualPaused = promauto.NewGauge(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "paused",
+		Help:      "1 while requests to the accrual system are paused after 429 Too Many Requests.",
+	})
+
+	AccrualRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "requests_total",
+		Help:      "Requests to the accrual system by outcome: ok, no_content, too_many_requests, server_error, unexpected_status, timeout, error.",
+	}, []string{"outcome"})
+
+	AccrualRequestDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "accrual",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of requests to the accrual system.",
+		Buckets:   prometheus.DefBuckets,
+	})
+
+	HTTPRequestsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Handled HTTP requests by method, route pattern and status code.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Duration of HTTP requests by method and route pattern.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "db",
+		Name:      "query_duration_seconds",
+		Help:      "Duration of repository queries by repository and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"repository", "method"})
+
+	PointsAccruedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "accrued_total",
+		Help:      "Points credited to users for processed orders.",
+	})
+
+	PointsWithdrawnTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "withdrawn_total",
+		Help:      "Points withdrawn by users.",
+	})
+
+	PointsExpiredTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "expired_total",
+		Help:      "Points written off because they weren't spent during the expiration period.",
+	})
+
+	PointsBonusTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "bonus_total",
+		Help:      "Points given over the accrual by bonus rules.",
+	}, []string{"rule"})
+
+	PointsAdjustedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "adjusted_total",
+		Help:      "Points credited or debited manually by admins.",
+	}, []string{"type"})
+
+	PointsReversedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "points",
+		Name:      "reversed_total",
+		Help:      "Points debited or credited back by reversals of transactions.",
+	}, []string{"type"})
+
+	WebhookDeliveriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "webhook",
+		Name:      "deliveries_total",
+		Help:      "Attempts to deliver webhook events by outcome: delivered, failed, dead.",
+	}, []string{"outcome"})
+)
+
+// ObserveDBQuery starts measuring a repository query, call the returned function when the query is finished:
+//
+//	defer metrics.ObserveDBQuery("order", "Insert")()
+func ObserveDBQuery(repository string, method string) func() {
+	startedAt := time.Now()
+
+	return func() {
+		DBQueryDuration.WithLabelValues(repository, method).Observe(time.Since(startedAt).Seconds())
+	}
+}
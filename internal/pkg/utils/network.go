@@ -0,0 +1,21 @@
+package utils
+
+import (
+	"net"
+)
+
+// carrierGradeNAT - 100.64.0.0/10 of RFC 6598, it isn't reachable from the internet either.
+var carrierGradeNAT = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicIP returns false for loopback, private, link-local, multicast and unspecified addresses,
+// e.g. 127.0.0.1, 10.0.0.1, 169.254.169.254 of cloud metadata or ::.
+func IsPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() &&
+		!ip.IsPrivate() &&
+		!ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() &&
+		!ip.IsMulticast() &&
+		!ip.IsUnspecified() &&
+		!carrierGradeNAT.Contains(ip)
+}
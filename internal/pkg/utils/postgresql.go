@@ -1,9 +1,15 @@
 package utils
 
 import (
+	"errors"
 	"time"
+
+	"github.com/jackc/pgconn"
 )
 
+// uniqueViolationCode - SQLSTATE of a duplicate key.
+const uniqueViolationCode = "23505"
+
 func DoWithTries(fn func() error, attempts int, delay time.Duration) (err error) {
 	for attempts > 0 {
 		err = fn()
@@ -20,3 +26,9 @@ func DoWithTries(fn func() error, attempts int, delay time.Duration) (err error)
 
 	return err
 }
+
+func IsUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolationCode
+}
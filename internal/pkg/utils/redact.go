@@ -0,0 +1,49 @@
+package utils
+
+import "regexp"
+
+// RedactedValue replaces secrets in logs, errors and the printed config.
+const RedactedValue = "******"
+
+type redactionRule struct {
+	pattern     *regexp.Regexp
+	replacement string
+}
+
+// redactionRules scrub secrets from text: passwords and tokens in JSON bodies,
+// key=value pairs like DSN or query strings, credentials of URLs, bearer and JWT tokens.
+var redactionRules = []redactionRule{
+	{
+		pattern:     regexp.MustCompile(`(?i)("(?:password|token|access_token|refresh_token|secret|secret_key)"\s*:\s*)"(?:[^"\\]|\\.)*"`),
+		replacement: `${1}"` + RedactedValue + `"`,
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)\b((?:password|passwd|secret)\s*[=:]\s*)('[^']*'|[^\s&,;'"]+)`),
+		replacement: "${1}" + RedactedValue,
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)\b((?:access_|refresh_)?token=)[^\s&,;'"]+`),
+		replacement: "${1}" + RedactedValue,
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)\b([a-z][a-z0-9+.-]*://[^:/@\s]*:)[^@\s]*@`),
+		replacement: "${1}" + RedactedValue + "@",
+	},
+	{
+		pattern:     regexp.MustCompile(`(?i)\b(bearer\s+)[^\s"']+`),
+		replacement: "${1}" + RedactedValue,
+	},
+	{
+		pattern:     regexp.MustCompile(`\beyJ[A-Za-z0-9_-]*\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`),
+		replacement: RedactedValue,
+	},
+}
+
+// Redact scrubs passwords, tokens and credentials of DSN from text.
+func Redact(text string) string {
+	for _, rule := range redactionRules {
+		text = rule.pattern.ReplaceAllString(text, rule.replacement)
+	}
+
+	return text
+}
@@ -0,0 +1,213 @@
+package idempotencyrepository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type idempotencyRepository struct {
+	client  dbclient.ClientInterface
+	rwMutex *sync.RWMutex
+	logger  logger.Logger
+}
+
+var _ repository.IdempotencyRepositoryInterface = &idempotencyRepository{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *idempotencyRepository {
+	rwMutex := sync.RWMutex{}
+
+	idmRepository := idempotencyRepository{
+		client:  client,
+		rwMutex: &rwMutex,
+		logger:  logger,
+	}
+
+	return &idmRepository
+}
+
+// Insert reserves the key; it returns false when the key has already been reserved.
+func (rep *idempotencyRepository) Insert(ctx context.Context, idempotencyKey *models.IdempotencyKey) (bool, error) {
+	defer metrics.ObserveDBQuery("idempotency", "Insert")()
+
+	query := `INSERT INTO idempotency_keys (user_id, key, request_method, request_path, request_hash, created_at, lease_until)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			ON CONFLICT (user_id, key) DO NOTHING;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query,
+		idempotencyKey.UserID.String(),
+		idempotencyKey.Key,
+		idempotencyKey.RequestMethod,
+		idempotencyKey.RequestPath,
+		idempotencyKey.RequestHash,
+		idempotencyKey.CreatedAt,
+		idempotencyKey.LeaseUntil,
+	)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: insert idempotency key: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+func (rep *idempotencyRepository) Get(ctx context.Context, userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	defer metrics.ObserveDBQuery("idempotency", "Get")()
+
+	query := `SELECT user_id, key, request_method, request_path, request_hash,
+				COALESCE(status_code, 0), COALESCE(content_type, ''), response_body, created_at,
+				COALESCE(lease_until, created_at), committed_at
+			FROM idempotency_keys WHERE user_id = $1 AND key = $2;`
+
+	var idempotencyKey models.IdempotencyKey
+	var committedAt *time.Time
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), key).Scan(
+		&idempotencyKey.UserID,
+		&idempotencyKey.Key,
+		&idempotencyKey.RequestMethod,
+		&idempotencyKey.RequestPath,
+		&idempotencyKey.RequestHash,
+		&idempotencyKey.StatusCode,
+		&idempotencyKey.ContentType,
+		&idempotencyKey.ResponseBody,
+		&idempotencyKey.CreatedAt,
+		&idempotencyKey.LeaseUntil,
+		&committedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: get idempotency key: %v\n", err)
+
+		return nil, err
+	}
+
+	if committedAt != nil {
+		idempotencyKey.CommittedAt = *committedAt
+	}
+
+	return &idempotencyKey, nil
+}
+
+// TakeOver extends the lease of the key whose request in progress has been abandoned before the commit;
+// it returns false when the request has finished or committed, or another retry has taken the key over.
+func (rep *idempotencyRepository) TakeOver(
+	ctx context.Context,
+	userID uuid.UUID,
+	key string,
+	now time.Time,
+	leaseUntil time.Time,
+) (bool, error) {
+	defer metrics.ObserveDBQuery("idempotency", "TakeOver")()
+
+	query := `UPDATE idempotency_keys SET lease_until = $1
+			WHERE user_id = $2 AND key = $3 AND status_code IS NULL AND committed_at IS NULL
+				AND COALESCE(lease_until, created_at) < $4;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, leaseUntil, userID.String(), key, now)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: take over idempotency key: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// MarkCommitted is called in the transaction of the changes of the request, the first commit is kept.
+func (rep *idempotencyRepository) MarkCommitted(ctx context.Context, userID uuid.UUID, key string, committedAt time.Time) error {
+	defer metrics.ObserveDBQuery("idempotency", "MarkCommitted")()
+
+	query := `UPDATE idempotency_keys SET committed_at = COALESCE(committed_at, $1) WHERE user_id = $2 AND key = $3;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, committedAt, userID.String(), key)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: mark idempotency key committed: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (rep *idempotencyRepository) Complete(
+	ctx context.Context,
+	userID uuid.UUID,
+	key string,
+	statusCode int,
+	contentType string,
+	body []byte,
+) error {
+	defer metrics.ObserveDBQuery("idempotency", "Complete")()
+
+	query := `UPDATE idempotency_keys SET (status_code, content_type, response_body) = ($1, $2, $3)
+			WHERE user_id = $4 AND key = $5;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, statusCode, contentType, body, userID.String(), key)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: complete idempotency key: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (rep *idempotencyRepository) Delete(ctx context.Context, userID uuid.UUID, key string) error {
+	defer metrics.ObserveDBQuery("idempotency", "Delete")()
+
+	query := `DELETE FROM idempotency_keys WHERE user_id = $1 AND key = $2;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, userID.String(), key)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: delete idempotency key: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (rep *idempotencyRepository) DeleteExpired(ctx context.Context, createdBefore time.Time) error {
+	defer metrics.ObserveDBQuery("idempotency", "DeleteExpired")()
+
+	query := `DELETE FROM idempotency_keys WHERE created_at < $1;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, createdBefore)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: delete expired idempotency keys: %v\n", err)
+		return err
+	}
+
+	return nil
+}
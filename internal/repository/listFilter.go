@@ -0,0 +1,75 @@
+package repository
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/lexizz/cumloys/internal/models"
+)
+
+// ListColumns - columns of the query which models.ListFilter is applied to.
+type ListColumns struct {
+	CreatedAt string
+	ID        string
+	// Status - empty when rows have no status
+	Status string
+}
+
+// ListQuery returns conditions, ORDER BY and LIMIT of filter to be appended to a query
+// which already has a WHERE clause with len(args) parameters.
+// LIMIT fetches one row more than the page to know whether the next page exists, see NextPage.
+func ListQuery(filter models.ListFilter, columns ListColumns, args []interface{}) (string, []interface{}) {
+	var query strings.Builder
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		query.WriteString(" AND " + fmt.Sprintf(condition, len(args)))
+	}
+
+	if len(filter.Statuses) > 0 && len(columns.Status) > 0 {
+		addCondition(columns.Status+"::text = ANY($%d)", filter.Statuses)
+	}
+
+	if filter.From != nil {
+		addCondition(columns.CreatedAt+" >= $%d", *filter.From)
+	}
+
+	if filter.To != nil {
+		addCondition(columns.CreatedAt+" < $%d", *filter.To)
+	}
+
+	direction := "ASC"
+	comparison := ">"
+
+	if filter.IsDesc() {
+		direction = "DESC"
+		comparison = "<"
+	}
+
+	if filter.Cursor != nil {
+		args = append(args, filter.Cursor.CreatedAt, filter.Cursor.ID.String())
+		query.WriteString(fmt.Sprintf(" AND (%v, %v) %v ($%d, $%d)",
+			columns.CreatedAt, columns.ID, comparison, len(args)-1, len(args)))
+	}
+
+	query.WriteString(fmt.Sprintf(" ORDER BY %v %v, %v %v", columns.CreatedAt, direction, columns.ID, direction))
+
+	if filter.Limit > 0 {
+		args = append(args, filter.Limit+1)
+		query.WriteString(fmt.Sprintf(" LIMIT $%d", len(args)))
+	}
+
+	return query.String(), args
+}
+
+// NextPage takes cursors of the rows fetched by ListQuery and returns the number of rows on the page
+// and the cursor of the next page, nil when it is the last one.
+func NextPage(filter models.ListFilter, cursors []models.Cursor) (int, *models.Cursor) {
+	if filter.Limit <= 0 || len(cursors) <= filter.Limit {
+		return len(cursors), nil
+	}
+
+	next := cursors[filter.Limit-1]
+
+	return filter.Limit, &next
+}
@@ -0,0 +1,146 @@
+package memoryrepository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type idempotencyRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.IdempotencyRepositoryInterface = &idempotencyRepository{}
+
+func NewIdempotencyRepository(storage *Storage, logger logger.Logger) *idempotencyRepository {
+	return &idempotencyRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *idempotencyRepository) Insert(_ context.Context, idempotencyKey *models.IdempotencyKey) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	id := idempotencyKeyID{userID: idempotencyKey.UserID, key: idempotencyKey.Key}
+
+	if _, ok := rep.storage.tables.idempotencyKeys[id]; ok {
+		return false, nil
+	}
+
+	rep.storage.tables.idempotencyKeys[id] = models.IdempotencyKey{
+		UserID:        idempotencyKey.UserID,
+		Key:           idempotencyKey.Key,
+		RequestMethod: idempotencyKey.RequestMethod,
+		RequestPath:   idempotencyKey.RequestPath,
+		RequestHash:   idempotencyKey.RequestHash,
+		CreatedAt:     idempotencyKey.CreatedAt,
+		LeaseUntil:    idempotencyKey.LeaseUntil,
+	}
+
+	return true, nil
+}
+
+func (rep *idempotencyRepository) Get(_ context.Context, userID uuid.UUID, key string) (*models.IdempotencyKey, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	idempotencyKey, ok := rep.storage.tables.idempotencyKeys[idempotencyKeyID{userID: userID, key: key}]
+	if !ok {
+		return nil, nil
+	}
+
+	return &idempotencyKey, nil
+}
+
+func (rep *idempotencyRepository) TakeOver(
+	_ context.Context,
+	userID uuid.UUID,
+	key string,
+	now time.Time,
+	leaseUntil time.Time,
+) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	id := idempotencyKeyID{userID: userID, key: key}
+
+	idempotencyKey, ok := rep.storage.tables.idempotencyKeys[id]
+	if !ok || !idempotencyKey.IsAbandoned(now) || idempotencyKey.IsCommitted() {
+		return false, nil
+	}
+
+	idempotencyKey.LeaseUntil = leaseUntil
+
+	rep.storage.tables.idempotencyKeys[id] = idempotencyKey
+
+	return true, nil
+}
+
+func (rep *idempotencyRepository) MarkCommitted(_ context.Context, userID uuid.UUID, key string, committedAt time.Time) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	id := idempotencyKeyID{userID: userID, key: key}
+
+	idempotencyKey, ok := rep.storage.tables.idempotencyKeys[id]
+	if !ok || idempotencyKey.IsCommitted() {
+		return nil
+	}
+
+	idempotencyKey.CommittedAt = committedAt
+
+	rep.storage.tables.idempotencyKeys[id] = idempotencyKey
+
+	return nil
+}
+
+func (rep *idempotencyRepository) Complete(
+	_ context.Context,
+	userID uuid.UUID,
+	key string,
+	statusCode int,
+	contentType string,
+	body []byte,
+) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	id := idempotencyKeyID{userID: userID, key: key}
+
+	idempotencyKey, ok := rep.storage.tables.idempotencyKeys[id]
+	if !ok {
+		return nil
+	}
+
+	idempotencyKey.StatusCode = statusCode
+	idempotencyKey.ContentType = contentType
+	idempotencyKey.ResponseBody = append([]byte(nil), body...)
+
+	rep.storage.tables.idempotencyKeys[id] = idempotencyKey
+
+	return nil
+}
+
+func (rep *idempotencyRepository) Delete(_ context.Context, userID uuid.UUID, key string) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	delete(rep.storage.tables.idempotencyKeys, idempotencyKeyID{userID: userID, key: key})
+
+	return nil
+}
+
+func (rep *idempotencyRepository) DeleteExpired(_ context.Context, createdBefore time.Time) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	for id, idempotencyKey := range rep.storage.tables.idempotencyKeys {
+		if idempotencyKey.CreatedAt.Before(createdBefore) {
+			delete(rep.storage.tables.idempotencyKeys, id)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,274 @@
+package memoryrepository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type orderRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.OrderRepositoryInterface = &orderRepository{}
+
+func NewOrderRepository(storage *Storage, logger logger.Logger) *orderRepository {
+	return &orderRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *orderRepository) IsExists(_ context.Context, number string) (bool, *uuid.UUID, *uuid.UUID, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	orderID, ok := rep.storage.tables.orderIDsByNum[number]
+	if !ok {
+		return false, nil, nil, nil
+	}
+
+	userID := rep.storage.tables.orders[orderID].order.UserID
+
+	return true, &orderID, &userID, nil
+}
+
+func (rep *orderRepository) GetAllByUserID(
+	_ context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.Order, *models.Cursor, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	orders := make([]models.Order, 0)
+
+	for _, row := range rep.storage.tables.orders {
+		if row.order.UserID != userID || !filter.Matches(row.order.CreatedAt, row.order.ID, row.order.Status) {
+			continue
+		}
+
+		orders = append(orders, row.order)
+	}
+
+	sort.Slice(orders, func(i, j int) bool {
+		if filter.IsDesc() {
+			i, j = j, i
+		}
+
+		return models.IsBeforeCursor(orders[i].CreatedAt, orders[i].ID, models.Cursor{
+			CreatedAt: orders[j].CreatedAt,
+			ID:        orders[j].ID,
+		})
+	})
+
+	cursors := make([]models.Cursor, 0, len(orders))
+
+	for index := range orders {
+		cursors = append(cursors, models.Cursor{CreatedAt: orders[index].CreatedAt, ID: orders[index].ID})
+		orders[index].UpdatedAt = truncateToSeconds(orders[index].UpdatedAt)
+	}
+
+	size, next := repository.NextPage(filter, cursors)
+
+	return orders[:size], next, nil
+}
+
+func (rep *orderRepository) Insert(_ context.Context, number string, userID uuid.UUID, isWithdrawal bool) (*uuid.UUID, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	if _, ok := rep.storage.tables.orderIDsByNum[number]; ok {
+		rep.logger.Errorf("---> ERROR: insert order: %v\n", repository.ErrDuplicateOrder)
+		return nil, repository.ErrDuplicateOrder
+	}
+
+	currentDatetime := utils.GetCurrentDatetimeUTC()
+
+	order := models.Order{
+		ID:           uuid.New(),
+		Number:       number,
+		UserID:       userID,
+		Status:       models.OrderStatusNew,
+		IsWithdrawal: isWithdrawal,
+		CreatedAt:    currentDatetime,
+		UpdatedAt:    currentDatetime,
+	}
+
+	rep.storage.tables.orders[order.ID] = orderRow{order: order}
+	rep.storage.tables.orderIDsByNum[number] = order.ID
+
+	rep.logger.Info("====== Insert Order: OK ======")
+
+	return &order.ID, nil
+}
+
+func (rep *orderRepository) Update(_ context.Context, number string, userID uuid.UUID, status string, points models.Points) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	orderID, ok := rep.storage.tables.orderIDsByNum[number]
+	if !ok {
+		return repository.ErrOrderFinalized
+	}
+
+	row := rep.storage.tables.orders[orderID]
+	if row.order.UserID != userID || models.IsFinalOrderStatus(row.order.Status) {
+		return repository.ErrOrderFinalized
+	}
+
+	row.order.Status = status
+	row.order.Points = points
+	row.order.UpdatedAt = utils.GetCurrentDatetimeUTC()
+
+	rep.storage.tables.orders[orderID] = row
+
+	return nil
+}
+
+// ClaimForPolling works like the PostgreSQL version: orders are leased until leaseUntil.
+func (rep *orderRepository) ClaimForPolling(_ context.Context, limit int, leaseUntil time.Time) ([]models.Order, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	now := utils.GetCurrentDatetimeUTC()
+
+	rows := make([]orderRow, 0)
+
+	for _, row := range rep.storage.tables.orders {
+		if models.IsFinalOrderStatus(row.order.Status) || row.order.IsWithdrawal {
+			continue
+		}
+
+		if row.nextPollAt != nil && row.nextPollAt.After(now) {
+			continue
+		}
+
+		rows = append(rows, row)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		switch {
+		case rows[i].nextPollAt == nil && rows[j].nextPollAt != nil:
+			return true
+		case rows[i].nextPollAt != nil && rows[j].nextPollAt == nil:
+			return false
+		case rows[i].nextPollAt != nil && !rows[i].nextPollAt.Equal(*rows[j].nextPollAt):
+			return rows[i].nextPollAt.Before(*rows[j].nextPollAt)
+		default:
+			return rows[i].order.CreatedAt.Before(rows[j].order.CreatedAt)
+		}
+	})
+
+	if len(rows) > limit {
+		rows = rows[:limit]
+	}
+
+	orders := make([]models.Order, 0, len(rows))
+
+	for _, row := range rows {
+		lease := leaseUntil
+		row.nextPollAt = &lease
+
+		rep.storage.tables.orders[row.order.ID] = row
+
+		orders = append(orders, row.order)
+	}
+
+	return orders, nil
+}
+
+func (rep *orderRepository) SchedulePolling(
+	_ context.Context,
+	orderID uuid.UUID,
+	nextPollAt time.Time,
+	isAnswered bool,
+) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	row, ok := rep.storage.tables.orders[orderID]
+	if !ok {
+		return nil
+	}
+
+	row.order.PollAttempts++
+	row.nextPollAt = &nextPollAt
+
+	if isAnswered {
+		row.order.AnsweredPolls++
+	}
+
+	rep.storage.tables.orders[orderID] = row
+
+	return nil
+}
+
+func (rep *orderRepository) ResetPolling(_ context.Context, numbers []string) (int64, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	var count int64
+
+	for orderID, row := range rep.storage.tables.orders {
+		if models.IsFinalOrderStatus(row.order.Status) {
+			continue
+		}
+
+		if len(numbers) > 0 && !containsNumber(numbers, row.order.Number) {
+			continue
+		}
+
+		row.order.PollAttempts = 0
+		row.order.AnsweredPolls = 0
+		row.nextPollAt = nil
+
+		rep.storage.tables.orders[orderID] = row
+		count++
+	}
+
+	return count, nil
+}
+
+func (rep *orderRepository) CountByUserIDAndStatus(_ context.Context, userID uuid.UUID, status string) (int, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	count := 0
+
+	for _, row := range rep.storage.tables.orders {
+		if row.order.UserID == userID && row.order.Status == status {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func (rep *orderRepository) CountByStatus(_ context.Context) (map[string]int64, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	counts := map[string]int64{
+		models.OrderStatusNew:        0,
+		models.OrderStatusProcessing: 0,
+		models.OrderStatusInvalid:    0,
+		models.OrderStatusProcessed:  0,
+	}
+
+	for _, row := range rep.storage.tables.orders {
+		counts[row.order.Status]++
+	}
+
+	return counts, nil
+}
+
+func containsNumber(numbers []string, number string) bool {
+	for _, current := range numbers {
+		if current == number {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,71 @@
+package memoryrepository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type outboxRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.OutboxRepositoryInterface = &outboxRepository{}
+
+func NewOutboxRepository(storage *Storage, logger logger.Logger) *outboxRepository {
+	return &outboxRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *outboxRepository) Insert(_ context.Context, event *models.OutboxEvent) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	rep.storage.tables.outboxEvents = append(rep.storage.tables.outboxEvents, outboxRow{event: *event})
+
+	return nil
+}
+
+func (rep *outboxRepository) FanOut(_ context.Context, limit int) (int, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	now := utils.GetCurrentDatetimeUTC()
+	dispatched := 0
+
+	for index, row := range rep.storage.tables.outboxEvents {
+		if dispatched >= limit {
+			break
+		}
+
+		if row.isDispatched {
+			continue
+		}
+
+		for _, webhook := range rep.storage.tables.webhooksByCreation(row.event.UserID) {
+			delivery := models.WebhookDelivery{
+				ID:            uuid.New(),
+				WebhookID:     webhook.ID,
+				Event:         row.event,
+				Status:        models.DeliveryStatusPending,
+				NextAttemptAt: now,
+				CreatedAt:     now,
+				UpdatedAt:     now,
+			}
+
+			rep.storage.tables.deliveries[delivery.ID] = delivery
+		}
+
+		rep.storage.tables.outboxEvents[index].isDispatched = true
+		dispatched++
+	}
+
+	return dispatched, nil
+}
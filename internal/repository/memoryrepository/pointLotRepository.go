@@ -0,0 +1,175 @@
+package memoryrepository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+// pointLotRepository keeps lots in the order they are earned, so the oldest lots come first.
+type pointLotRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.PointLotRepositoryInterface = &pointLotRepository{}
+
+func NewPointLotRepository(storage *Storage, logger logger.Logger) *pointLotRepository {
+	return &pointLotRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *pointLotRepository) Insert(_ context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	rep.storage.tables.pointLots = append(rep.storage.tables.pointLots, models.PointLot{
+		ID:        uuid.New(),
+		UserID:    userID,
+		OrderID:   orderID,
+		Points:    points,
+		Remaining: points,
+		EarnedAt:  utils.GetCurrentDatetimeUTC(),
+	})
+
+	return nil
+}
+
+func (rep *pointLotRepository) Consume(_ context.Context, userID uuid.UUID, points models.Points) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	for index, lot := range rep.storage.tables.pointLots {
+		if points <= 0 {
+			break
+		}
+
+		if lot.UserID != userID || lot.Remaining <= 0 {
+			continue
+		}
+
+		consumed := lot.Remaining
+		if consumed > points {
+			consumed = points
+		}
+
+		lot.Remaining = lot.Remaining.Sub(consumed)
+		points = points.Sub(consumed)
+
+		rep.storage.tables.pointLots[index] = lot
+	}
+
+	return nil
+}
+
+func (rep *pointLotRepository) ConsumeOrder(
+	_ context.Context,
+	userID uuid.UUID,
+	orderID uuid.UUID,
+	points models.Points,
+) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	var consumedTotal models.Points
+
+	for index, lot := range rep.storage.tables.pointLots {
+		if points <= 0 {
+			break
+		}
+
+		if lot.UserID != userID || lot.OrderID != orderID || lot.Remaining <= 0 {
+			continue
+		}
+
+		consumed := lot.Remaining
+		if consumed > points {
+			consumed = points
+		}
+
+		lot.Remaining = lot.Remaining.Sub(consumed)
+		points = points.Sub(consumed)
+		consumedTotal = consumedTotal.Add(consumed)
+
+		rep.storage.tables.pointLots[index] = lot
+	}
+
+	return consumedTotal, nil
+}
+
+func (rep *pointLotRepository) Expire(_ context.Context, userID uuid.UUID, earnedBefore time.Time) ([]models.PointLot, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	lots := make([]models.PointLot, 0)
+
+	for index, lot := range rep.storage.tables.pointLots {
+		if lot.UserID != userID || !isExpiredLot(lot, earnedBefore) {
+			continue
+		}
+
+		lots = append(lots, lot)
+
+		lot.Remaining = 0
+		rep.storage.tables.pointLots[index] = lot
+	}
+
+	return lots, nil
+}
+
+func (rep *pointLotRepository) GetUserIDsWithExpired(_ context.Context, earnedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	userIDs := make([]uuid.UUID, 0)
+	isAdded := make(map[uuid.UUID]bool)
+
+	for _, lot := range rep.storage.tables.pointLots {
+		if len(userIDs) >= limit {
+			break
+		}
+
+		if isAdded[lot.UserID] || !isExpiredLot(lot, earnedBefore) {
+			continue
+		}
+
+		isAdded[lot.UserID] = true
+		userIDs = append(userIDs, lot.UserID)
+	}
+
+	return userIDs, nil
+}
+
+func (rep *pointLotRepository) GetExpiring(
+	_ context.Context,
+	userID uuid.UUID,
+	earnedBefore time.Time,
+) (models.Points, *time.Time, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	var points models.Points
+	var oldestEarnedAt *time.Time
+
+	for _, lot := range rep.storage.tables.pointLots {
+		if lot.UserID != userID || !isExpiredLot(lot, earnedBefore) {
+			continue
+		}
+
+		if oldestEarnedAt == nil {
+			earnedAt := lot.EarnedAt
+			oldestEarnedAt = &earnedAt
+		}
+
+		points = points.Add(lot.Remaining)
+	}
+
+	return points, oldestEarnedAt, nil
+}
+
+func isExpiredLot(lot models.PointLot, earnedBefore time.Time) bool {
+	return lot.Remaining > 0 && lot.EarnedAt.Before(earnedBefore)
+}
@@ -0,0 +1,28 @@
+package memoryrepository
+
+import (
+	"context"
+
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+// schemaRepository reports the in-memory storage as always reachable and up to date.
+type schemaRepository struct {
+	migrationVersion uint
+}
+
+var _ repository.SchemaRepositoryInterface = &schemaRepository{}
+
+func NewSchemaRepository(migrationVersion uint) *schemaRepository {
+	return &schemaRepository{
+		migrationVersion: migrationVersion,
+	}
+}
+
+func (rep *schemaRepository) Ping(_ context.Context) error {
+	return nil
+}
+
+func (rep *schemaRepository) GetMigrationVersion(_ context.Context) (uint, bool, error) {
+	return rep.migrationVersion, false, nil
+}
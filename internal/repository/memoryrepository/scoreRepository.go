@@ -0,0 +1,143 @@
+package memoryrepository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+var ErrDuplicateScore = errors.New("score of the user already exists")
+
+type scoreRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.ScoreRepositoryInterface = &scoreRepository{}
+
+func NewScoreRepository(storage *Storage, logger logger.Logger) *scoreRepository {
+	return &scoreRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *scoreRepository) GetScoreByUserID(_ context.Context, userID uuid.UUID) (*models.Score, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	score, ok := rep.storage.tables.scores[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &score, nil
+}
+
+// GetScoreByUserIDForUpdate is GetScoreByUserID: inside a unit of work the whole storage is locked anyway.
+func (rep *scoreRepository) GetScoreByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (*models.Score, error) {
+	return rep.GetScoreByUserID(ctx, userID)
+}
+
+func (rep *scoreRepository) Insert(_ context.Context, userID uuid.UUID, points models.Points) (*uuid.UUID, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	if _, ok := rep.storage.tables.scores[userID]; ok {
+		rep.logger.Errorf("---> ERROR: failed insert score: %v\n", ErrDuplicateScore)
+		return nil, ErrDuplicateScore
+	}
+
+	score := rep.storage.tables.newScore(userID, points)
+
+	rep.logger.Info("====== Insert Score: OK ======")
+
+	return &score.ID, nil
+}
+
+func (rep *scoreRepository) Update(_ context.Context, userID uuid.UUID, points models.Points) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	score, ok := rep.storage.tables.scores[userID]
+	if !ok {
+		return nil
+	}
+
+	score.Total = points
+	score.UpdatedAt = utils.GetCurrentDatetimeUTC()
+
+	rep.storage.tables.scores[userID] = score
+
+	return nil
+}
+
+func (rep *scoreRepository) Increase(_ context.Context, userID uuid.UUID, points models.Points) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	score, ok := rep.storage.tables.scores[userID]
+	if !ok {
+		return rep.storage.tables.newScore(userID, points).Total, nil
+	}
+
+	score.Total = score.Total.Add(points)
+	score.UpdatedAt = utils.GetCurrentDatetimeUTC()
+
+	rep.storage.tables.scores[userID] = score
+
+	return score.Total, nil
+}
+
+func (rep *scoreRepository) Decrease(_ context.Context, userID uuid.UUID, points models.Points) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	score, ok := rep.storage.tables.scores[userID]
+	if !ok || score.Total < points {
+		return 0, repository.ErrInsufficientFunds
+	}
+
+	score.Total = score.Total.Sub(points)
+	score.UpdatedAt = utils.GetCurrentDatetimeUTC()
+
+	rep.storage.tables.scores[userID] = score
+
+	return score.Total, nil
+}
+
+func (rep *scoreRepository) Set(_ context.Context, userID uuid.UUID, points models.Points) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	score, ok := rep.storage.tables.scores[userID]
+	if !ok {
+		rep.storage.tables.newScore(userID, points)
+		return nil
+	}
+
+	score.Total = points
+	score.UpdatedAt = utils.GetCurrentDatetimeUTC()
+
+	rep.storage.tables.scores[userID] = score
+
+	return nil
+}
+
+// newScore inserts the score of the user. The mutex must be locked.
+func (tbl *tables) newScore(userID uuid.UUID, points models.Points) models.Score {
+	currentDatetime := utils.GetCurrentDatetimeUTC()
+
+	score := models.Score{
+		ID:        uuid.New(),
+		Total:     points,
+		UserID:    userID,
+		CreatedAt: currentDatetime,
+		UpdatedAt: currentDatetime,
+	}
+
+	tbl.scores[userID] = score
+
+	return score
+}
@@ -0,0 +1,140 @@
+package memoryrepository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+var ErrDuplicateRefreshToken = errors.New("refresh token already exists")
+
+type sessionRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.SessionRepositoryInterface = &sessionRepository{}
+
+func NewSessionRepository(storage *Storage, logger logger.Logger) *sessionRepository {
+	return &sessionRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *sessionRepository) Insert(_ context.Context, userID uuid.UUID) (*uuid.UUID, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	session := models.Session{
+		ID:        uuid.New(),
+		UserID:    userID,
+		CreatedAt: utils.GetCurrentDatetimeUTC(),
+	}
+
+	rep.storage.tables.sessions[session.ID] = session
+
+	return &session.ID, nil
+}
+
+func (rep *sessionRepository) GetByID(_ context.Context, sessionID uuid.UUID) (*models.Session, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	session, ok := rep.storage.tables.sessions[sessionID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &session, nil
+}
+
+func (rep *sessionRepository) Revoke(_ context.Context, sessionID uuid.UUID) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	session, ok := rep.storage.tables.sessions[sessionID]
+	if !ok || session.IsRevoked() {
+		return nil
+	}
+
+	revokedAt := utils.GetCurrentDatetimeUTC()
+	session.RevokedAt = &revokedAt
+
+	rep.storage.tables.sessions[sessionID] = session
+
+	return nil
+}
+
+func (rep *sessionRepository) RevokeAllByUserID(_ context.Context, userID uuid.UUID) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	revokedAt := utils.GetCurrentDatetimeUTC()
+
+	for sessionID, session := range rep.storage.tables.sessions {
+		if session.UserID != userID || session.IsRevoked() {
+			continue
+		}
+
+		session.RevokedAt = &revokedAt
+		rep.storage.tables.sessions[sessionID] = session
+	}
+
+	return nil
+}
+
+func (rep *sessionRepository) InsertRefreshToken(_ context.Context, sessionID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	for _, refreshToken := range rep.storage.tables.refreshTokens {
+		if refreshToken.TokenHash == tokenHash {
+			rep.logger.Errorf("---> ERROR: insert refresh token: %v\n", ErrDuplicateRefreshToken)
+			return ErrDuplicateRefreshToken
+		}
+	}
+
+	refreshToken := models.RefreshToken{
+		ID:        uuid.New(),
+		SessionID: sessionID,
+		TokenHash: tokenHash,
+		CreatedAt: utils.GetCurrentDatetimeUTC(),
+		ExpiresAt: expiresAt,
+	}
+
+	rep.storage.tables.refreshTokens[refreshToken.ID] = refreshToken
+
+	return nil
+}
+
+func (rep *sessionRepository) GetRefreshToken(_ context.Context, tokenHash string) (*models.RefreshToken, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	for _, refreshToken := range rep.storage.tables.refreshTokens {
+		if refreshToken.TokenHash == tokenHash {
+			return &refreshToken, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (rep *sessionRepository) MarkRefreshTokenUsed(_ context.Context, refreshTokenID uuid.UUID) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	refreshToken, ok := rep.storage.tables.refreshTokens[refreshTokenID]
+	if !ok || refreshToken.UsedAt != nil {
+		return false, nil
+	}
+
+	usedAt := utils.GetCurrentDatetimeUTC()
+	refreshToken.UsedAt = &usedAt
+
+	rep.storage.tables.refreshTokens[refreshTokenID] = refreshToken
+
+	return true, nil
+}
@@ -0,0 +1,120 @@
+// Package memoryrepository is the in-memory backend selected by STORAGE=memory.
+// It has the same uniqueness rules and errors as the PostgreSQL repositories,
+// so services behave the same way in tests and demos without a database.
+package memoryrepository
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+)
+
+// Storage keeps the tables of the in-memory backend. Every call of a repository locks it,
+// a unit of work holds the lock until the end and restores the snapshot on error.
+type Storage struct {
+	mutex  sync.Mutex
+	tables *tables
+}
+
+type idempotencyKeyID struct {
+	userID uuid.UUID
+	key    string
+}
+
+type orderRow struct {
+	order      models.Order
+	nextPollAt *time.Time
+}
+
+type outboxRow struct {
+	event        models.OutboxEvent
+	isDispatched bool
+}
+
+type tables struct {
+	users           map[uuid.UUID]models.User
+	userIDsByLogin  map[string]uuid.UUID
+	orders          map[uuid.UUID]orderRow
+	orderIDsByNum   map[string]uuid.UUID
+	scores          map[uuid.UUID]models.Score
+	transactions    []models.Transaction
+	idempotencyKeys map[idempotencyKeyID]models.IdempotencyKey
+	sessions        map[uuid.UUID]models.Session
+	refreshTokens   map[uuid.UUID]models.RefreshToken
+	webhooks        map[uuid.UUID]models.Webhook
+	outboxEvents    []outboxRow
+	deliveries      map[uuid.UUID]models.WebhookDelivery
+	pointLots       []models.PointLot
+	userTiers       map[uuid.UUID]models.UserTier
+}
+
+func NewStorage() *Storage {
+	return &Storage{
+		tables: &tables{
+			users:           make(map[uuid.UUID]models.User),
+			userIDsByLogin:  make(map[string]uuid.UUID),
+			orders:          make(map[uuid.UUID]orderRow),
+			orderIDsByNum:   make(map[string]uuid.UUID),
+			scores:          make(map[uuid.UUID]models.Score),
+			transactions:    make([]models.Transaction, 0),
+			idempotencyKeys: make(map[idempotencyKeyID]models.IdempotencyKey),
+			sessions:        make(map[uuid.UUID]models.Session),
+			refreshTokens:   make(map[uuid.UUID]models.RefreshToken),
+			webhooks:        make(map[uuid.UUID]models.Webhook),
+			outboxEvents:    make([]outboxRow, 0),
+			deliveries:      make(map[uuid.UUID]models.WebhookDelivery),
+			pointLots:       make([]models.PointLot, 0),
+			userTiers:       make(map[uuid.UUID]models.UserTier),
+		},
+	}
+}
+
+// lock locks the storage unless the repository works inside a unit of work, which already holds the lock.
+func (storage *Storage) lock(isTransaction bool) func() {
+	if isTransaction {
+		return func() {}
+	}
+
+	storage.mutex.Lock()
+
+	return storage.mutex.Unlock
+}
+
+// clone copies the tables for rollback. Rows are values, so copying the maps is enough:
+// repositories replace rows and never change them in place.
+func (tbl *tables) clone() *tables {
+	return &tables{
+		users:           cloneMap(tbl.users),
+		userIDsByLogin:  cloneMap(tbl.userIDsByLogin),
+		orders:          cloneMap(tbl.orders),
+		orderIDsByNum:   cloneMap(tbl.orderIDsByNum),
+		scores:          cloneMap(tbl.scores),
+		transactions:    append(make([]models.Transaction, 0, len(tbl.transactions)), tbl.transactions...),
+		idempotencyKeys: cloneMap(tbl.idempotencyKeys),
+		sessions:        cloneMap(tbl.sessions),
+		refreshTokens:   cloneMap(tbl.refreshTokens),
+		webhooks:        cloneMap(tbl.webhooks),
+		outboxEvents:    append(make([]outboxRow, 0, len(tbl.outboxEvents)), tbl.outboxEvents...),
+		deliveries:      cloneMap(tbl.deliveries),
+		pointLots:       append(make([]models.PointLot, 0, len(tbl.pointLots)), tbl.pointLots...),
+		userTiers:       cloneMap(tbl.userTiers),
+	}
+}
+
+func cloneMap[K comparable, V any](source map[K]V) map[K]V {
+	result := make(map[K]V, len(source))
+
+	for key, value := range source {
+		result[key] = value
+	}
+
+	return result
+}
+
+// truncateToSeconds repeats the conversion of dates which the PostgreSQL repositories do for the API.
+func truncateToSeconds(datetime time.Time) time.Time {
+	return time.Unix(datetime.Unix(), 0).UTC()
+}
@@ -0,0 +1,45 @@
+package memoryrepository
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type tierRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.TierRepositoryInterface = &tierRepository{}
+
+func NewTierRepository(storage *Storage, logger logger.Logger) *tierRepository {
+	return &tierRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *tierRepository) Get(_ context.Context, userID uuid.UUID) (*models.UserTier, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	userTier, ok := rep.storage.tables.userTiers[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &userTier, nil
+}
+
+func (rep *tierRepository) Save(_ context.Context, userTier *models.UserTier) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	rep.storage.tables.userTiers[userTier.UserID] = *userTier
+
+	return nil
+}
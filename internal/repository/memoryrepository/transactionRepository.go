@@ -0,0 +1,364 @@
+package memoryrepository
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type transactionRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.TransactionRepositoryInterface = &transactionRepository{}
+
+func NewTransactionRepository(storage *Storage, logger logger.Logger) *transactionRepository {
+	return &transactionRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *transactionRepository) Insert(_ context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points, typeTransaction int) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	rep.storage.tables.insertTransaction(models.Transaction{
+		UserID:  userID,
+		OrderID: orderID,
+		Points:  points,
+		Type:    typeTransaction,
+	})
+
+	rep.logger.Info("====== Insert Transaction: OK ======")
+
+	return nil
+}
+
+func (rep *transactionRepository) InsertBonus(_ context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points, ruleID string) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	rep.storage.tables.insertTransaction(models.Transaction{
+		UserID:  userID,
+		OrderID: orderID,
+		Points:  points,
+		Type:    models.BonusPointsType,
+		RuleID:  ruleID,
+	})
+
+	return nil
+}
+
+func (rep *transactionRepository) InsertAdjustment(
+	_ context.Context,
+	userID uuid.UUID,
+	points models.Points,
+	typeTransaction int,
+	reason string,
+	operatorID uuid.UUID,
+) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	rep.storage.tables.insertTransaction(models.Transaction{
+		UserID:     userID,
+		Points:     points,
+		Type:       typeTransaction,
+		Reason:     reason,
+		OperatorID: &operatorID,
+	})
+
+	return nil
+}
+
+func (rep *transactionRepository) InsertReversal(
+	_ context.Context,
+	original models.Transaction,
+	points models.Points,
+	reason string,
+	operatorID *uuid.UUID,
+) (*models.Transaction, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	typeTransaction, isReversible := models.ReversalType(original.Type)
+	if !isReversible {
+		return nil, fmt.Errorf("transaction of type %d can't be reversed", original.Type)
+	}
+
+	if rep.storage.tables.reversalOf(original.ID) != nil {
+		return nil, repository.ErrAlreadyReversed
+	}
+
+	reversal := rep.storage.tables.insertTransaction(models.Transaction{
+		UserID:     original.UserID,
+		OrderID:    original.OrderID,
+		Points:     points,
+		Type:       typeTransaction,
+		Reason:     reason,
+		OperatorID: operatorID,
+		ReversalOf: &original.ID,
+	})
+
+	reversal = rep.storage.tables.withReadFields(reversal)
+
+	return &reversal, nil
+}
+
+func (rep *transactionRepository) GetByID(_ context.Context, transactionID uuid.UUID) (*models.Transaction, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.ID == transactionID {
+			transaction = rep.storage.tables.withReadFields(transaction)
+			return &transaction, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (rep *transactionRepository) GetAllByOrderID(_ context.Context, orderID uuid.UUID) ([]models.Transaction, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	transactions := make([]models.Transaction, 0)
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.OrderID == orderID && orderID != uuid.Nil {
+			transactions = append(transactions, rep.storage.tables.withReadFields(transaction))
+		}
+	}
+
+	sortTransactions(transactions, models.ListFilter{})
+
+	return transactions, nil
+}
+
+func (rep *transactionRepository) GetSumBonus(_ context.Context, userID uuid.UUID, ruleID string, since time.Time) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	var bonusPoints models.Points
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.UserID == userID &&
+			transaction.Type == models.BonusPointsType &&
+			transaction.RuleID == ruleID &&
+			!transaction.CreatedAt.Before(since) &&
+			!rep.storage.tables.isReversed(transaction.ID) {
+			bonusPoints = bonusPoints.Add(transaction.Points)
+		}
+	}
+
+	return bonusPoints, nil
+}
+
+func (rep *transactionRepository) GetSumAccrued(_ context.Context, userID uuid.UUID, since time.Time) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	var accruedPoints models.Points
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.UserID == userID &&
+			transaction.Type == models.IncreasePointsType &&
+			!transaction.CreatedAt.Before(since) &&
+			!rep.storage.tables.isReversed(transaction.ID) {
+			accruedPoints = accruedPoints.Add(transaction.Points)
+		}
+	}
+
+	return accruedPoints, nil
+}
+
+func (rep *transactionRepository) GetSumFundsWithdrawn(_ context.Context, userID uuid.UUID) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	var withdrawPoints models.Points
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.UserID == userID &&
+			transaction.Type == models.DecreasePointsType &&
+			!rep.storage.tables.isReversed(transaction.ID) {
+			withdrawPoints = withdrawPoints.Add(transaction.Points)
+		}
+	}
+
+	return withdrawPoints, nil
+}
+
+func (rep *transactionRepository) GetAllFundsWithdrawn(
+	_ context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.ScoreWithdraw, *models.Cursor, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	transactions := make([]models.Transaction, 0)
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.UserID != userID ||
+			transaction.Type != models.DecreasePointsType ||
+			rep.storage.tables.isReversed(transaction.ID) {
+			continue
+		}
+
+		if _, ok := rep.storage.tables.orders[transaction.OrderID]; !ok {
+			continue
+		}
+
+		if !filter.Matches(transaction.CreatedAt, transaction.ID, "") {
+			continue
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	sortTransactions(transactions, filter)
+
+	scoreWithdraws := make([]models.ScoreWithdraw, 0, len(transactions))
+	cursors := make([]models.Cursor, 0, len(transactions))
+
+	for _, transaction := range transactions {
+		scoreWithdraws = append(scoreWithdraws, models.ScoreWithdraw{
+			NumberOrder: rep.storage.tables.orders[transaction.OrderID].order.Number,
+			SumWithdraw: transaction.Points,
+			CreatedAt:   truncateToSeconds(transaction.CreatedAt),
+		})
+		cursors = append(cursors, models.Cursor{CreatedAt: transaction.CreatedAt, ID: transaction.ID})
+	}
+
+	size, next := repository.NextPage(filter, cursors)
+
+	return scoreWithdraws[:size], next, nil
+}
+
+func (rep *transactionRepository) GetAllByUserID(
+	_ context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.Transaction, *models.Cursor, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	transactions := make([]models.Transaction, 0)
+
+	for _, transaction := range rep.storage.tables.transactions {
+		if transaction.UserID != userID || !filter.Matches(transaction.CreatedAt, transaction.ID, "") {
+			continue
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	sortTransactions(transactions, filter)
+
+	cursors := make([]models.Cursor, 0, len(transactions))
+
+	for index, transaction := range transactions {
+		cursors = append(cursors, models.Cursor{CreatedAt: transaction.CreatedAt, ID: transaction.ID})
+		transactions[index] = rep.storage.tables.withReadFields(transaction)
+	}
+
+	size, next := repository.NextPage(filter, cursors)
+
+	return transactions[:size], next, nil
+}
+
+func (rep *transactionRepository) GetLedgerBalance(_ context.Context, userID uuid.UUID) (models.Points, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	return rep.storage.tables.ledgerBalance(userID), nil
+}
+
+func (rep *transactionRepository) GetAllLedgerBalances(_ context.Context) ([]models.LedgerBalance, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	users := rep.storage.tables.usersByCreation()
+	balances := make([]models.LedgerBalance, 0, len(users))
+
+	for _, user := range users {
+		balances = append(balances, models.LedgerBalance{
+			UserID:      user.ID,
+			ScoreTotal:  rep.storage.tables.scores[user.ID].Total,
+			LedgerTotal: rep.storage.tables.ledgerBalance(user.ID),
+		})
+	}
+
+	return balances, nil
+}
+
+// sortTransactions orders the transactions the same way as ORDER BY created_at, id in the direction of the filter.
+func sortTransactions(transactions []models.Transaction, filter models.ListFilter) {
+	sort.Slice(transactions, func(i, j int) bool {
+		if filter.IsDesc() {
+			i, j = j, i
+		}
+
+		return models.IsBeforeCursor(transactions[i].CreatedAt, transactions[i].ID, models.Cursor{
+			CreatedAt: transactions[j].CreatedAt,
+			ID:        transactions[j].ID,
+		})
+	})
+}
+
+// insertTransaction appends the transaction to the ledger, balance_after continues the ledger of the user.
+// The mutex must be locked.
+func (tbl *tables) insertTransaction(transaction models.Transaction) models.Transaction {
+	transaction.ID = uuid.New()
+	transaction.BalanceAfter = tbl.ledgerBalance(transaction.UserID).Add(models.SignedPoints(transaction.Points, transaction.Type))
+	transaction.CreatedAt = utils.GetCurrentDatetimeUTC()
+
+	tbl.transactions = append(tbl.transactions, transaction)
+
+	return transaction
+}
+
+// reversalOf returns the transaction which reverses the transaction with transactionID. The mutex must be locked.
+func (tbl *tables) reversalOf(transactionID uuid.UUID) *models.Transaction {
+	for index := range tbl.transactions {
+		reversalOf := tbl.transactions[index].ReversalOf
+		if reversalOf != nil && *reversalOf == transactionID {
+			return &tbl.transactions[index]
+		}
+	}
+
+	return nil
+}
+
+func (tbl *tables) isReversed(transactionID uuid.UUID) bool {
+	return tbl.reversalOf(transactionID) != nil
+}
+
+// withReadFields fills the fields which Postgres reads by joins: the number of the order and the reversal.
+// The mutex must be locked.
+func (tbl *tables) withReadFields(transaction models.Transaction) models.Transaction {
+	if row, ok := tbl.orders[transaction.OrderID]; ok {
+		transaction.OrderNumber = row.order.Number
+	}
+
+	if reversal := tbl.reversalOf(transaction.ID); reversal != nil {
+		transaction.ReversedBy = &reversal.ID
+	}
+
+	transaction.CreatedAt = truncateToSeconds(transaction.CreatedAt)
+
+	return transaction
+}
+
+// ledgerBalance sums the ledger of the user. The mutex must be locked.
+func (tbl *tables) ledgerBalance(userID uuid.UUID) models.Points {
+	var balance models.Points
+
+	for _, transaction := range tbl.transactions {
+		if transaction.UserID == userID {
+			balance = balance.Add(models.SignedPoints(transaction.Points, transaction.Type))
+		}
+	}
+
+	return balance
+}
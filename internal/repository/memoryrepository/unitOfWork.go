@@ -0,0 +1,57 @@
+package memoryrepository
+
+import (
+	"context"
+
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+// unitOfWork holds the lock of the storage while fn runs, so units of work are serializable,
+// and restores the snapshot of the tables when fn fails.
+type unitOfWork struct {
+	storage *Storage
+	logger  logger.Logger
+}
+
+var _ repository.UnitOfWorkInterface = &unitOfWork{}
+
+func NewUnitOfWork(storage *Storage, logger logger.Logger) *unitOfWork {
+	return &unitOfWork{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (uow *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repositories *repository.Repositories) error) error {
+	uow.storage.mutex.Lock()
+	defer uow.storage.mutex.Unlock()
+
+	if errCtx := ctx.Err(); errCtx != nil {
+		return errCtx
+	}
+
+	snapshot := uow.storage.tables.clone()
+
+	repositories := &repository.Repositories{
+		User:        &userRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+		Order:       &orderRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+		Score:       &scoreRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+		Transaction: &transactionRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+		Outbox:      &outboxRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+		PointLot:    &pointLotRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+		Idempotency: &idempotencyRepository{storage: uow.storage, isTransaction: true, logger: uow.logger},
+	}
+
+	errFn := fn(ctx, repositories)
+	if errFn == nil {
+		errFn = repositories.MarkIdempotencyKeyCommitted(ctx)
+	}
+
+	if errFn != nil {
+		uow.storage.tables = snapshot
+		return errFn
+	}
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package memoryrepository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type userRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.UserRepositoryInterface = &userRepository{}
+
+func NewUserRepository(storage *Storage, logger logger.Logger) *userRepository {
+	return &userRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *userRepository) IsExists(_ context.Context, login string) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	_, ok := rep.storage.tables.userIDsByLogin[login]
+
+	return ok, nil
+}
+
+func (rep *userRepository) GetUserByLogin(_ context.Context, login string) (*models.User, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	userID, ok := rep.storage.tables.userIDsByLogin[login]
+	if !ok {
+		return nil, nil
+	}
+
+	user := rep.storage.tables.users[userID]
+
+	return &user, nil
+}
+
+func (rep *userRepository) Insert(_ context.Context, newLogin string, newPassword string) (*uuid.UUID, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	if _, ok := rep.storage.tables.userIDsByLogin[newLogin]; ok {
+		rep.logger.Errorf("---> ERROR: insert new user: %v\n", repository.ErrDuplicateLogin)
+		return nil, repository.ErrDuplicateLogin
+	}
+
+	user := models.User{
+		ID:        uuid.New(),
+		Login:     newLogin,
+		Password:  newPassword,
+		Role:      models.RoleUser,
+		CreatedAt: utils.GetCurrentDatetimeUTC(),
+	}
+
+	rep.storage.tables.users[user.ID] = user
+	rep.storage.tables.userIDsByLogin[user.Login] = user.ID
+
+	rep.logger.Info("====== Insert User: OK ======")
+
+	return &user.ID, nil
+}
+
+func (rep *userRepository) GetByID(_ context.Context, userID uuid.UUID) (*models.User, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	user, ok := rep.storage.tables.users[userID]
+	if !ok {
+		return nil, nil
+	}
+
+	return &user, nil
+}
+
+func (rep *userRepository) Search(_ context.Context, login string, limit int) ([]models.User, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	users := make([]models.User, 0)
+
+	for _, user := range rep.storage.tables.users {
+		if strings.Contains(strings.ToLower(user.Login), strings.ToLower(login)) {
+			users = append(users, user)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return users[i].Login < users[j].Login
+	})
+
+	if len(users) > limit {
+		users = users[:limit]
+	}
+
+	return users, nil
+}
+
+func (rep *userRepository) SetBlocked(_ context.Context, userID uuid.UUID, blockedAt *time.Time) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	user, ok := rep.storage.tables.users[userID]
+	if !ok {
+		return false, nil
+	}
+
+	user.BlockedAt = blockedAt
+
+	rep.storage.tables.users[userID] = user
+
+	return true, nil
+}
+
+func (rep *userRepository) SetPassword(_ context.Context, userID uuid.UUID, password string) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	user, ok := rep.storage.tables.users[userID]
+	if !ok {
+		return false, nil
+	}
+
+	user.Password = password
+
+	rep.storage.tables.users[userID] = user
+
+	return true, nil
+}
+
+func (rep *userRepository) SetRole(_ context.Context, login string, role string) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	userID, ok := rep.storage.tables.userIDsByLogin[login]
+	if !ok {
+		return false, nil
+	}
+
+	user := rep.storage.tables.users[userID]
+	user.Role = role
+
+	rep.storage.tables.users[userID] = user
+
+	return true, nil
+}
+
+// usersByCreation returns users in the order of registration. The mutex must be locked.
+func (tbl *tables) usersByCreation() []models.User {
+	users := make([]models.User, 0, len(tbl.users))
+
+	for _, user := range tbl.users {
+		users = append(users, user)
+	}
+
+	sort.SliceStable(users, func(i, j int) bool {
+		return users[i].CreatedAt.Before(users[j].CreatedAt)
+	})
+
+	return users
+}
@@ -0,0 +1,188 @@
+package memoryrepository
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type webhookRepository struct {
+	storage       *Storage
+	isTransaction bool
+	logger        logger.Logger
+}
+
+var _ repository.WebhookRepositoryInterface = &webhookRepository{}
+
+func NewWebhookRepository(storage *Storage, logger logger.Logger) *webhookRepository {
+	return &webhookRepository{
+		storage: storage,
+		logger:  logger,
+	}
+}
+
+func (rep *webhookRepository) Insert(_ context.Context, webhook *models.Webhook) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	webhook.ID = uuid.New()
+
+	rep.storage.tables.webhooks[webhook.ID] = *webhook
+
+	return nil
+}
+
+func (rep *webhookRepository) GetAllByUserID(_ context.Context, userID uuid.UUID) ([]models.Webhook, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	webhooks := rep.storage.tables.webhooksByCreation(userID)
+
+	for index := range webhooks {
+		webhooks[index].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// Delete also removes deliveries of the webhook like ON DELETE CASCADE does.
+func (rep *webhookRepository) Delete(_ context.Context, userID uuid.UUID, webhookID uuid.UUID) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	webhook, ok := rep.storage.tables.webhooks[webhookID]
+	if !ok || webhook.UserID != userID {
+		return false, nil
+	}
+
+	delete(rep.storage.tables.webhooks, webhookID)
+
+	for deliveryID, delivery := range rep.storage.tables.deliveries {
+		if delivery.WebhookID == webhookID {
+			delete(rep.storage.tables.deliveries, deliveryID)
+		}
+	}
+
+	return true, nil
+}
+
+func (rep *webhookRepository) ClaimDeliveries(_ context.Context, limit int, leaseUntil time.Time) ([]models.WebhookDelivery, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	now := utils.GetCurrentDatetimeUTC()
+
+	deliveries := rep.storage.tables.deliveriesWhere(func(delivery models.WebhookDelivery) bool {
+		return delivery.Status == models.DeliveryStatusPending && !delivery.NextAttemptAt.After(now)
+	})
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].NextAttemptAt.Before(deliveries[j].NextAttemptAt)
+	})
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	for index := range deliveries {
+		deliveries[index].NextAttemptAt = leaseUntil
+
+		stored := rep.storage.tables.deliveries[deliveries[index].ID]
+		stored.NextAttemptAt = leaseUntil
+		rep.storage.tables.deliveries[stored.ID] = stored
+	}
+
+	return deliveries, nil
+}
+
+func (rep *webhookRepository) UpdateDelivery(_ context.Context, delivery *models.WebhookDelivery) error {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	stored, ok := rep.storage.tables.deliveries[delivery.ID]
+	if !ok {
+		return nil
+	}
+
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.LastStatusCode = delivery.LastStatusCode
+	stored.LastError = delivery.LastError
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.UpdatedAt = utils.GetCurrentDatetimeUTC()
+
+	rep.storage.tables.deliveries[stored.ID] = stored
+
+	return nil
+}
+
+func (rep *webhookRepository) GetDeliveriesByUserID(_ context.Context, userID uuid.UUID, status string) ([]models.WebhookDelivery, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	deliveries := rep.storage.tables.deliveriesWhere(func(delivery models.WebhookDelivery) bool {
+		return delivery.Event.UserID == userID && (len(status) == 0 || delivery.Status == status)
+	})
+
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.Before(deliveries[j].CreatedAt)
+	})
+
+	return deliveries, nil
+}
+
+func (rep *webhookRepository) Redeliver(_ context.Context, userID uuid.UUID, deliveryID uuid.UUID) (bool, error) {
+	defer rep.storage.lock(rep.isTransaction)()
+
+	delivery, ok := rep.storage.tables.deliveries[deliveryID]
+	if !ok || delivery.Event.UserID != userID || delivery.Status != models.DeliveryStatusDead {
+		return false, nil
+	}
+
+	now := utils.GetCurrentDatetimeUTC()
+
+	delivery.Status = models.DeliveryStatusPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now
+	delivery.UpdatedAt = now
+
+	rep.storage.tables.deliveries[deliveryID] = delivery
+
+	return true, nil
+}
+
+func (tbl *tables) webhooksByCreation(userID uuid.UUID) []models.Webhook {
+	webhooks := make([]models.Webhook, 0)
+
+	for _, webhook := range tbl.webhooks {
+		if webhook.UserID == userID {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+
+	sort.SliceStable(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks
+}
+
+// deliveriesWhere returns matching deliveries with the url and the secret of their webhooks.
+func (tbl *tables) deliveriesWhere(isMatching func(delivery models.WebhookDelivery) bool) []models.WebhookDelivery {
+	deliveries := make([]models.WebhookDelivery, 0)
+
+	for _, delivery := range tbl.deliveries {
+		if !isMatching(delivery) {
+			continue
+		}
+
+		webhook := tbl.webhooks[delivery.WebhookID]
+		delivery.URL = webhook.URL
+		delivery.Secret = webhook.Secret
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/lexizz/cumloys/internal/db/dbclient"
 	"github.com/lexizz/cumloys/internal/models"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 )
@@ -39,6 +40,8 @@ func New(client dbclient.ClientInterface, logger logger.Logger) *orderRepository
 }
 
 func (rep *orderRepository) IsExists(ctx context.Context, number string) (bool, *uuid.UUID, *uuid.UUID, error) {
+	defer metrics.ObserveDBQuery("order", "IsExists")()
+
 	query := `SELECT id, user_id FROM orders WHERE number = $1;`
 
 	var orderID uuid.UUID
@@ -70,32 +73,47 @@ func (rep *orderRepository) IsExists(ctx context.Context, number string) (bool,
 	return true, &orderID, &userID, nil
 }
 
-func (rep *orderRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]models.Order, error) {
-	query := `SELECT number, status, points, updated_at 
+// GetAllByUserID returns the page of orders of the user and the cursor of the next page.
+func (rep *orderRepository) GetAllByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.Order, *models.Cursor, error) {
+	defer metrics.ObserveDBQuery("order", "GetAllByUserID")()
+
+	conditions, args := repository.ListQuery(filter, repository.ListColumns{
+		CreatedAt: "created_at",
+		ID:        "id",
+		Status:    "status",
+	}, []interface{}{userID.String()})
+
+	query := `SELECT id, number, status, points, created_at, updated_at 
 			FROM orders 
-			WHERE user_id = $1 
-			ORDER BY created_at DESC;`
+			WHERE user_id = $1` + conditions + `;`
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
 
-	rows, errQuery := rep.client.Query(ctx, query, userID.String())
+	rows, errQuery := rep.client.Query(ctx, query, args...)
 	if errQuery != nil {
 		rep.logger.Errorf("---> ERROR: orderRepository: query in GetAllByUserID: %v\n", errQuery)
-		return nil, errQuery
+		return nil, nil, errQuery
 	}
 
 	defer rows.Close()
 
 	orders := make([]models.Order, 0)
+	cursors := make([]models.Cursor, 0)
 
 	for rows.Next() {
 		var order models.Order
 
 		err := rows.Scan(
+			&order.ID,
 			&order.Number,
 			&order.Status,
 			&order.Points,
+			&order.CreatedAt,
 			&order.UpdatedAt,
 		)
 		if err != nil {
@@ -110,7 +128,7 @@ func (rep *orderRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID
 
 			rep.logger.Errorf(errorMessage)
 
-			return nil, err
+			return nil, nil, err
 		}
 
 		newTime := time.Unix(order.UpdatedAt.Unix(), 0).Format(time.RFC3339)
@@ -123,19 +141,24 @@ func (rep *orderRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID
 		order.UpdatedAt = newUpdatedAt
 
 		orders = append(orders, order)
+		cursors = append(cursors, models.Cursor{CreatedAt: order.CreatedAt, ID: order.ID})
 	}
 
 	if errRows := rows.Err(); errRows != nil {
 		rep.logger.Errorf("---> ERROR: GetAllByUserID: rows next: %v\n", errRows)
-		return nil, errRows
+		return nil, nil, errRows
 	}
 
-	return orders, nil
+	size, next := repository.NextPage(filter, cursors)
+
+	return orders[:size], next, nil
 }
 
-func (rep *orderRepository) Insert(ctx context.Context, number string, userID uuid.UUID) (*uuid.UUID, error) {
-	query := `INSERT INTO orders (number, user_id, created_at, updated_at) 
-				VALUES ($1, $2, $3, $4) RETURNING id`
+func (rep *orderRepository) Insert(ctx context.Context, number string, userID uuid.UUID, isWithdrawal bool) (*uuid.UUID, error) {
+	defer metrics.ObserveDBQuery("order", "Insert")()
+
+	query := `INSERT INTO orders (number, user_id, is_withdrawal, created_at, updated_at) 
+				VALUES ($1, $2, $3, $4, $5) RETURNING id`
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
@@ -150,6 +173,7 @@ func (rep *orderRepository) Insert(ctx context.Context, number string, userID uu
 		query,
 		number,
 		userID.String(),
+		isWithdrawal,
 		currentDatetime,
 		currentDatetime,
 	).Scan(&lastInsert)
@@ -165,6 +189,10 @@ func (rep *orderRepository) Insert(ctx context.Context, number string, userID uu
 
 		rep.logger.Errorf(errorMessage)
 
+		if utils.IsUniqueViolation(err) {
+			return nil, repository.ErrDuplicateOrder
+		}
+
 		return nil, err
 	}
 
@@ -178,13 +206,16 @@ func (rep *orderRepository) Insert(ctx context.Context, number string, userID uu
 	return &lastInsertID, nil
 }
 
-func (rep *orderRepository) Update(ctx context.Context, number string, userID uuid.UUID, status string, points float32) error {
-	query := `UPDATE orders SET (status, points, updated_at) = ($1, $2, $3) WHERE number = $4 AND user_id = $5;`
+func (rep *orderRepository) Update(ctx context.Context, number string, userID uuid.UUID, status string, points models.Points) error {
+	defer metrics.ObserveDBQuery("order", "Update")()
+
+	query := `UPDATE orders SET (status, points, updated_at) = ($1, $2, $3)
+			WHERE number = $4 AND user_id = $5 AND status IN ('NEW', 'PROCESSING');`
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
 
-	_, err := rep.client.Exec(ctx, query, status, points, utils.GetCurrentDatetimeUTC(), number, userID.String())
+	commandTag, err := rep.client.Exec(ctx, query, status, points, utils.GetCurrentDatetimeUTC(), number, userID.String())
 	if err != nil {
 		var pgErr pgconn.PgError
 
@@ -200,5 +231,176 @@ func (rep *orderRepository) Update(ctx context.Context, number string, userID uu
 		return err
 	}
 
+	if commandTag.RowsAffected() == 0 {
+		return repository.ErrOrderFinalized
+	}
+
 	return nil
 }
+
+// ClaimForPolling selects orders with a non-final status whose time for polling has come
+// and moves their next_poll_at to leaseUntil, so other workers don't take them at the same time.
+// Orders created by withdrawals aren't polled, the accrual system has no points for them.
+func (rep *orderRepository) ClaimForPolling(ctx context.Context, limit int, leaseUntil time.Time) ([]models.Order, error) {
+	defer metrics.ObserveDBQuery("order", "ClaimForPolling")()
+
+	query := `UPDATE orders SET next_poll_at = $1
+			WHERE id IN (
+				SELECT id FROM orders
+				WHERE status IN ('NEW', 'PROCESSING') AND NOT is_withdrawal AND (next_poll_at IS NULL OR next_poll_at <= $2)
+				ORDER BY next_poll_at ASC NULLS FIRST, created_at ASC
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			)
+			RETURNING id, number, user_id, status, points, poll_attempts, answered_polls, created_at, updated_at;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, leaseUntil, utils.GetCurrentDatetimeUTC(), limit)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: orderRepository: query in ClaimForPolling: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	orders := make([]models.Order, 0, limit)
+
+	for rows.Next() {
+		var order models.Order
+
+		err := rows.Scan(
+			&order.ID,
+			&order.Number,
+			&order.UserID,
+			&order.Status,
+			&order.Points,
+			&order.PollAttempts,
+			&order.AnsweredPolls,
+			&order.CreatedAt,
+			&order.UpdatedAt,
+		)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: ClaimForPolling: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		orders = append(orders, order)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: ClaimForPolling: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return orders, nil
+}
+
+func (rep *orderRepository) SchedulePolling(
+	ctx context.Context,
+	orderID uuid.UUID,
+	nextPollAt time.Time,
+	isAnswered bool,
+) error {
+	defer metrics.ObserveDBQuery("order", "SchedulePolling")()
+
+	query := `UPDATE orders SET (poll_attempts, answered_polls, next_poll_at) =
+				(poll_attempts + 1, answered_polls + CASE WHEN $1 THEN 1 ELSE 0 END, $2)
+			WHERE id = $3;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, isAnswered, nextPollAt, orderID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: failed schedule polling of order: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (rep *orderRepository) ResetPolling(ctx context.Context, numbers []string) (int64, error) {
+	defer metrics.ObserveDBQuery("order", "ResetPolling")()
+
+	query := `UPDATE orders SET (poll_attempts, answered_polls, next_poll_at) = (0, 0, NULL)
+			WHERE status IN ('NEW', 'PROCESSING') AND (cardinality($1::varchar[]) = 0 OR number = ANY($1));`
+
+	if numbers == nil {
+		numbers = []string{}
+	}
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, numbers)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: failed reset polling of orders: %v\n", err)
+		return 0, err
+	}
+
+	return commandTag.RowsAffected(), nil
+}
+
+// CountByUserIDAndStatus returns the number of orders of the user with the status.
+func (rep *orderRepository) CountByUserIDAndStatus(ctx context.Context, userID uuid.UUID, status string) (int, error) {
+	defer metrics.ObserveDBQuery("order", "CountByUserIDAndStatus")()
+
+	query := `SELECT COUNT(*) FROM orders WHERE user_id = $1 AND status = $2;`
+
+	var count int
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), status).Scan(&count)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: CountByUserIDAndStatus: %v\n", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// CountByStatus returns the number of orders for every status, also for statuses without orders.
+func (rep *orderRepository) CountByStatus(ctx context.Context) (map[string]int64, error) {
+	defer metrics.ObserveDBQuery("order", "CountByStatus")()
+
+	query := `SELECT status, COUNT(*) FROM orders GROUP BY status;`
+
+	counts := map[string]int64{
+		models.OrderStatusNew:        0,
+		models.OrderStatusProcessing: 0,
+		models.OrderStatusInvalid:    0,
+		models.OrderStatusProcessed:  0,
+	}
+
+	rows, errQuery := rep.client.Query(ctx, query)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: orderRepository: query in CountByStatus: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var status string
+		var count int64
+
+		err := rows.Scan(&status, &count)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: CountByStatus: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		counts[status] = count
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: CountByStatus: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,80 @@
+package outboxrepository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type outboxRepository struct {
+	client  dbclient.ClientInterface
+	rwMutex *sync.RWMutex
+	logger  logger.Logger
+}
+
+var _ repository.OutboxRepositoryInterface = &outboxRepository{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *outboxRepository {
+	rwMutex := sync.RWMutex{}
+
+	outRepository := outboxRepository{
+		client:  client,
+		rwMutex: &rwMutex,
+		logger:  logger,
+	}
+
+	return &outRepository
+}
+
+func (rep *outboxRepository) Insert(ctx context.Context, event *models.OutboxEvent) error {
+	defer metrics.ObserveDBQuery("outbox", "Insert")()
+
+	query := `INSERT INTO outbox_events (id, user_id, type, data, created_at) VALUES ($1, $2, $3, $4, $5)`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, event.ID.String(), event.UserID.String(), event.Type, string(event.Data), event.CreatedAt)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: insert outbox event: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// FanOut locks the events, so concurrent dispatchers never create deliveries of the same event twice.
+func (rep *outboxRepository) FanOut(ctx context.Context, limit int) (int, error) {
+	defer metrics.ObserveDBQuery("outbox", "FanOut")()
+
+	query := `WITH events AS (
+				SELECT id, user_id FROM outbox_events
+				WHERE dispatched_at IS NULL
+				ORDER BY created_at ASC
+				LIMIT $1
+				FOR UPDATE SKIP LOCKED
+			), deliveries AS (
+				INSERT INTO webhook_deliveries (event_id, webhook_id, next_attempt_at, created_at, updated_at)
+				SELECT e.id, w.id, $2, $2, $2
+				FROM events AS e
+				INNER JOIN webhooks AS w ON w.user_id = e.user_id
+			)
+			UPDATE outbox_events SET dispatched_at = $2 WHERE id IN (SELECT id FROM events);`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, limit, utils.GetCurrentDatetimeUTC())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: fan out outbox events: %v\n", err)
+		return 0, err
+	}
+
+	return int(commandTag.RowsAffected()), nil
+}
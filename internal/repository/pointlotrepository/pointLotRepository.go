@@ -0,0 +1,227 @@
+package pointlotrepository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type pointLotRepository struct {
+	client  dbclient.ClientInterface
+	rwMutex *sync.RWMutex
+	logger  logger.Logger
+}
+
+var _ repository.PointLotRepositoryInterface = &pointLotRepository{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *pointLotRepository {
+	rwMutex := sync.RWMutex{}
+
+	lotRepository := pointLotRepository{
+		client:  client,
+		rwMutex: &rwMutex,
+		logger:  logger,
+	}
+
+	return &lotRepository
+}
+
+func (rep *pointLotRepository) Insert(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points) error {
+	defer metrics.ObserveDBQuery("pointLot", "Insert")()
+
+	query := `INSERT INTO point_lots (user_id, order_id, points, remaining, earned_at, updated_at)
+			VALUES ($1, $2, $3, $3, $4, $4)`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, userID.String(), orderID.String(), points, utils.GetCurrentDatetimeUTC())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: insert point lot: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// Consume takes points from the oldest lots first: a lot keeps what is left of the running total
+// of the lots up to it after the points are taken.
+func (rep *pointLotRepository) Consume(ctx context.Context, userID uuid.UUID, points models.Points) error {
+	defer metrics.ObserveDBQuery("pointLot", "Consume")()
+
+	query := `WITH lots AS (
+				SELECT id, remaining, SUM(remaining) OVER (ORDER BY earned_at, id) AS running_total
+				FROM point_lots
+				WHERE user_id = $1 AND remaining > 0
+			)
+			UPDATE point_lots AS p
+			SET remaining = LEAST(l.remaining, GREATEST(0, l.running_total - $2)), updated_at = $3
+			FROM lots AS l
+			WHERE p.id = l.id AND l.running_total - l.remaining < $2`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, userID.String(), points, utils.GetCurrentDatetimeUTC())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: consume point lots: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeOrder works like Consume on the lots of the order only.
+func (rep *pointLotRepository) ConsumeOrder(
+	ctx context.Context,
+	userID uuid.UUID,
+	orderID uuid.UUID,
+	points models.Points,
+) (models.Points, error) {
+	defer metrics.ObserveDBQuery("pointLot", "ConsumeOrder")()
+
+	query := `WITH lots AS (
+				SELECT id, remaining, SUM(remaining) OVER (ORDER BY earned_at, id) AS running_total
+				FROM point_lots
+				WHERE user_id = $1 AND order_id = $2 AND remaining > 0
+			), consumed AS (
+				UPDATE point_lots AS p
+				SET remaining = LEAST(l.remaining, GREATEST(0, l.running_total - $3)), updated_at = $4
+				FROM lots AS l
+				WHERE p.id = l.id AND l.running_total - l.remaining < $3
+				RETURNING l.remaining - p.remaining AS points
+			)
+			SELECT COALESCE(SUM(points), 0) FROM consumed`
+
+	var consumed models.Points
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), orderID.String(), points, utils.GetCurrentDatetimeUTC()).Scan(&consumed)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: consume point lots of order: %v\n", err)
+		return 0, err
+	}
+
+	return consumed, nil
+}
+
+func (rep *pointLotRepository) Expire(ctx context.Context, userID uuid.UUID, earnedBefore time.Time) ([]models.PointLot, error) {
+	defer metrics.ObserveDBQuery("pointLot", "Expire")()
+
+	query := `WITH expired AS (
+				SELECT id, remaining FROM point_lots
+				WHERE user_id = $1 AND earned_at < $2 AND remaining > 0
+				ORDER BY earned_at, id
+				FOR UPDATE
+			)
+			UPDATE point_lots AS p SET remaining = 0, updated_at = $3
+			FROM expired AS e
+			WHERE p.id = e.id
+			RETURNING p.id, p.user_id, p.order_id, p.points, e.remaining, p.earned_at`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, userID.String(), earnedBefore, utils.GetCurrentDatetimeUTC())
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: pointLotRepository: query in Expire: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	lots := make([]models.PointLot, 0)
+
+	for rows.Next() {
+		var lot models.PointLot
+
+		err := rows.Scan(&lot.ID, &lot.UserID, &lot.OrderID, &lot.Points, &lot.Remaining, &lot.EarnedAt)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: Expire: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		lots = append(lots, lot)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: Expire: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return lots, nil
+}
+
+func (rep *pointLotRepository) GetUserIDsWithExpired(ctx context.Context, earnedBefore time.Time, limit int) ([]uuid.UUID, error) {
+	defer metrics.ObserveDBQuery("pointLot", "GetUserIDsWithExpired")()
+
+	query := `SELECT DISTINCT user_id FROM point_lots WHERE earned_at < $1 AND remaining > 0 LIMIT $2`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, earnedBefore, limit)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: pointLotRepository: query in GetUserIDsWithExpired: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	userIDs := make([]uuid.UUID, 0)
+
+	for rows.Next() {
+		var userID uuid.UUID
+
+		err := rows.Scan(&userID)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: GetUserIDsWithExpired: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		userIDs = append(userIDs, userID)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: GetUserIDsWithExpired: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return userIDs, nil
+}
+
+func (rep *pointLotRepository) GetExpiring(
+	ctx context.Context,
+	userID uuid.UUID,
+	earnedBefore time.Time,
+) (models.Points, *time.Time, error) {
+	defer metrics.ObserveDBQuery("pointLot", "GetExpiring")()
+
+	query := `SELECT COALESCE(SUM(remaining), 0), MIN(earned_at) FROM point_lots
+			WHERE user_id = $1 AND earned_at < $2 AND remaining > 0`
+
+	var points models.Points
+	var oldestEarnedAt *time.Time
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), earnedBefore).Scan(&points, &oldestEarnedAt)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: GetExpiring: %v\n", err)
+		return 0, nil, err
+	}
+
+	return points, oldestEarnedAt, nil
+}
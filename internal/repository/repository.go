@@ -2,33 +2,185 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
 )
 
+var (
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrOrderFinalized    = errors.New("order already has a final status")
+	ErrDuplicateLogin    = errors.New("user with this login already exists")
+	ErrDuplicateOrder    = errors.New("order with this number already exists")
+	ErrAlreadyReversed   = errors.New("transaction has already been reversed")
+)
+
+// Repositories is a set of repositories working inside one database transaction.
+type Repositories struct {
+	User        UserRepositoryInterface
+	Order       OrderRepositoryInterface
+	Score       ScoreRepositoryInterface
+	Transaction TransactionRepositoryInterface
+	Outbox      OutboxRepositoryInterface
+	PointLot    PointLotRepositoryInterface
+	Idempotency IdempotencyRepositoryInterface
+}
+
+// MarkIdempotencyKeyCommitted marks the key of the request in ctx committed in the transaction of repositories,
+// so a request which has failed after its changes were committed isn't executed again with the same key.
+func (repositories *Repositories) MarkIdempotencyKeyCommitted(ctx context.Context) error {
+	idempotencyKey, ok := models.IdempotencyKeyFromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	return repositories.Idempotency.MarkCommitted(ctx, idempotencyKey.UserID, idempotencyKey.Key, utils.GetCurrentDatetimeUTC())
+}
+
+// UnitOfWorkInterface runs fn in a transaction: it is committed when fn returns nil
+// and rolled back otherwise.
+type UnitOfWorkInterface interface {
+	Do(ctx context.Context, fn func(ctx context.Context, repositories *Repositories) error) error
+}
+
 type UserRepositoryInterface interface {
 	Insert(ctx context.Context, newLogin string, newPassword string) (*uuid.UUID, error)
 	GetUserByLogin(ctx context.Context, login string) (*models.User, error)
 	IsExists(ctx context.Context, login string) (bool, error)
+	// GetByID returns nil when there is no such user
+	GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error)
+	// Search returns up to limit users whose login contains the substring, ordered by login
+	Search(ctx context.Context, login string, limit int) ([]models.User, error)
+	// SetBlocked blocks the user at blockedAt or unblocks it for nil, it returns false when there is no such user
+	SetBlocked(ctx context.Context, userID uuid.UUID, blockedAt *time.Time) (bool, error)
+	SetRole(ctx context.Context, login string, role string) (bool, error)
+	// SetPassword replaces the password hash of the user, it returns false when there is no such user
+	SetPassword(ctx context.Context, userID uuid.UUID, password string) (bool, error)
 }
 
 type OrderRepositoryInterface interface {
-	Insert(ctx context.Context, number string, userID uuid.UUID) (*uuid.UUID, error)
-	Update(ctx context.Context, number string, userID uuid.UUID, status string, points float32) error
-	GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]models.Order, error)
+	// Insert - isWithdrawal marks the order created by a withdrawal, such orders aren't polled
+	Insert(ctx context.Context, number string, userID uuid.UUID, isWithdrawal bool) (*uuid.UUID, error)
+	Update(ctx context.Context, number string, userID uuid.UUID, status string, points models.Points) error
+	GetAllByUserID(ctx context.Context, userID uuid.UUID, filter models.ListFilter) ([]models.Order, *models.Cursor, error)
 	IsExists(ctx context.Context, number string) (bool, *uuid.UUID, *uuid.UUID, error)
+	ClaimForPolling(ctx context.Context, limit int, leaseUntil time.Time) ([]models.Order, error)
+	// SchedulePolling counts the poll, isAnswered - the accrual system has answered it without the final status
+	SchedulePolling(ctx context.Context, orderID uuid.UUID, nextPollAt time.Time, isAnswered bool) error
+	// ResetPolling makes the orders with a non-final status due for polling at once and resets their attempts,
+	// empty numbers - every such order. It returns the number of the orders.
+	ResetPolling(ctx context.Context, numbers []string) (int64, error)
+	CountByUserIDAndStatus(ctx context.Context, userID uuid.UUID, status string) (int, error)
+	CountByStatus(ctx context.Context) (map[string]int64, error)
 }
 
 type ScoreRepositoryInterface interface {
-	Insert(ctx context.Context, userID uuid.UUID, points float32) (*uuid.UUID, error)
-	Update(ctx context.Context, userID uuid.UUID, points float32) error
+	Insert(ctx context.Context, userID uuid.UUID, points models.Points) (*uuid.UUID, error)
+	Update(ctx context.Context, userID uuid.UUID, points models.Points) error
+	Increase(ctx context.Context, userID uuid.UUID, points models.Points) (models.Points, error)
+	Decrease(ctx context.Context, userID uuid.UUID, points models.Points) (models.Points, error)
+	Set(ctx context.Context, userID uuid.UUID, points models.Points) error
 	GetScoreByUserID(ctx context.Context, userID uuid.UUID) (*models.Score, error)
+	GetScoreByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (*models.Score, error)
 }
 
 type TransactionRepositoryInterface interface {
-	Insert(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points float32, typeTransaction int) error
-	GetSumFundsWithdrawn(ctx context.Context, userID uuid.UUID) (float32, error)
-	GetAllFundsWithdrawn(ctx context.Context, userID uuid.UUID) ([]models.ScoreWithdraw, error)
+	Insert(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points, typeTransaction int) error
+	// InsertBonus writes the points given for the order by the bonus rule
+	InsertBonus(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points, ruleID string) error
+	GetSumBonus(ctx context.Context, userID uuid.UUID, ruleID string, since time.Time) (models.Points, error)
+	// InsertAdjustment writes the manual correction of the balance, it isn't linked to an order
+	InsertAdjustment(ctx context.Context, userID uuid.UUID, points models.Points, typeTransaction int, reason string, operatorID uuid.UUID) error
+	// InsertReversal writes the compensating transaction of the original one and returns it,
+	// ErrAlreadyReversed is returned when the original transaction has already been reversed
+	InsertReversal(
+		ctx context.Context,
+		original models.Transaction,
+		points models.Points,
+		reason string,
+		operatorID *uuid.UUID,
+	) (*models.Transaction, error)
+	// GetByID returns nil when there is no such transaction
+	GetByID(ctx context.Context, transactionID uuid.UUID) (*models.Transaction, error)
+	GetAllByOrderID(ctx context.Context, orderID uuid.UUID) ([]models.Transaction, error)
+	// GetAllByUserID returns the page of the ledger of the user with numbers of the orders
+	GetAllByUserID(ctx context.Context, userID uuid.UUID, filter models.ListFilter) ([]models.Transaction, *models.Cursor, error)
+	// GetSumAccrued returns the points accrued by the accrual system since the moment, without bonuses
+	// and reversed accruals
+	GetSumAccrued(ctx context.Context, userID uuid.UUID, since time.Time) (models.Points, error)
+	GetSumFundsWithdrawn(ctx context.Context, userID uuid.UUID) (models.Points, error)
+	GetAllFundsWithdrawn(ctx context.Context, userID uuid.UUID, filter models.ListFilter) ([]models.ScoreWithdraw, *models.Cursor, error)
+	GetLedgerBalance(ctx context.Context, userID uuid.UUID) (models.Points, error)
+	GetAllLedgerBalances(ctx context.Context) ([]models.LedgerBalance, error)
+}
+
+// PointLotRepositoryInterface - lots of accrued points. Callers lock the score of the user before
+// changing its lots, so withdrawals and the expiration of one user don't interleave.
+type PointLotRepositoryInterface interface {
+	Insert(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points) error
+	// Consume takes points from the oldest lots of the user
+	Consume(ctx context.Context, userID uuid.UUID, points models.Points) error
+	// ConsumeOrder takes up to points from the lot of the order and returns how many were taken
+	ConsumeOrder(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points) (models.Points, error)
+	// Expire empties the lots of the user earned before earnedBefore and returns them
+	// with the expired points in Remaining
+	Expire(ctx context.Context, userID uuid.UUID, earnedBefore time.Time) ([]models.PointLot, error)
+	GetUserIDsWithExpired(ctx context.Context, earnedBefore time.Time, limit int) ([]uuid.UUID, error)
+	// GetExpiring returns the sum of the points left in the lots earned before earnedBefore
+	// and when the oldest of these lots was earned
+	GetExpiring(ctx context.Context, userID uuid.UUID, earnedBefore time.Time) (models.Points, *time.Time, error)
+}
+
+type TierRepositoryInterface interface {
+	// Get returns nil when the tier of the user hasn't been calculated yet
+	Get(ctx context.Context, userID uuid.UUID) (*models.UserTier, error)
+	Save(ctx context.Context, userTier *models.UserTier) error
+}
+
+type IdempotencyRepositoryInterface interface {
+	Insert(ctx context.Context, idempotencyKey *models.IdempotencyKey) (bool, error)
+	Get(ctx context.Context, userID uuid.UUID, key string) (*models.IdempotencyKey, error)
+	TakeOver(ctx context.Context, userID uuid.UUID, key string, now time.Time, leaseUntil time.Time) (bool, error)
+	MarkCommitted(ctx context.Context, userID uuid.UUID, key string, committedAt time.Time) error
+	Complete(ctx context.Context, userID uuid.UUID, key string, statusCode int, contentType string, body []byte) error
+	Delete(ctx context.Context, userID uuid.UUID, key string) error
+	DeleteExpired(ctx context.Context, createdBefore time.Time) error
+}
+
+type SessionRepositoryInterface interface {
+	Insert(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error)
+	GetByID(ctx context.Context, sessionID uuid.UUID) (*models.Session, error)
+	Revoke(ctx context.Context, sessionID uuid.UUID) error
+	RevokeAllByUserID(ctx context.Context, userID uuid.UUID) error
+	InsertRefreshToken(ctx context.Context, sessionID uuid.UUID, tokenHash string, expiresAt time.Time) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	MarkRefreshTokenUsed(ctx context.Context, refreshTokenID uuid.UUID) (bool, error)
+}
+
+// OutboxRepositoryInterface - events which are written together with the changes they describe.
+type OutboxRepositoryInterface interface {
+	Insert(ctx context.Context, event *models.OutboxEvent) error
+	// FanOut creates a delivery of up to limit undispatched events for every webhook of their users
+	// and returns the number of dispatched events.
+	FanOut(ctx context.Context, limit int) (int, error)
+}
+
+type WebhookRepositoryInterface interface {
+	Insert(ctx context.Context, webhook *models.Webhook) error
+	GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error)
+	Delete(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) (bool, error)
+	ClaimDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]models.WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error
+	GetDeliveriesByUserID(ctx context.Context, userID uuid.UUID, status string) ([]models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, userID uuid.UUID, deliveryID uuid.UUID) (bool, error)
+}
+
+// SchemaRepositoryInterface tells whether the database is reachable and its schema is up to date.
+type SchemaRepositoryInterface interface {
+	Ping(ctx context.Context) error
+	GetMigrationVersion(ctx context.Context) (uint, bool, error)
 }
@@ -0,0 +1,57 @@
+package schemarepository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type schemaRepository struct {
+	client dbclient.PoolInterface
+	logger logger.Logger
+}
+
+var _ repository.SchemaRepositoryInterface = &schemaRepository{}
+
+func New(client dbclient.PoolInterface, logger logger.Logger) *schemaRepository {
+	return &schemaRepository{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (rep *schemaRepository) Ping(ctx context.Context) error {
+	defer metrics.ObserveDBQuery("schema", "Ping")()
+
+	return rep.client.Ping(ctx)
+}
+
+// GetMigrationVersion returns the version of the last applied migration from the table of golang-migrate
+// and whether the migration has failed halfway. Version 0 means no migration was applied.
+func (rep *schemaRepository) GetMigrationVersion(ctx context.Context) (uint, bool, error) {
+	defer metrics.ObserveDBQuery("schema", "GetMigrationVersion")()
+
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1;`
+
+	var version int64
+	var dirty bool
+
+	err := rep.client.QueryRow(ctx, query).Scan(&version, &dirty)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, false, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: get migration version: %v\n", err)
+
+		return 0, false, err
+	}
+
+	return uint(version), dirty, nil
+}
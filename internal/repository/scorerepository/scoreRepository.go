@@ -13,6 +13,7 @@ import (
 	"github.com/lexizz/cumloys/internal/db/dbclient"
 	"github.com/lexizz/cumloys/internal/models"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 )
@@ -38,6 +39,8 @@ func New(client dbclient.ClientInterface, logger logger.Logger) *scoreRepository
 }
 
 func (rep *scoreRepository) GetScoreByUserID(ctx context.Context, userID uuid.UUID) (*models.Score, error) {
+	defer metrics.ObserveDBQuery("score", "GetScoreByUserID")()
+
 	query := `SELECT id, total, user_id, created_at, updated_at FROM score WHERE user_id=$1`
 
 	var score models.Score
@@ -78,7 +81,9 @@ func (rep *scoreRepository) GetScoreByUserID(ctx context.Context, userID uuid.UU
 	return &score, nil
 }
 
-func (rep *scoreRepository) Insert(ctx context.Context, userID uuid.UUID, points float32) (*uuid.UUID, error) {
+func (rep *scoreRepository) Insert(ctx context.Context, userID uuid.UUID, points models.Points) (*uuid.UUID, error) {
+	defer metrics.ObserveDBQuery("score", "Insert")()
+
 	query := `INSERT INTO score (user_id, total, created_at, updated_at) VALUES ($1, $2, $3, $4) RETURNING id`
 
 	rep.rwMutex.Lock()
@@ -108,7 +113,9 @@ func (rep *scoreRepository) Insert(ctx context.Context, userID uuid.UUID, points
 	return &lastInsertID, nil
 }
 
-func (rep *scoreRepository) Update(ctx context.Context, userID uuid.UUID, points float32) error {
+func (rep *scoreRepository) Update(ctx context.Context, userID uuid.UUID, points models.Points) error {
+	defer metrics.ObserveDBQuery("score", "Update")()
+
 	query := `UPDATE score SET (user_id, total, updated_at) = ($1, $2, $3) WHERE user_id = $4;`
 
 	rep.rwMutex.Lock()
@@ -132,3 +139,101 @@ func (rep *scoreRepository) Update(ctx context.Context, userID uuid.UUID, points
 
 	return nil
 }
+
+// Increase adds points to the score of the user, creating the score if needed, and returns the new total.
+func (rep *scoreRepository) Increase(ctx context.Context, userID uuid.UUID, points models.Points) (models.Points, error) {
+	defer metrics.ObserveDBQuery("score", "Increase")()
+
+	query := `INSERT INTO score (user_id, total, created_at, updated_at) VALUES ($1, $2, $3, $3)
+			ON CONFLICT (user_id) DO UPDATE SET total = score.total + EXCLUDED.total, updated_at = EXCLUDED.updated_at
+			RETURNING total;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	var total models.Points
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), points, utils.GetCurrentDatetimeUTC()).Scan(&total)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: failed increase score: %v\n", err)
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// Decrease subtracts points from the score of the user only if there are enough of them and returns the new total.
+// The check and the update are one statement, so concurrent withdrawals can't overdraw the score.
+func (rep *scoreRepository) Decrease(ctx context.Context, userID uuid.UUID, points models.Points) (models.Points, error) {
+	defer metrics.ObserveDBQuery("score", "Decrease")()
+
+	query := `UPDATE score SET (total, updated_at) = (total - $1, $2) WHERE user_id = $3 AND total >= $1 RETURNING total;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	var total models.Points
+
+	err := rep.client.QueryRow(ctx, query, points, utils.GetCurrentDatetimeUTC(), userID.String()).Scan(&total)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return 0, repository.ErrInsufficientFunds
+		}
+
+		rep.logger.Errorf("---> ERROR: failed decrease score: %v\n", err)
+
+		return 0, err
+	}
+
+	return total, nil
+}
+
+// GetScoreByUserIDForUpdate is GetScoreByUserID which locks the score row until the end of the transaction.
+func (rep *scoreRepository) GetScoreByUserIDForUpdate(ctx context.Context, userID uuid.UUID) (*models.Score, error) {
+	defer metrics.ObserveDBQuery("score", "GetScoreByUserIDForUpdate")()
+
+	query := `SELECT id, total, user_id, created_at, updated_at FROM score WHERE user_id=$1 FOR UPDATE`
+
+	var score models.Score
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String()).Scan(
+		&score.ID,
+		&score.Total,
+		&score.UserID,
+		&score.CreatedAt,
+		&score.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: scoreRepository: GetScoreByUserIDForUpdate: %v\n", err)
+
+		return nil, err
+	}
+
+	return &score, nil
+}
+
+// Set replaces the total of the user, creating the score if needed.
+func (rep *scoreRepository) Set(ctx context.Context, userID uuid.UUID, points models.Points) error {
+	defer metrics.ObserveDBQuery("score", "Set")()
+
+	query := `INSERT INTO score (user_id, total, created_at, updated_at) VALUES ($1, $2, $3, $3)
+			ON CONFLICT (user_id) DO UPDATE SET total = EXCLUDED.total, updated_at = EXCLUDED.updated_at;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, userID.String(), points, utils.GetCurrentDatetimeUTC())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: failed set score: %v\n", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,188 @@
+package sessionrepository
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type sessionRepository struct {
+	client  dbclient.ClientInterface
+	rwMutex *sync.RWMutex
+	logger  logger.Logger
+}
+
+var _ repository.SessionRepositoryInterface = &sessionRepository{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *sessionRepository {
+	rwMutex := sync.RWMutex{}
+
+	sesRepository := sessionRepository{
+		client:  client,
+		rwMutex: &rwMutex,
+		logger:  logger,
+	}
+
+	return &sesRepository
+}
+
+func (rep *sessionRepository) Insert(ctx context.Context, userID uuid.UUID) (*uuid.UUID, error) {
+	defer metrics.ObserveDBQuery("session", "Insert")()
+
+	query := `INSERT INTO sessions (user_id, created_at) VALUES ($1, $2) RETURNING id`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	var lastInsertID uuid.UUID
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), utils.GetCurrentDatetimeUTC()).Scan(&lastInsertID)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: insert session: %v\n", err)
+		return nil, err
+	}
+
+	return &lastInsertID, nil
+}
+
+func (rep *sessionRepository) GetByID(ctx context.Context, sessionID uuid.UUID) (*models.Session, error) {
+	defer metrics.ObserveDBQuery("session", "GetByID")()
+
+	query := `SELECT id, user_id, created_at, revoked_at FROM sessions WHERE id = $1`
+
+	var session models.Session
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, sessionID.String()).Scan(
+		&session.ID,
+		&session.UserID,
+		&session.CreatedAt,
+		&session.RevokedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: get session: %v\n", err)
+
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+func (rep *sessionRepository) Revoke(ctx context.Context, sessionID uuid.UUID) error {
+	defer metrics.ObserveDBQuery("session", "Revoke")()
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND revoked_at IS NULL;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, utils.GetCurrentDatetimeUTC(), sessionID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: revoke session: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAllByUserID revokes every active session of the user, e.g. when the user is blocked.
+func (rep *sessionRepository) RevokeAllByUserID(ctx context.Context, userID uuid.UUID) error {
+	defer metrics.ObserveDBQuery("session", "RevokeAllByUserID")()
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND revoked_at IS NULL;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, utils.GetCurrentDatetimeUTC(), userID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: revoke sessions of user: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (rep *sessionRepository) InsertRefreshToken(ctx context.Context, sessionID uuid.UUID, tokenHash string, expiresAt time.Time) error {
+	defer metrics.ObserveDBQuery("session", "InsertRefreshToken")()
+
+	query := `INSERT INTO refresh_tokens (session_id, token_hash, created_at, expires_at) VALUES ($1, $2, $3, $4)`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query, sessionID.String(), tokenHash, utils.GetCurrentDatetimeUTC(), expiresAt)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: insert refresh token: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func (rep *sessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	defer metrics.ObserveDBQuery("session", "GetRefreshToken")()
+
+	query := `SELECT id, session_id, token_hash, created_at, expires_at, used_at FROM refresh_tokens WHERE token_hash = $1`
+
+	var refreshToken models.RefreshToken
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, tokenHash).Scan(
+		&refreshToken.ID,
+		&refreshToken.SessionID,
+		&refreshToken.TokenHash,
+		&refreshToken.CreatedAt,
+		&refreshToken.ExpiresAt,
+		&refreshToken.UsedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: get refresh token: %v\n", err)
+
+		return nil, err
+	}
+
+	return &refreshToken, nil
+}
+
+// MarkRefreshTokenUsed returns false when the token has already been used,
+// also by a concurrent request.
+func (rep *sessionRepository) MarkRefreshTokenUsed(ctx context.Context, refreshTokenID uuid.UUID) (bool, error) {
+	defer metrics.ObserveDBQuery("session", "MarkRefreshTokenUsed")()
+
+	query := `UPDATE refresh_tokens SET used_at = $1 WHERE id = $2 AND used_at IS NULL;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, utils.GetCurrentDatetimeUTC(), refreshTokenID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: mark refresh token used: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,89 @@
+package tierrepository
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+type tierRepository struct {
+	client  dbclient.ClientInterface
+	rwMutex *sync.RWMutex
+	logger  logger.Logger
+}
+
+var _ repository.TierRepositoryInterface = &tierRepository{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *tierRepository {
+	rwMutex := sync.RWMutex{}
+
+	userTierRepository := tierRepository{
+		client:  client,
+		rwMutex: &rwMutex,
+		logger:  logger,
+	}
+
+	return &userTierRepository
+}
+
+func (rep *tierRepository) Get(ctx context.Context, userID uuid.UUID) (*models.UserTier, error) {
+	defer metrics.ObserveDBQuery("tier", "Get")()
+
+	query := `SELECT user_id, tier, accrued_points, updated_at FROM user_tiers WHERE user_id = $1`
+
+	var userTier models.UserTier
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String()).Scan(
+		&userTier.UserID,
+		&userTier.Tier,
+		&userTier.AccruedPoints,
+		&userTier.UpdatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: get user tier: %v\n", err)
+
+		return nil, err
+	}
+
+	return &userTier, nil
+}
+
+func (rep *tierRepository) Save(ctx context.Context, userTier *models.UserTier) error {
+	defer metrics.ObserveDBQuery("tier", "Save")()
+
+	query := `INSERT INTO user_tiers (user_id, tier, accrued_points, updated_at) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (user_id) DO UPDATE
+			SET tier = EXCLUDED.tier, accrued_points = EXCLUDED.accrued_points, updated_at = EXCLUDED.updated_at;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query,
+		userTier.UserID.String(),
+		userTier.Tier,
+		userTier.AccruedPoints,
+		userTier.UpdatedAt,
+	)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: save user tier: %v\n", err)
+		return err
+	}
+
+	return nil
+}
@@ -2,7 +2,6 @@ package transactionrepository
 
 import (
 	"context"
-	"database/sql"
 	"errors"
 	"fmt"
 	"sync"
@@ -15,6 +14,7 @@ import (
 	"github.com/lexizz/cumloys/internal/db/dbclient"
 	"github.com/lexizz/cumloys/internal/models"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 )
@@ -24,6 +24,16 @@ const (
 	DecreaseNumberPointsType int = 2
 )
 
+// notReversedCondition skips the transactions `t` which have been reversed, e.g. refunded withdrawals.
+const notReversedCondition = ` AND NOT EXISTS (SELECT 1 FROM transactions AS r WHERE r.reversal_of = t.id)`
+
+// selectTransactionColumns - columns scanned by scanTransaction.
+const selectTransactionColumns = `SELECT t.id, t.user_id, t.order_id, COALESCE(o.number, ''), t.points, t.balance_after, t.type,
+				COALESCE(t.rule_id, ''), COALESCE(t.reason, ''), t.operator_id, t.reversal_of, r.id, t.created_at
+			FROM transactions AS t
+			LEFT JOIN orders o on o.id = t.order_id
+			LEFT JOIN transactions r on r.reversal_of = t.id`
+
 type transactionRepository struct {
 	client  dbclient.ClientInterface
 	rwMutex *sync.RWMutex
@@ -44,19 +54,146 @@ func New(client dbclient.ClientInterface, logger logger.Logger) *transactionRepo
 	return &transactRepository
 }
 
-func (rep *transactionRepository) Insert(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points float32, typeTransaction int) error {
-	query := `INSERT INTO transactions (user_id, order_id, points, type, created_at) VALUES ($1, $2, $3, $4, $5)`
+func (rep *transactionRepository) Insert(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points, typeTransaction int) error {
+	defer metrics.ObserveDBQuery("transaction", "Insert")()
+
+	_, err := rep.insert(ctx, models.Transaction{
+		UserID:  userID,
+		OrderID: orderID,
+		Points:  points,
+		Type:    typeTransaction,
+	})
+
+	return err
+}
+
+func (rep *transactionRepository) InsertBonus(ctx context.Context, userID uuid.UUID, orderID uuid.UUID, points models.Points, ruleID string) error {
+	defer metrics.ObserveDBQuery("transaction", "InsertBonus")()
+
+	_, err := rep.insert(ctx, models.Transaction{
+		UserID:  userID,
+		OrderID: orderID,
+		Points:  points,
+		Type:    models.BonusPointsType,
+		RuleID:  ruleID,
+	})
+
+	return err
+}
+
+func (rep *transactionRepository) InsertAdjustment(
+	ctx context.Context,
+	userID uuid.UUID,
+	points models.Points,
+	typeTransaction int,
+	reason string,
+	operatorID uuid.UUID,
+) error {
+	defer metrics.ObserveDBQuery("transaction", "InsertAdjustment")()
+
+	_, err := rep.insert(ctx, models.Transaction{
+		UserID:     userID,
+		Points:     points,
+		Type:       typeTransaction,
+		Reason:     reason,
+		OperatorID: &operatorID,
+	})
+
+	return err
+}
+
+// InsertReversal relies on the unique index of reversal_of, so concurrent reversals of one transaction
+// can't both succeed.
+func (rep *transactionRepository) InsertReversal(
+	ctx context.Context,
+	original models.Transaction,
+	points models.Points,
+	reason string,
+	operatorID *uuid.UUID,
+) (*models.Transaction, error) {
+	defer metrics.ObserveDBQuery("transaction", "InsertReversal")()
+
+	typeTransaction, isReversible := models.ReversalType(original.Type)
+	if !isReversible {
+		return nil, fmt.Errorf("transaction of type %d can't be reversed", original.Type)
+	}
+
+	reversal, err := rep.insert(ctx, models.Transaction{
+		UserID:     original.UserID,
+		OrderID:    original.OrderID,
+		Points:     points,
+		Type:       typeTransaction,
+		Reason:     reason,
+		OperatorID: operatorID,
+		ReversalOf: &original.ID,
+	})
+	if err != nil {
+		if utils.IsUniqueViolation(err) {
+			return nil, repository.ErrAlreadyReversed
+		}
+
+		return nil, err
+	}
+
+	reversal.OrderNumber = original.OrderNumber
+	reversal.CreatedAt = time.Unix(reversal.CreatedAt.Unix(), 0).UTC()
+
+	return reversal, nil
+}
+
+// insert writes NULL instead of the empty order, rule and reason of the transaction
+// and returns the transaction with the id, the balance after it and the creation time.
+func (rep *transactionRepository) insert(ctx context.Context, transaction models.Transaction) (*models.Transaction, error) {
+	// balance_after continues the ledger of the user, so the ledger doesn't depend on the score table.
+	// Callers change the score of the user in the same transaction before, which locks the score row
+	// and keeps concurrent inserts for one user in order. The previous transaction is taken by seq:
+	// the rows of one unit of work have the same created_at, and their ids are random.
+	query := `INSERT INTO transactions (user_id, order_id, points, type, balance_after, created_at, rule_id, reason, operator_id, reversal_of)
+			SELECT $1, $2, $3, $4, COALESCE((
+				SELECT balance_after FROM transactions WHERE user_id = $1 ORDER BY seq DESC LIMIT 1
+			), 0) + $5, $6, $7, $8, $9, $10
+			RETURNING id, balance_after, created_at`
+
+	var orderID, ruleID, reason, operatorID, reversalOf *string
+
+	if transaction.OrderID != uuid.Nil {
+		value := transaction.OrderID.String()
+		orderID = &value
+	}
+
+	if len(transaction.RuleID) > 0 {
+		ruleID = &transaction.RuleID
+	}
+
+	if len(transaction.Reason) > 0 {
+		reason = &transaction.Reason
+	}
+
+	if transaction.OperatorID != nil {
+		value := transaction.OperatorID.String()
+		operatorID = &value
+	}
+
+	if transaction.ReversalOf != nil {
+		value := transaction.ReversalOf.String()
+		reversalOf = &value
+	}
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
 
-	_, err := rep.client.Exec(ctx, query,
-		userID.String(),
-		orderID.String(),
-		points,
-		typeTransaction,
+	err := rep.client.QueryRow(ctx, query,
+		transaction.UserID.String(),
+		orderID,
+		transaction.Points,
+		transaction.Type,
+		models.SignedPoints(transaction.Points, transaction.Type),
 		utils.GetCurrentDatetimeUTC(),
-	)
+		ruleID,
+		reason,
+		operatorID,
+		reversalOf,
+	).Scan(&transaction.ID, &transaction.BalanceAfter, &transaction.CreatedAt)
 	if err != nil {
 		var pgErr pgconn.PgError
 
@@ -69,18 +206,62 @@ func (rep *transactionRepository) Insert(ctx context.Context, userID uuid.UUID,
 
 		rep.logger.Errorf(errorMessage)
 
-		return err
+		return nil, err
 	}
 
 	rep.logger.Info("====== Insert Transaction: OK ======")
 
-	return nil
+	return &transaction, nil
+}
+
+// GetSumBonus returns the points given to the user by the bonus rule since the moment.
+func (rep *transactionRepository) GetSumBonus(ctx context.Context, userID uuid.UUID, ruleID string, since time.Time) (models.Points, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetSumBonus")()
+
+	query := `SELECT COALESCE(SUM(t.points), 0) FROM transactions AS t
+			WHERE t.user_id = $1 AND t.type = $2 AND t.rule_id = $3 AND t.created_at >= $4` + notReversedCondition + `;`
+
+	var bonusPoints models.Points
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), models.BonusPointsType, ruleID, since).Scan(&bonusPoints)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: GetSumBonus: %v\n", err)
+		return 0, err
+	}
+
+	return bonusPoints, nil
+}
+
+func (rep *transactionRepository) GetSumAccrued(ctx context.Context, userID uuid.UUID, since time.Time) (models.Points, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetSumAccrued")()
+
+	query := `SELECT COALESCE(SUM(t.points), 0) FROM transactions AS t
+			WHERE t.user_id = $1 AND t.type = $2 AND t.created_at >= $3` + notReversedCondition + `;`
+
+	var accruedPoints models.Points
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), models.IncreasePointsType, since).Scan(&accruedPoints)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: GetSumAccrued: %v\n", err)
+		return 0, err
+	}
+
+	return accruedPoints, nil
 }
 
-func (rep *transactionRepository) GetSumFundsWithdrawn(ctx context.Context, userID uuid.UUID) (float32, error) {
-	query := `SELECT SUM(points) FROM transactions WHERE user_id = $1 AND type = $2;`
+func (rep *transactionRepository) GetSumFundsWithdrawn(ctx context.Context, userID uuid.UUID) (models.Points, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetSumFundsWithdrawn")()
+
+	query := `SELECT COALESCE(SUM(t.points), 0) FROM transactions AS t
+			WHERE t.user_id = $1 AND t.type = $2` + notReversedCondition + `;`
 
-	var withdrawPoints sql.NullFloat64
+	var withdrawPoints models.Points
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
@@ -105,32 +286,47 @@ func (rep *transactionRepository) GetSumFundsWithdrawn(ctx context.Context, user
 		return 0, err
 	}
 
-	return float32(withdrawPoints.Float64), nil
+	return withdrawPoints, nil
 }
 
-func (rep *transactionRepository) GetAllFundsWithdrawn(ctx context.Context, userID uuid.UUID) ([]models.ScoreWithdraw, error) {
-	query := `SELECT t.points, o.number, t.created_at
+// GetAllFundsWithdrawn returns the page of withdrawals of the user and the cursor of the next page.
+func (rep *transactionRepository) GetAllFundsWithdrawn(
+	ctx context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.ScoreWithdraw, *models.Cursor, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetAllFundsWithdrawn")()
+
+	conditions, args := repository.ListQuery(filter, repository.ListColumns{
+		CreatedAt: "t.created_at",
+		ID:        "t.id",
+	}, []interface{}{userID.String(), models.DecreasePointsType})
+
+	query := `SELECT t.id, t.points, o.number, t.created_at
 			FROM transactions AS t
 			INNER JOIN orders o on o.id = t.order_id
-			WHERE t.user_id = $1 AND t.type = $2 ORDER BY t.created_at ASC`
+			WHERE t.user_id = $1 AND t.type = $2` + notReversedCondition + conditions
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
 
-	rows, errQuery := rep.client.Query(ctx, query, userID.String(), models.DecreasePointsType)
+	rows, errQuery := rep.client.Query(ctx, query, args...)
 	if errQuery != nil {
 		rep.logger.Errorf("---> ERROR: transactionRepository: query in GetAllFundsWithdrawn: %v\n", errQuery)
-		return nil, errQuery
+		return nil, nil, errQuery
 	}
 
 	defer rows.Close()
 
 	scoreWithdraws := make([]models.ScoreWithdraw, 0)
+	cursors := make([]models.Cursor, 0)
 
 	for rows.Next() {
 		var scoreWithdraw models.ScoreWithdraw
+		var transactionID uuid.UUID
 
 		err := rows.Scan(
+			&transactionID,
 			&scoreWithdraw.SumWithdraw,
 			&scoreWithdraw.NumberOrder,
 			&scoreWithdraw.CreatedAt,
@@ -147,9 +343,11 @@ func (rep *transactionRepository) GetAllFundsWithdrawn(ctx context.Context, user
 
 			rep.logger.Errorf(errorMessage)
 
-			return nil, err
+			return nil, nil, err
 		}
 
+		cursors = append(cursors, models.Cursor{CreatedAt: scoreWithdraw.CreatedAt, ID: transactionID})
+
 		newTime := time.Unix(scoreWithdraw.CreatedAt.Unix(), 0).Format(time.RFC3339)
 		newUpdatedAt, errTimeParse := time.Parse(time.RFC3339, newTime)
 		if errTimeParse != nil {
@@ -164,8 +362,226 @@ func (rep *transactionRepository) GetAllFundsWithdrawn(ctx context.Context, user
 
 	if errRows := rows.Err(); errRows != nil {
 		rep.logger.Errorf("---> ERROR: GetAllFundsWithdrawn: rows next: %v\n", errRows)
+		return nil, nil, errRows
+	}
+
+	size, next := repository.NextPage(filter, cursors)
+
+	return scoreWithdraws[:size], next, nil
+}
+
+func (rep *transactionRepository) GetByID(ctx context.Context, transactionID uuid.UUID) (*models.Transaction, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetByID")()
+
+	query := selectTransactionColumns + ` WHERE t.id = $1`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	transaction, err := scanTransaction(rep.client.QueryRow(ctx, query, transactionID.String()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: GetByID: %v\n", err)
+
+		return nil, err
+	}
+
+	transaction.CreatedAt = time.Unix(transaction.CreatedAt.Unix(), 0).UTC()
+
+	return &transaction, nil
+}
+
+// GetAllByOrderID returns the transactions of the order from the oldest to the newest one.
+func (rep *transactionRepository) GetAllByOrderID(ctx context.Context, orderID uuid.UUID) ([]models.Transaction, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetAllByOrderID")()
+
+	query := selectTransactionColumns + ` WHERE t.order_id = $1 ORDER BY t.created_at ASC, t.id ASC`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, orderID.String())
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: transactionRepository: query in GetAllByOrderID: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0)
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: GetAllByOrderID: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		transaction.CreatedAt = time.Unix(transaction.CreatedAt.Unix(), 0).UTC()
+
+		transactions = append(transactions, transaction)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: GetAllByOrderID: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return transactions, nil
+}
+
+// GetAllByUserID returns the page of the ledger of the user and the cursor of the next page.
+func (rep *transactionRepository) GetAllByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.Transaction, *models.Cursor, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetAllByUserID")()
+
+	conditions, args := repository.ListQuery(filter, repository.ListColumns{
+		CreatedAt: "t.created_at",
+		ID:        "t.id",
+	}, []interface{}{userID.String()})
+
+	query := selectTransactionColumns + `
+			WHERE t.user_id = $1` + conditions
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, args...)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: transactionRepository: query in GetAllByUserID: %v\n", errQuery)
+		return nil, nil, errQuery
+	}
+
+	defer rows.Close()
+
+	transactions := make([]models.Transaction, 0)
+	cursors := make([]models.Cursor, 0)
+
+	for rows.Next() {
+		transaction, err := scanTransaction(rows)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: GetAllByUserID: get row from scan: %v\n", err)
+			return nil, nil, err
+		}
+
+		cursors = append(cursors, models.Cursor{CreatedAt: transaction.CreatedAt, ID: transaction.ID})
+
+		transaction.CreatedAt = time.Unix(transaction.CreatedAt.Unix(), 0).UTC()
+
+		transactions = append(transactions, transaction)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: GetAllByUserID: rows next: %v\n", errRows)
+		return nil, nil, errRows
+	}
+
+	size, next := repository.NextPage(filter, cursors)
+
+	return transactions[:size], next, nil
+}
+
+// GetLedgerBalance returns the balance of the user calculated from the ledger.
+func (rep *transactionRepository) GetLedgerBalance(ctx context.Context, userID uuid.UUID) (models.Points, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetLedgerBalance")()
+
+	query := `SELECT COALESCE(SUM(CASE WHEN type = ANY($2) THEN -points ELSE points END), 0)
+			FROM transactions WHERE user_id = $1;`
+
+	var balance models.Points
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String(), models.DebitPointsTypes()).Scan(&balance)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: GetLedgerBalance: %v\n", err)
+		return 0, err
+	}
+
+	return balance, nil
+}
+
+// GetAllLedgerBalances returns the score total and the ledger sum of every user.
+func (rep *transactionRepository) GetAllLedgerBalances(ctx context.Context) ([]models.LedgerBalance, error) {
+	defer metrics.ObserveDBQuery("transaction", "GetAllLedgerBalances")()
+
+	query := `SELECT u.id, COALESCE(s.total, 0), COALESCE(l.total, 0)
+			FROM users AS u
+			LEFT JOIN score AS s ON s.user_id = u.id
+			LEFT JOIN (
+				SELECT user_id, SUM(CASE WHEN type = ANY($1) THEN -points ELSE points END) AS total
+				FROM transactions
+				GROUP BY user_id
+			) AS l ON l.user_id = u.id
+			ORDER BY u.created_at ASC;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, models.DebitPointsTypes())
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: transactionRepository: query in GetAllLedgerBalances: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	balances := make([]models.LedgerBalance, 0)
+
+	for rows.Next() {
+		var balance models.LedgerBalance
+
+		err := rows.Scan(&balance.UserID, &balance.ScoreTotal, &balance.LedgerTotal)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: GetAllLedgerBalances: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		balances = append(balances, balance)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: GetAllLedgerBalances: rows next: %v\n", errRows)
 		return nil, errRows
 	}
 
-	return scoreWithdraws, nil
+	return balances, nil
+}
+
+// scanTransaction reads the row of selectTransactionColumns.
+func scanTransaction(row pgx.Row) (models.Transaction, error) {
+	var transaction models.Transaction
+	var orderID *uuid.UUID
+
+	err := row.Scan(
+		&transaction.ID,
+		&transaction.UserID,
+		&orderID,
+		&transaction.OrderNumber,
+		&transaction.Points,
+		&transaction.BalanceAfter,
+		&transaction.Type,
+		&transaction.RuleID,
+		&transaction.Reason,
+		&transaction.OperatorID,
+		&transaction.ReversalOf,
+		&transaction.ReversedBy,
+		&transaction.CreatedAt,
+	)
+	if err != nil {
+		return transaction, err
+	}
+
+	if orderID != nil {
+		transaction.OrderID = *orderID
+	}
+
+	return transaction, nil
 }
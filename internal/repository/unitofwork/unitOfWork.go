@@ -0,0 +1,76 @@
+package unitofwork
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v4"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/repository/idempotencyrepository"
+	"github.com/lexizz/cumloys/internal/repository/orderrepository"
+	"github.com/lexizz/cumloys/internal/repository/outboxrepository"
+	"github.com/lexizz/cumloys/internal/repository/pointlotrepository"
+	"github.com/lexizz/cumloys/internal/repository/scorerepository"
+	"github.com/lexizz/cumloys/internal/repository/transactionrepository"
+	"github.com/lexizz/cumloys/internal/repository/userrepository"
+)
+
+type unitOfWork struct {
+	client dbclient.ClientInterface
+	logger logger.Logger
+}
+
+var _ repository.UnitOfWorkInterface = &unitOfWork{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *unitOfWork {
+	return &unitOfWork{
+		client: client,
+		logger: logger,
+	}
+}
+
+func (uow *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context, repositories *repository.Repositories) error) error {
+	tx, errBegin := uow.client.Begin(ctx)
+	if errBegin != nil {
+		uow.logger.Errorf("---> ERROR: unitOfWork: failed begin transaction: %v\n", errBegin)
+		return errBegin
+	}
+
+	defer func() {
+		errRollback := tx.Rollback(ctx)
+		if errRollback != nil && !errors.Is(errRollback, pgx.ErrTxClosed) {
+			uow.logger.Errorf("---> ERROR: unitOfWork: failed rollback transaction: %v\n", errRollback)
+		}
+	}()
+
+	repositories := &repository.Repositories{
+		User:        userrepository.New(tx, uow.logger),
+		Order:       orderrepository.New(tx, uow.logger),
+		Score:       scorerepository.New(tx, uow.logger),
+		Transaction: transactionrepository.New(tx, uow.logger),
+		Outbox:      outboxrepository.New(tx, uow.logger),
+		PointLot:    pointlotrepository.New(tx, uow.logger),
+		Idempotency: idempotencyrepository.New(tx, uow.logger),
+	}
+
+	errFn := fn(ctx, repositories)
+	if errFn != nil {
+		return errFn
+	}
+
+	errMark := repositories.MarkIdempotencyKeyCommitted(ctx)
+	if errMark != nil {
+		return errMark
+	}
+
+	errCommit := tx.Commit(ctx)
+	if errCommit != nil {
+		uow.logger.Errorf("---> ERROR: unitOfWork: failed commit transaction: %v\n", errCommit)
+		return errCommit
+	}
+
+	return nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/jackc/pgconn"
@@ -13,6 +15,7 @@ import (
 	"github.com/lexizz/cumloys/internal/db/dbclient"
 	"github.com/lexizz/cumloys/internal/models"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 )
@@ -38,6 +41,8 @@ func New(client dbclient.ClientInterface, logger logger.Logger) *userRepository
 }
 
 func (rep *userRepository) IsExists(ctx context.Context, login string) (bool, error) {
+	defer metrics.ObserveDBQuery("user", "IsExists")()
+
 	query := `SELECT COUNT(*) FROM users WHERE login=$1`
 
 	var numberOfUsers int
@@ -69,14 +74,23 @@ func (rep *userRepository) IsExists(ctx context.Context, login string) (bool, er
 }
 
 func (rep *userRepository) GetUserByLogin(ctx context.Context, login string) (*models.User, error) {
-	query := `SELECT id, login, password, created_at FROM users WHERE login=$1`
+	defer metrics.ObserveDBQuery("user", "GetUserByLogin")()
+
+	query := `SELECT id, login, password, role, blocked_at, created_at FROM users WHERE login=$1`
 
 	var user models.User
 
 	rep.rwMutex.Lock()
 	defer rep.rwMutex.Unlock()
 
-	err := rep.client.QueryRow(ctx, query, login).Scan(&user.ID, &user.Login, &user.Password, &user.CreatedAt)
+	err := rep.client.QueryRow(ctx, query, login).Scan(
+		&user.ID,
+		&user.Login,
+		&user.Password,
+		&user.Role,
+		&user.BlockedAt,
+		&user.CreatedAt,
+	)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return nil, nil
@@ -104,6 +118,8 @@ func (rep *userRepository) GetUserByLogin(ctx context.Context, login string) (*m
 }
 
 func (rep *userRepository) Insert(ctx context.Context, newLogin string, newPassword string) (*uuid.UUID, error) {
+	defer metrics.ObserveDBQuery("user", "Insert")()
+
 	query := `INSERT INTO users (login, password, created_at) VALUES ($1, $2, $3) RETURNING id`
 
 	rep.rwMutex.Lock()
@@ -125,6 +141,10 @@ func (rep *userRepository) Insert(ctx context.Context, newLogin string, newPassw
 
 		rep.logger.Errorf(errorMessage)
 
+		if utils.IsUniqueViolation(err) {
+			return nil, repository.ErrDuplicateLogin
+		}
+
 		return nil, err
 	}
 
@@ -137,3 +157,128 @@ func (rep *userRepository) Insert(ctx context.Context, newLogin string, newPassw
 
 	return &lastInsertID, nil
 }
+
+func (rep *userRepository) GetByID(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	defer metrics.ObserveDBQuery("user", "GetByID")()
+
+	query := `SELECT id, login, role, blocked_at, created_at FROM users WHERE id = $1`
+
+	var user models.User
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, userID.String()).Scan(
+		&user.ID,
+		&user.Login,
+		&user.Role,
+		&user.BlockedAt,
+		&user.CreatedAt,
+	)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+
+		rep.logger.Errorf("---> ERROR: GetByID: %v\n", err)
+
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// Search finds users by a part of the login, the case is ignored.
+func (rep *userRepository) Search(ctx context.Context, login string, limit int) ([]models.User, error) {
+	defer metrics.ObserveDBQuery("user", "Search")()
+
+	query := `SELECT id, login, role, blocked_at, created_at FROM users
+			WHERE login ILIKE '%' || $1 || '%' ESCAPE '\'
+			ORDER BY login ASC
+			LIMIT $2;`
+
+	pattern := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(login)
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, pattern, limit)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: userRepository: query in Search: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+
+	for rows.Next() {
+		var user models.User
+
+		err := rows.Scan(&user.ID, &user.Login, &user.Role, &user.BlockedAt, &user.CreatedAt)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: Search: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: Search: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return users, nil
+}
+
+func (rep *userRepository) SetBlocked(ctx context.Context, userID uuid.UUID, blockedAt *time.Time) (bool, error) {
+	defer metrics.ObserveDBQuery("user", "SetBlocked")()
+
+	query := `UPDATE users SET blocked_at = $1 WHERE id = $2;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, blockedAt, userID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: SetBlocked: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+func (rep *userRepository) SetPassword(ctx context.Context, userID uuid.UUID, password string) (bool, error) {
+	defer metrics.ObserveDBQuery("user", "SetPassword")()
+
+	query := `UPDATE users SET password = $1 WHERE id = $2;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, password, userID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: SetPassword: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+func (rep *userRepository) SetRole(ctx context.Context, login string, role string) (bool, error) {
+	defer metrics.ObserveDBQuery("user", "SetRole")()
+
+	query := `UPDATE users SET role = $1 WHERE login = $2;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, role, login)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: SetRole: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
@@ -0,0 +1,257 @@
+package webhookrepository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v4"
+
+	"github.com/lexizz/cumloys/internal/db/dbclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+const deliveryColumns = `d.id, d.webhook_id, w.url, w.secret, e.id, e.user_id, e.type, e.data, e.created_at,
+			d.status, d.attempts, d.last_status_code, d.last_error, d.next_attempt_at, d.created_at, d.updated_at`
+
+type webhookRepository struct {
+	client  dbclient.ClientInterface
+	rwMutex *sync.RWMutex
+	logger  logger.Logger
+}
+
+var _ repository.WebhookRepositoryInterface = &webhookRepository{}
+
+func New(client dbclient.ClientInterface, logger logger.Logger) *webhookRepository {
+	rwMutex := sync.RWMutex{}
+
+	whRepository := webhookRepository{
+		client:  client,
+		rwMutex: &rwMutex,
+		logger:  logger,
+	}
+
+	return &whRepository
+}
+
+func (rep *webhookRepository) Insert(ctx context.Context, webhook *models.Webhook) error {
+	defer metrics.ObserveDBQuery("webhook", "Insert")()
+
+	query := `INSERT INTO webhooks (user_id, url, secret, created_at) VALUES ($1, $2, $3, $4) RETURNING id`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	err := rep.client.QueryRow(ctx, query, webhook.UserID.String(), webhook.URL, webhook.Secret, webhook.CreatedAt).Scan(&webhook.ID)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: insert webhook: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetAllByUserID returns webhooks without secrets.
+func (rep *webhookRepository) GetAllByUserID(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error) {
+	defer metrics.ObserveDBQuery("webhook", "GetAllByUserID")()
+
+	query := `SELECT id, user_id, url, created_at FROM webhooks WHERE user_id = $1 ORDER BY created_at ASC;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, userID.String())
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: webhookRepository: query in GetAllByUserID: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	defer rows.Close()
+
+	webhooks := make([]models.Webhook, 0)
+
+	for rows.Next() {
+		var webhook models.Webhook
+
+		err := rows.Scan(&webhook.ID, &webhook.UserID, &webhook.URL, &webhook.CreatedAt)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: GetAllByUserID: get row from scan: %v\n", err)
+			return nil, err
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: GetAllByUserID: rows next: %v\n", errRows)
+		return nil, errRows
+	}
+
+	return webhooks, nil
+}
+
+func (rep *webhookRepository) Delete(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) (bool, error) {
+	defer metrics.ObserveDBQuery("webhook", "Delete")()
+
+	query := `DELETE FROM webhooks WHERE id = $1 AND user_id = $2;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, webhookID.String(), userID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: delete webhook: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+// ClaimDeliveries selects pending deliveries whose time has come and moves their next_attempt_at to leaseUntil,
+// so other dispatchers don't send them at the same time.
+func (rep *webhookRepository) ClaimDeliveries(ctx context.Context, limit int, leaseUntil time.Time) ([]models.WebhookDelivery, error) {
+	defer metrics.ObserveDBQuery("webhook", "ClaimDeliveries")()
+
+	query := `UPDATE webhook_deliveries AS d SET next_attempt_at = $1
+			FROM outbox_events AS e, webhooks AS w
+			WHERE d.id IN (
+				SELECT id FROM webhook_deliveries
+				WHERE status = 'PENDING' AND next_attempt_at <= $2
+				ORDER BY next_attempt_at ASC
+				LIMIT $3
+				FOR UPDATE SKIP LOCKED
+			) AND e.id = d.event_id AND w.id = d.webhook_id
+			RETURNING ` + deliveryColumns + `;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, leaseUntil, utils.GetCurrentDatetimeUTC(), limit)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: webhookRepository: query in ClaimDeliveries: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	return rep.scanDeliveries(rows, "ClaimDeliveries")
+}
+
+func (rep *webhookRepository) UpdateDelivery(ctx context.Context, delivery *models.WebhookDelivery) error {
+	defer metrics.ObserveDBQuery("webhook", "UpdateDelivery")()
+
+	query := `UPDATE webhook_deliveries
+			SET (status, attempts, last_status_code, last_error, next_attempt_at, updated_at) = ($1, $2, $3, $4, $5, $6)
+			WHERE id = $7;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	_, err := rep.client.Exec(ctx, query,
+		delivery.Status,
+		delivery.Attempts,
+		delivery.LastStatusCode,
+		delivery.LastError,
+		delivery.NextAttemptAt,
+		utils.GetCurrentDatetimeUTC(),
+		delivery.ID.String(),
+	)
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: update webhook delivery: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// GetDeliveriesByUserID returns deliveries to the webhooks of the user with the status, empty status - any status.
+func (rep *webhookRepository) GetDeliveriesByUserID(ctx context.Context, userID uuid.UUID, status string) ([]models.WebhookDelivery, error) {
+	defer metrics.ObserveDBQuery("webhook", "GetDeliveriesByUserID")()
+
+	query := `SELECT ` + deliveryColumns + `
+			FROM webhook_deliveries AS d
+			INNER JOIN outbox_events AS e ON e.id = d.event_id
+			INNER JOIN webhooks AS w ON w.id = d.webhook_id
+			WHERE w.user_id = $1 AND ($2 = '' OR d.status::text = $2)
+			ORDER BY d.created_at ASC;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	rows, errQuery := rep.client.Query(ctx, query, userID.String(), status)
+	if errQuery != nil {
+		rep.logger.Errorf("---> ERROR: webhookRepository: query in GetDeliveriesByUserID: %v\n", errQuery)
+		return nil, errQuery
+	}
+
+	return rep.scanDeliveries(rows, "GetDeliveriesByUserID")
+}
+
+// Redeliver moves the dead delivery back to the queue with a fresh number of attempts.
+// It returns false when the user has no such dead delivery.
+func (rep *webhookRepository) Redeliver(ctx context.Context, userID uuid.UUID, deliveryID uuid.UUID) (bool, error) {
+	defer metrics.ObserveDBQuery("webhook", "Redeliver")()
+
+	query := `UPDATE webhook_deliveries AS d SET (status, attempts, next_attempt_at, updated_at) = ('PENDING', 0, $1, $1)
+			FROM webhooks AS w
+			WHERE d.id = $2 AND d.status = 'DEAD' AND w.id = d.webhook_id AND w.user_id = $3;`
+
+	rep.rwMutex.Lock()
+	defer rep.rwMutex.Unlock()
+
+	commandTag, err := rep.client.Exec(ctx, query, utils.GetCurrentDatetimeUTC(), deliveryID.String(), userID.String())
+	if err != nil {
+		rep.logger.Errorf("---> ERROR: redeliver webhook delivery: %v\n", err)
+		return false, err
+	}
+
+	return commandTag.RowsAffected() > 0, nil
+}
+
+func (rep *webhookRepository) scanDeliveries(rows pgx.Rows, method string) ([]models.WebhookDelivery, error) {
+	defer rows.Close()
+
+	deliveries := make([]models.WebhookDelivery, 0)
+
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		var data []byte
+
+		err := rows.Scan(
+			&delivery.ID,
+			&delivery.WebhookID,
+			&delivery.URL,
+			&delivery.Secret,
+			&delivery.Event.ID,
+			&delivery.Event.UserID,
+			&delivery.Event.Type,
+			&data,
+			&delivery.Event.CreatedAt,
+			&delivery.Status,
+			&delivery.Attempts,
+			&delivery.LastStatusCode,
+			&delivery.LastError,
+			&delivery.NextAttemptAt,
+			&delivery.CreatedAt,
+			&delivery.UpdatedAt,
+		)
+		if err != nil {
+			rep.logger.Errorf("---> ERROR: %v: get row from scan: %v\n", method, err)
+			return nil, err
+		}
+
+		delivery.Event.Data = data
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	if errRows := rows.Err(); errRows != nil {
+		rep.logger.Errorf("---> ERROR: %v: rows next: %v\n", method, errRows)
+		return nil, errRows
+	}
+
+	return deliveries, nil
+}
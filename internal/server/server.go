@@ -18,7 +18,16 @@ type Server struct {
 }
 
 func New(ctx context.Context, cfg *config.Config, handler http.Handler, logger logger.Logger) *Server {
-	newDNS, errParse := parseURL(cfg.HTTP.Address, logger)
+	return newServer(ctx, cfg.HTTP.Address, cfg, handler, logger)
+}
+
+// NewAdmin creates the server for the admin listener.
+func NewAdmin(ctx context.Context, cfg *config.Config, handler http.Handler, logger logger.Logger) *Server {
+	return newServer(ctx, cfg.Admin.Address, cfg, handler, logger)
+}
+
+func newServer(ctx context.Context, address string, cfg *config.Config, handler http.Handler, logger logger.Logger) *Server {
+	newDNS, errParse := parseURL(address, logger)
 	if errParse != nil {
 		return nil
 	}
@@ -30,6 +39,8 @@ func New(ctx context.Context, cfg *config.Config, handler http.Handler, logger l
 			Handler:           handler,
 			ReadTimeout:       cfg.HTTP.ReadTimeout,
 			ReadHeaderTimeout: cfg.HTTP.ReadTimeout,
+			WriteTimeout:      cfg.HTTP.WriteTimeout,
+			MaxHeaderBytes:    cfg.HTTP.MaxHeaderBytes,
 		},
 		log: logger,
 	}
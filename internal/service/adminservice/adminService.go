@@ -0,0 +1,266 @@
+package adminservice
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.AdminServiceInterface = &adminService{}
+
+// maxReasonLength - size of transactions.reason
+const maxReasonLength = 255
+
+var (
+	ErrUserNotFound      = errors.New("user not found")
+	ErrOwnAccount        = errors.New("admin can't block or adjust the own account")
+	ErrWrongAdjustment   = errors.New("sum of adjustment must be non-zero")
+	ErrWrongReason       = errors.New("reason of adjustment is required and must be up to 255 characters")
+	ErrInsufficientFunds = errors.New("insufficient funds")
+	ErrEmptyPassword     = errors.New("password must not be empty")
+)
+
+type adminService struct {
+	userRepository        repository.UserRepositoryInterface
+	transactionRepository repository.TransactionRepositoryInterface
+	sessionRepository     repository.SessionRepositoryInterface
+	unitOfWork            repository.UnitOfWorkInterface
+	eventPublisher        eventbus.PublisherInterface
+	logger                logger.Logger
+}
+
+func New(
+	userRepository repository.UserRepositoryInterface,
+	transactionRepository repository.TransactionRepositoryInterface,
+	sessionRepository repository.SessionRepositoryInterface,
+	unitOfWork repository.UnitOfWorkInterface,
+	eventPublisher eventbus.PublisherInterface,
+	logger logger.Logger,
+) *adminService {
+	return &adminService{
+		userRepository:        userRepository,
+		transactionRepository: transactionRepository,
+		sessionRepository:     sessionRepository,
+		unitOfWork:            unitOfWork,
+		eventPublisher:        eventPublisher,
+		logger:                logger,
+	}
+}
+
+func (service *adminService) SearchUsers(ctx context.Context, login string, limit int) ([]models.User, error) {
+	return service.userRepository.Search(ctx, login, limit)
+}
+
+func (service *adminService) GetUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, errUser := service.userRepository.GetByID(ctx, userID)
+	if errUser != nil {
+		return nil, errUser
+	}
+
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+
+	return user, nil
+}
+
+func (service *adminService) GetTransactions(
+	ctx context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.Transaction, *models.Cursor, error) {
+	_, errUser := service.GetUser(ctx, userID)
+	if errUser != nil {
+		return nil, nil, errUser
+	}
+
+	return service.transactionRepository.GetAllByUserID(ctx, userID, filter)
+}
+
+// Block forbids the user to log in and revokes the sessions, so issued access tokens stop working at once.
+func (service *adminService) Block(ctx context.Context, userID uuid.UUID, operatorID uuid.UUID) error {
+	if userID == operatorID {
+		return ErrOwnAccount
+	}
+
+	blockedAt := utils.GetCurrentDatetimeUTC()
+
+	errBlock := service.setBlocked(ctx, userID, &blockedAt)
+	if errBlock != nil {
+		return errBlock
+	}
+
+	errRevoke := service.sessionRepository.RevokeAllByUserID(ctx, userID)
+	if errRevoke != nil {
+		return errRevoke
+	}
+
+	service.logger.Infof("=== adminService: user %v is blocked by %v", userID, operatorID)
+
+	return nil
+}
+
+// Unblock allows the user to log in again, the revoked sessions stay revoked.
+func (service *adminService) Unblock(ctx context.Context, userID uuid.UUID, operatorID uuid.UUID) error {
+	errUnblock := service.setBlocked(ctx, userID, nil)
+	if errUnblock != nil {
+		return errUnblock
+	}
+
+	service.logger.Infof("=== adminService: user %v is unblocked by %v", userID, operatorID)
+
+	return nil
+}
+
+// ResetPassword replaces the password of the user and revokes the sessions, so the old password
+// and issued tokens stop working at once.
+func (service *adminService) ResetPassword(ctx context.Context, userID uuid.UUID, password string) error {
+	if len(password) == 0 {
+		return ErrEmptyPassword
+	}
+
+	passwordHash, errHash := utils.GeneratePasswordHash(password)
+	if errHash != nil {
+		return errHash
+	}
+
+	isFound, errPassword := service.userRepository.SetPassword(ctx, userID, passwordHash)
+	if errPassword != nil {
+		return errPassword
+	}
+
+	if !isFound {
+		return ErrUserNotFound
+	}
+
+	errRevoke := service.sessionRepository.RevokeAllByUserID(ctx, userID)
+	if errRevoke != nil {
+		return errRevoke
+	}
+
+	service.logger.Infof("=== adminService: password of user %v is reset", userID)
+
+	return nil
+}
+
+func (service *adminService) setBlocked(ctx context.Context, userID uuid.UUID, blockedAt *time.Time) error {
+	isFound, errBlock := service.userRepository.SetBlocked(ctx, userID, blockedAt)
+	if errBlock != nil {
+		return errBlock
+	}
+
+	if !isFound {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// Adjust credits positive points and debits negative ones, the ledger row keeps the reason and the operator.
+// A debit spends the oldest lots like a withdrawal does; credited points aren't put into lots, so they never expire.
+// It returns the balance of the user after the adjustment.
+func (service *adminService) Adjust(
+	ctx context.Context,
+	userID uuid.UUID,
+	operatorID uuid.UUID,
+	points models.Points,
+	reason string,
+) (models.Points, error) {
+	if points == 0 {
+		return 0, ErrWrongAdjustment
+	}
+
+	if len(reason) == 0 || len([]rune(reason)) > maxReasonLength {
+		return 0, ErrWrongReason
+	}
+
+	if userID == operatorID {
+		return 0, ErrOwnAccount
+	}
+
+	_, errUser := service.GetUser(ctx, userID)
+	if errUser != nil {
+		return 0, errUser
+	}
+
+	typeTransaction := models.AdjustmentCreditType
+	if points < 0 {
+		typeTransaction = models.AdjustmentDebitType
+	}
+
+	sum := points
+	if sum < 0 {
+		sum = -sum
+	}
+
+	var currentBalance models.Points
+
+	errAdjust := service.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		var errScore error
+
+		if typeTransaction == models.AdjustmentCreditType {
+			currentBalance, errScore = repositories.Score.Increase(ctx, userID, sum)
+		} else {
+			currentBalance, errScore = repositories.Score.Decrease(ctx, userID, sum)
+		}
+
+		if errScore != nil {
+			return errScore
+		}
+
+		errInsert := repositories.Transaction.InsertAdjustment(ctx, userID, sum, typeTransaction, reason, operatorID)
+		if errInsert != nil {
+			return errInsert
+		}
+
+		if typeTransaction == models.AdjustmentDebitType {
+			errConsume := repositories.PointLot.Consume(ctx, userID, sum)
+			if errConsume != nil {
+				return errConsume
+			}
+		}
+
+		event, errEvent := models.NewOutboxEvent(userID, models.EventPointsAdjusted, models.AdjustmentEventData{
+			Sum:    points,
+			Reason: reason,
+		}, utils.GetCurrentDatetimeUTC())
+		if errEvent != nil {
+			return errEvent
+		}
+
+		return repositories.Outbox.Insert(ctx, event)
+	})
+	if errAdjust != nil {
+		if errors.Is(errAdjust, repository.ErrInsufficientFunds) {
+			return 0, ErrInsufficientFunds
+		}
+
+		return 0, errAdjust
+	}
+
+	metricType := "credit"
+	if typeTransaction == models.AdjustmentDebitType {
+		metricType = "debit"
+	}
+
+	metrics.PointsAdjustedTotal.WithLabelValues(metricType).Add(sum.Float64())
+
+	service.logger.Infof("=== adminService: balance of user %v is adjusted by %v by %v: %v", userID, points, operatorID, reason)
+
+	service.eventPublisher.Publish(userID, models.UserEventBalance, models.BalanceEventData{
+		Current: currentBalance,
+		Change:  points,
+	})
+
+	return currentBalance, nil
+}
@@ -37,7 +37,13 @@ func New(
 	}
 }
 
-func (service *createOrderService) Handle(ctx context.Context, numberOrder string, userID uuid.UUID) (*models.Order, error) {
+// Handle - isWithdrawal is true for the order which is paid by a withdrawal, the accrual system isn't asked about it.
+func (service *createOrderService) Handle(
+	ctx context.Context,
+	numberOrder string,
+	userID uuid.UUID,
+	isWithdrawal bool,
+) (*models.Order, error) {
 	if len(numberOrder) < 1 {
 		return nil, errors.New("number order empty")
 	}
@@ -51,14 +57,19 @@ func (service *createOrderService) Handle(ctx context.Context, numberOrder strin
 		return nil, ErrOrderExists
 	}
 
-	lastInsertID, errInsert := service.orderRepository.Insert(ctx, numberOrder, userID)
+	lastInsertID, errInsert := service.orderRepository.Insert(ctx, numberOrder, userID, isWithdrawal)
 	if errInsert != nil {
+		if errors.Is(errInsert, repository.ErrDuplicateOrder) {
+			return nil, ErrOrderExists
+		}
+
 		return nil, ErrOrderCreation
 	}
 
 	return &models.Order{
-		ID:     *lastInsertID,
-		Number: numberOrder,
-		UserID: userID,
+		ID:           *lastInsertID,
+		Number:       numberOrder,
+		UserID:       userID,
+		IsWithdrawal: isWithdrawal,
 	}, nil
 }
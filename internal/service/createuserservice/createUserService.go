@@ -53,6 +53,11 @@ func (service *createUserService) Handle(ctx context.Context, newLogin string, n
 
 	lastInsertID, errInsert := service.userRepository.Insert(ctx, newLogin, passwordHash)
 	if errInsert != nil {
+		// the login could be taken by a concurrent registration after the check above
+		if errors.Is(errInsert, repository.ErrDuplicateLogin) {
+			return nil, ErrUserExists
+		}
+
 		return nil, ErrUserCreation
 	}
 
@@ -0,0 +1,143 @@
+package expirepointsservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.ExpirePointsServiceInterface = &expirePointsService{}
+
+type expirePointsService struct {
+	unitOfWork     repository.UnitOfWorkInterface
+	eventPublisher eventbus.PublisherInterface
+	logger         logger.Logger
+}
+
+func New(
+	unitOfWork repository.UnitOfWorkInterface,
+	eventPublisher eventbus.PublisherInterface,
+	logger logger.Logger,
+) *expirePointsService {
+	return &expirePointsService{
+		unitOfWork:     unitOfWork,
+		eventPublisher: eventPublisher,
+		logger:         logger,
+	}
+}
+
+// ExpireByUserID writes off the points left in the lots of the user earned before earnedBefore:
+// every lot gets its own expiration transaction in the ledger, so the ledger shows which accrual has expired.
+// A negative or low balance limits the written off points, see writeOffLots. It returns the sum of the expired points.
+func (service *expirePointsService) ExpireByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	earnedBefore time.Time,
+) (models.Points, error) {
+	var expiredPoints, currentBalance models.Points
+
+	errExpire := service.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		expiredPoints = 0
+
+		// the score is locked before the lots like withdrawals do, so they can't deadlock
+		score, errLock := repositories.Score.GetScoreByUserIDForUpdate(ctx, userID)
+		if errLock != nil {
+			return errLock
+		}
+
+		lots, errLots := repositories.PointLot.Expire(ctx, userID, earnedBefore)
+		if errLots != nil {
+			return errLots
+		}
+
+		var balance models.Points
+		if score != nil {
+			balance = score.Total
+		}
+
+		writtenOff := writeOffLots(lots, balance)
+
+		for _, lot := range writtenOff {
+			expiredPoints = expiredPoints.Add(lot.Remaining)
+		}
+
+		if expiredPoints <= 0 {
+			return nil
+		}
+
+		var errDecrease error
+
+		currentBalance, errDecrease = repositories.Score.Decrease(ctx, userID, expiredPoints)
+		if errDecrease != nil {
+			return errDecrease
+		}
+
+		for _, lot := range writtenOff {
+			errInsert := repositories.Transaction.Insert(ctx, userID, lot.OrderID, lot.Remaining, models.ExpirePointsType)
+			if errInsert != nil {
+				return errInsert
+			}
+		}
+
+		event, errEvent := models.NewOutboxEvent(userID, models.EventPointsExpired, models.ExpirationEventData{
+			Sum: expiredPoints,
+		}, utils.GetCurrentDatetimeUTC())
+		if errEvent != nil {
+			return errEvent
+		}
+
+		return repositories.Outbox.Insert(ctx, event)
+	})
+	if errExpire != nil {
+		service.logger.Errorf("---> ERROR: expirePointsService: user %v: %v", userID, errExpire)
+		return 0, errExpire
+	}
+
+	if expiredPoints <= 0 {
+		return 0, nil
+	}
+
+	metrics.PointsExpiredTotal.Add(expiredPoints.Float64())
+
+	service.eventPublisher.Publish(userID, models.UserEventBalance, models.BalanceEventData{
+		Current: currentBalance,
+		Change:  -expiredPoints,
+	})
+
+	return expiredPoints, nil
+}
+
+// writeOffLots returns the expired lots with the points to write off, they are no more than the balance.
+// The balance is lower than the lots after a reversal with the debt policy: the later accruals pay the debt,
+// but their lots stay. The lots expire anyway, so the user isn't picked up again on every run.
+func writeOffLots(lots []models.PointLot, balance models.Points) []models.PointLot {
+	writtenOff := make([]models.PointLot, 0, len(lots))
+
+	for _, lot := range lots {
+		if balance <= 0 {
+			break
+		}
+
+		if lot.Remaining > balance {
+			lot.Remaining = balance
+		}
+
+		if lot.Remaining <= 0 {
+			continue
+		}
+
+		balance = balance.Sub(lot.Remaining)
+		writtenOff = append(writtenOff, lot)
+	}
+
+	return writtenOff
+}
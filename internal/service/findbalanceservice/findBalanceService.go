@@ -2,11 +2,14 @@ package findbalanceservice
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
+	"github.com/lexizz/cumloys/internal/config"
 	"github.com/lexizz/cumloys/internal/models"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 	"github.com/lexizz/cumloys/internal/service"
 )
@@ -14,19 +17,25 @@ import (
 var _ service.FindBalanceServiceInterface = &findBalanceService{}
 
 type findBalanceService struct {
+	cfg                   *config.Config
 	scoreRepository       repository.ScoreRepositoryInterface
 	transactionRepository repository.TransactionRepositoryInterface
+	pointLotRepository    repository.PointLotRepositoryInterface
 	logger                logger.Logger
 }
 
 func New(
+	cfg *config.Config,
 	scoreRepository repository.ScoreRepositoryInterface,
 	transactionRepository repository.TransactionRepositoryInterface,
+	pointLotRepository repository.PointLotRepositoryInterface,
 	logger logger.Logger,
 ) *findBalanceService {
 	return &findBalanceService{
+		cfg:                   cfg,
 		scoreRepository:       scoreRepository,
 		transactionRepository: transactionRepository,
+		pointLotRepository:    pointLotRepository,
 		logger:                logger,
 	}
 }
@@ -34,15 +43,45 @@ func New(
 func (service *findBalanceService) GetBalanceByUserID(ctx context.Context, userID uuid.UUID) *models.TotalScoreWithdraw {
 	totalScore := models.TotalScoreWithdraw{}
 
-	score, _ := service.scoreRepository.GetScoreByUserID(ctx, userID)
-	if score == nil {
-		totalScore.Total = 0
+	if service.cfg.Ledger.BalanceFromLedger {
+		totalScore.Total, _ = service.transactionRepository.GetLedgerBalance(ctx, userID)
 	} else {
-		totalScore.Total = score.Total
+		score, _ := service.scoreRepository.GetScoreByUserID(ctx, userID)
+		if score == nil {
+			totalScore.Total = 0
+		} else {
+			totalScore.Total = score.Total
+		}
 	}
 
 	withdrawPoints, _ := service.transactionRepository.GetSumFundsWithdrawn(ctx, userID)
 	totalScore.Withdraw = withdrawPoints
 
+	service.fillExpiringSoon(ctx, userID, &totalScore)
+
 	return &totalScore
 }
+
+// fillExpiringSoon adds the points which expire within the warning period, when the expiration is enabled.
+func (service *findBalanceService) fillExpiringSoon(ctx context.Context, userID uuid.UUID, totalScore *models.TotalScoreWithdraw) {
+	months := service.cfg.Expiration.Months
+	if months <= 0 {
+		return
+	}
+
+	soonMoment := utils.GetCurrentDatetimeUTC().Add(service.cfg.Expiration.SoonPeriod)
+
+	expiringPoints, oldestEarnedAt, errExpiring := service.pointLotRepository.GetExpiring(
+		ctx,
+		userID,
+		models.EarnedBeforeToExpire(soonMoment, months),
+	)
+	if errExpiring != nil || expiringPoints <= 0 || oldestEarnedAt == nil {
+		return
+	}
+
+	expiresAt := models.PointLot{EarnedAt: *oldestEarnedAt}.ExpiresAt(months).Truncate(time.Second)
+
+	totalScore.ExpiringSoon = expiringPoints
+	totalScore.NextExpirationAt = &expiresAt
+}
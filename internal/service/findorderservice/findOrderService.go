@@ -31,17 +31,22 @@ func New(orderRepository repository.OrderRepositoryInterface, logger logger.Logg
 	}
 }
 
-func (service *findOrderService) GetOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]models.Order, error) {
-	orders, errQuery := service.orderRepository.GetAllByUserID(ctx, userID)
+// GetOrdersByUserID returns the page of orders and the cursor of the next page, nil on the last page.
+func (service *findOrderService) GetOrdersByUserID(
+	ctx context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.Order, *models.Cursor, error) {
+	orders, next, errQuery := service.orderRepository.GetAllByUserID(ctx, userID, filter)
 	if errQuery != nil {
-		return nil, ErrInternal
+		return nil, nil, ErrInternal
 	}
 
 	if orders != nil {
-		return orders, nil
+		return orders, next, nil
 	}
 
-	return nil, ErrOrderNotFound
+	return nil, nil, ErrOrderNotFound
 }
 
 func (service *findOrderService) IsExistsOrder(ctx context.Context, numberOrder string) (bool, *uuid.UUID, *uuid.UUID) {
@@ -25,8 +25,11 @@ func New(
 	}
 }
 
-func (service *findWithdrawPointsService) Handle(ctx context.Context, userID uuid.UUID) []models.ScoreWithdraw {
-	transactions, _ := service.transactionRepository.GetAllFundsWithdrawn(ctx, userID)
-
-	return transactions
+// Handle returns the page of withdrawals and the cursor of the next page, nil on the last page.
+func (service *findWithdrawPointsService) Handle(
+	ctx context.Context,
+	userID uuid.UUID,
+	filter models.ListFilter,
+) ([]models.ScoreWithdraw, *models.Cursor, error) {
+	return service.transactionRepository.GetAllFundsWithdrawn(ctx, userID, filter)
 }
@@ -0,0 +1,34 @@
+package gettingpointsservice
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/bonusrules"
+	"github.com/lexizz/cumloys/internal/repository"
+)
+
+// bonusHistory reads the past of the user for the bonus rules inside the transaction which credits the order.
+type bonusHistory struct {
+	repositories *repository.Repositories
+	userID       uuid.UUID
+}
+
+var _ bonusrules.HistoryInterface = &bonusHistory{}
+
+// IsFirstProcessedOrder is asked before the order gets PROCESSED, so the user must have no processed orders yet.
+func (history *bonusHistory) IsFirstProcessedOrder(ctx context.Context) (bool, error) {
+	count, errCount := history.repositories.Order.CountByUserIDAndStatus(ctx, history.userID, models.OrderStatusProcessed)
+	if errCount != nil {
+		return false, errCount
+	}
+
+	return count == 0, nil
+}
+
+func (history *bonusHistory) GetBonusSince(ctx context.Context, ruleID string, since time.Time) (models.Points, error) {
+	return history.repositories.Transaction.GetSumBonus(ctx, history.userID, ruleID, since)
+}
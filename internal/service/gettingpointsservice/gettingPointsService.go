@@ -2,174 +2,313 @@ package gettingpointsservice
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
-	"io"
-	"net/http"
-	"time"
 
 	"github.com/google/uuid"
 
-	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/client"
+	"github.com/lexizz/cumloys/internal/client/accrualclient"
 	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/bonusrules"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/reversalservice"
 )
 
 var _ service.GettingPointsServiceInterface = &gettingPointsService{}
 
-const requestTimeout time.Duration = 2
+const (
+	accrualStatusRegistered = "REGISTERED"
 
-type gettingPointsService struct {
-	cfg                   *config.Config
-	httpClient            *http.Client
-	createOrderService    service.CreateOrderServiceInterface
-	orderRepository       repository.OrderRepositoryInterface
-	scoreRepository       repository.ScoreRepositoryInterface
-	transactionRepository repository.TransactionRepositoryInterface
-	logger                logger.Logger
-}
+	reasonInvalidOrder = "order is INVALID in the accrual system"
+)
+
+var ErrUnknownStatus = errors.New("unknown status of order from the accrual system")
 
-type responseOrderData struct {
-	Number string  `json:"order,omitempty"`
-	Status string  `json:"status,omitempty"`
-	Points float32 `json:"accrual,omitempty"`
+type gettingPointsService struct {
+	accrualClient      client.AccrualClientInterface
+	createOrderService service.CreateOrderServiceInterface
+	orderRepository    repository.OrderRepositoryInterface
+	unitOfWork         repository.UnitOfWorkInterface
+	bonusEvaluator     bonusrules.EvaluatorInterface
+	tierService        service.TierServiceInterface
+	reversalService    service.ReversalServiceInterface
+	eventPublisher     eventbus.PublisherInterface
+	logger             logger.Logger
 }
 
 func New(
-	cfg *config.Config,
-	httpClient *http.Client,
+	accrualClient client.AccrualClientInterface,
 	createOrderService service.CreateOrderServiceInterface,
 	orderRepository repository.OrderRepositoryInterface,
-	scoreRepository repository.ScoreRepositoryInterface,
-	transactionRepository repository.TransactionRepositoryInterface,
+	unitOfWork repository.UnitOfWorkInterface,
+	bonusEvaluator bonusrules.EvaluatorInterface,
+	tierService service.TierServiceInterface,
+	reversalService service.ReversalServiceInterface,
+	eventPublisher eventbus.PublisherInterface,
 	logger logger.Logger,
 ) *gettingPointsService {
 	return &gettingPointsService{
-		cfg:                   cfg,
-		httpClient:            httpClient,
-		createOrderService:    createOrderService,
-		orderRepository:       orderRepository,
-		scoreRepository:       scoreRepository,
-		transactionRepository: transactionRepository,
-		logger:                logger,
+		accrualClient:      accrualClient,
+		createOrderService: createOrderService,
+		orderRepository:    orderRepository,
+		unitOfWork:         unitOfWork,
+		bonusEvaluator:     bonusEvaluator,
+		tierService:        tierService,
+		reversalService:    reversalService,
+		eventPublisher:     eventPublisher,
+		logger:             logger,
 	}
 }
 
+// Handle registers the order. Points are requested later by the accrual worker,
+// which picks up every order with a non-final status.
 func (service *gettingPointsService) Handle(ctx context.Context, numberOrder string, userID uuid.UUID) error {
-	order, errCreate := service.createOrderService.Handle(ctx, numberOrder, userID)
+	_, errCreate := service.createOrderService.Handle(ctx, numberOrder, userID, false)
 	if errCreate != nil {
 		return errors.New("error creating order: " + errCreate.Error())
 	}
 
-	go func(numberOrder string, userID uuid.UUID) {
-		ctxTimeout, cancelCtxTimeout := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancelCtxTimeout()
+	return nil
+}
+
+// ProcessOrder requests the state of the order from the accrual system and saves it.
+// It returns true when the order has reached a final status and doesn't need polling any more.
+func (service *gettingPointsService) ProcessOrder(ctx context.Context, order models.Order) (bool, error) {
+	responseData, errRequest := service.accrualClient.GetOrder(ctx, order.Number)
+	if errRequest != nil {
+		if errors.Is(errRequest, accrualclient.ErrOrderNotRegistered) {
+			return false, nil
+		}
+
+		return false, errRequest
+	}
+
+	status, errStatus := convertAccrualStatus(responseData.Status)
+	if errStatus != nil {
+		service.logger.Errorf("---> ERROR: gettingPointsService: order %v: %v: %v", order.Number, errStatus, responseData.Status)
+		return false, errStatus
+	}
 
-		responseData, err := service.sendRequest(numberOrder)
-		if err != nil {
-			return
+	if !models.IsFinalOrderStatus(status) {
+		if status == order.Status {
+			return false, nil
 		}
 
-		errUpdateOrder := service.orderRepository.Update(ctxTimeout, numberOrder, userID, responseData.Status, responseData.Points)
+		errUpdateOrder := service.orderRepository.Update(ctx, order.Number, order.UserID, status, 0)
 		if errUpdateOrder != nil {
-			return
+			if errors.Is(errUpdateOrder, repository.ErrOrderFinalized) {
+				return true, nil
+			}
+
+			return false, errUpdateOrder
 		}
 
-		score, errScore := service.scoreRepository.GetScoreByUserID(ctxTimeout, userID)
-		if errScore != nil {
-			return
+		service.publishOrder(order, status, 0)
+
+		return false, nil
+	}
+
+	return service.saveFinalStatus(ctx, order, status, responseData.Points)
+}
+
+// AbandonOrder makes the order INVALID when the accrual system hasn't given its final status
+// in the allowed number of polls, e.g. it has never heard of the order.
+func (service *gettingPointsService) AbandonOrder(ctx context.Context, order models.Order) error {
+	_, errSave := service.saveFinalStatus(ctx, order, models.OrderStatusInvalid, 0)
+
+	return errSave
+}
+
+// saveFinalStatus saves the final status of the order and credits the accrual with the bonuses of a processed order.
+// It returns true when the order has the final status, also when it was saved before.
+func (service *gettingPointsService) saveFinalStatus(
+	ctx context.Context,
+	order models.Order,
+	status string,
+	responseAccrual models.Points,
+) (bool, error) {
+	var accrual models.Points
+	var tier models.Tier
+
+	if status == models.OrderStatusProcessed {
+		accrual = responseAccrual
+
+		var errTier error
+
+		tier, errTier = service.tierService.GetCurrentTier(ctx, order.UserID)
+		if errTier != nil {
+			service.logger.Errorf("---> ERROR: gettingPointsService: order %v: getting tier: %v", order.Number, errTier)
+			return false, errTier
 		}
+	}
+
+	var points, currentBalance models.Points
+	var bonuses []bonusrules.Bonus
+
+	// the status of the order, the score, the ledger, the lot and the outbox event are changed together,
+	// so the points can't be lost or credited twice and the event is sent only about the saved status
+	errSave := service.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		points = accrual
+		bonuses = nil
+
+		if status == models.OrderStatusProcessed {
+			var errBonuses error
 
-		if score != nil {
-			score.Total += responseData.Points
+			bonuses, errBonuses = service.evaluateBonuses(ctx, repositories, order, accrual)
+			if errBonuses != nil {
+				return errBonuses
+			}
 
-			errScoreUpdate := service.scoreRepository.Update(ctxTimeout, userID, score.Total)
-			if errScoreUpdate != nil {
-				return
+			if tierBonus := bonusrules.TierBonus(tier, accrual); tierBonus.Points > 0 {
+				bonuses = append(bonuses, tierBonus)
 			}
-		} else {
-			_, errScoreInsert := service.scoreRepository.Insert(ctxTimeout, userID, responseData.Points)
-			if errScoreInsert != nil {
-				return
+
+			for _, bonus := range bonuses {
+				points = points.Add(bonus.Points)
 			}
 		}
 
-		errTransactionInsert := service.transactionRepository.Insert(
-			ctxTimeout,
-			userID,
-			order.ID,
-			responseData.Points,
-			models.IncreasePointsType,
-		)
-		if errTransactionInsert != nil {
-			return
+		errUpdateOrder := repositories.Order.Update(ctx, order.Number, order.UserID, status, points)
+		if errUpdateOrder != nil {
+			return errUpdateOrder
 		}
-	}(numberOrder, userID)
 
-	return nil
-}
+		event, errEvent := models.NewOutboxEvent(order.UserID, models.OrderEventType(status), models.OrderEventData{
+			Number:  order.Number,
+			Status:  status,
+			Accrual: points,
+		}, utils.GetCurrentDatetimeUTC())
+		if errEvent != nil {
+			return errEvent
+		}
+
+		errInsertEvent := repositories.Outbox.Insert(ctx, event)
+		if errInsertEvent != nil {
+			return errInsertEvent
+		}
 
-func (service *gettingPointsService) sendRequest(numberOrder string) (*responseOrderData, error) {
-	accrualAddress := service.cfg.IncomingParams.AccrualSystemAddress
-	url := accrualAddress + "/api/orders/" + numberOrder
+		if points <= 0 {
+			return nil
+		}
 
-	service.logger.Infof("=== Url accrual: %v", url)
+		var errScoreIncrease error
 
-	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout*time.Second)
-	defer cancel()
+		currentBalance, errScoreIncrease = repositories.Score.Increase(ctx, order.UserID, points)
+		if errScoreIncrease != nil {
+			return errScoreIncrease
+		}
 
-	var buf io.Reader
+		// the ledger keeps the accrual of the accrual system and every bonus separately
+		if accrual > 0 {
+			errInsertTransaction := repositories.Transaction.Insert(ctx, order.UserID, order.ID, accrual, models.IncreasePointsType)
+			if errInsertTransaction != nil {
+				return errInsertTransaction
+			}
+		}
 
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, buf)
-	if err != nil {
-		service.logger.Errorf("---> ERROR: gettingPointsService: NewRequestWithContext: %v\n", err)
-		return nil, err
+		for _, bonus := range bonuses {
+			errInsertBonus := repositories.Transaction.InsertBonus(ctx, order.UserID, order.ID, bonus.Points, bonus.RuleID)
+			if errInsertBonus != nil {
+				return errInsertBonus
+			}
+		}
+
+		return repositories.PointLot.Insert(ctx, order.UserID, order.ID, points)
+	})
+	if errSave != nil {
+		if errors.Is(errSave, repository.ErrOrderFinalized) {
+			return true, nil
+		}
+
+		return false, errSave
 	}
 
-	response, err := service.httpClient.Do(request)
-	if err != nil {
-		service.logger.Errorf("---> ERROR: gettingPointsService: request to accrual: %v\n", err)
-		return nil, err
+	metrics.PointsAccruedTotal.Add(accrual.Float64())
+
+	if accrual > 0 {
+		_, errRecalculate := service.tierService.Recalculate(ctx, order.UserID)
+		if errRecalculate != nil {
+			service.logger.Errorf("---> ERROR: gettingPointsService: order %v: recalculating tier: %v", order.Number, errRecalculate)
+		}
 	}
 
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
-		service.logger.Errorf("---> ERROR: gettingPointsService: failed read response body: %v\n", err)
-		return nil, err
+	for _, bonus := range bonuses {
+		metrics.PointsBonusTotal.WithLabelValues(bonus.RuleID).Add(bonus.Points.Float64())
 	}
 
-	service.logger.Infof("=== Response accrual status: %v | Body: %v", response.Status, string(body))
+	service.publishOrder(order, status, points)
 
-	errorResponseClose := response.Body.Close()
-	if errorResponseClose != nil {
-		service.logger.Errorf("---> ERROR: gettingPointsService: failed response body close: %v\n", err)
-		return nil, errorResponseClose
+	if status == models.OrderStatusInvalid {
+		service.reverseInvalidOrder(ctx, order)
 	}
 
-	if response.StatusCode > http.StatusNoContent {
-		service.logger.Errorf("---> ERROR: gettingPointsService: failed request: status: %v", response.Status)
-		return nil, errors.New("data not found")
+	if points > 0 {
+		service.eventPublisher.Publish(order.UserID, models.UserEventBalance, models.BalanceEventData{
+			Current: currentBalance,
+			Change:  points,
+		})
 	}
 
-	responseData := responseOrderData{}
+	return true, nil
+}
 
-	if response.StatusCode == http.StatusNoContent {
-		service.logger.Infof("=== points by this number of order not found: %v", response.Status)
+// reverseInvalidOrder reverses automatically what was written for the order which turned out INVALID,
+// e.g. a withdrawal against an uploaded order which the shop has never placed. A failed reversal is only logged,
+// an admin can repeat it with the admin API.
+func (service *gettingPointsService) reverseInvalidOrder(ctx context.Context, order models.Order) {
+	_, errReverse := service.reversalService.ReverseOrder(ctx, order.Number, nil, reasonInvalidOrder)
+	if errReverse == nil {
+		service.logger.Infof("=== gettingPointsService: transactions of INVALID order %v are reversed", order.Number)
+		return
+	}
 
-		responseData.Number = numberOrder
-		responseData.Points = 0
-		responseData.Status = "NEW"
+	if errors.Is(errReverse, reversalservice.ErrNotReversible) || errors.Is(errReverse, reversalservice.ErrAlreadyReversed) {
+		return
 	}
 
-	errDecode := json.Unmarshal(body, &responseData)
-	if errDecode != nil {
-		service.logger.Errorf("---> ERROR: gettingPointsService: json decode: %v\n", errDecode)
-		return nil, errDecode
+	service.logger.Errorf("---> ERROR: gettingPointsService: order %v: reversing INVALID order: %v", order.Number, errReverse)
+}
+
+// evaluateBonuses applies the bonus rules to the accrual of the order. The score of the user is locked first,
+// so concurrent orders of one user see the bonuses of each other, e.g. only one of them is the first order.
+func (service *gettingPointsService) evaluateBonuses(
+	ctx context.Context,
+	repositories *repository.Repositories,
+	order models.Order,
+	accrual models.Points,
+) ([]bonusrules.Bonus, error) {
+	_, errLock := repositories.Score.Increase(ctx, order.UserID, 0)
+	if errLock != nil {
+		return nil, errLock
 	}
 
-	service.logger.Infof("=== Response data: %+v", responseData)
+	return service.bonusEvaluator.Evaluate(ctx, accrual, order.CreatedAt, &bonusHistory{
+		repositories: repositories,
+		userID:       order.UserID,
+	})
+}
+
+func (service *gettingPointsService) publishOrder(order models.Order, status string, points models.Points) {
+	service.eventPublisher.Publish(order.UserID, models.UserEventOrder, models.OrderEventData{
+		Number:  order.Number,
+		Status:  status,
+		Accrual: points,
+	})
+}
 
-	return &responseData, nil
+// convertAccrualStatus maps a status of the accrual system to a status of the order.
+func convertAccrualStatus(accrualStatus string) (string, error) {
+	switch accrualStatus {
+	case accrualStatusRegistered:
+		return models.OrderStatusNew, nil
+	case models.OrderStatusProcessing, models.OrderStatusInvalid, models.OrderStatusProcessed:
+		return accrualStatus, nil
+	default:
+		return "", ErrUnknownStatus
+	}
 }
@@ -0,0 +1,324 @@
+package gettingpointsservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/client/accrualclient"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/bonusrules"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/repository/memoryrepository"
+	"github.com/lexizz/cumloys/internal/service/createorderservice"
+	"github.com/lexizz/cumloys/internal/service/reversalservice"
+	"github.com/lexizz/cumloys/internal/service/tierservice"
+)
+
+var errAccrualUnavailable = errors.New("accrual system is unavailable")
+
+// accrualClient answers every order with the same response.
+type accrualClient struct {
+	response *models.AccrualOrder
+	err      error
+}
+
+func (client *accrualClient) GetOrder(_ context.Context, numberOrder string) (*models.AccrualOrder, error) {
+	if client.err != nil {
+		return nil, client.err
+	}
+
+	response := *client.response
+	response.Number = numberOrder
+
+	return &response, nil
+}
+
+func (client *accrualClient) PausedUntil() time.Time {
+	return time.Time{}
+}
+
+func (client *accrualClient) Ping(_ context.Context) error {
+	return nil
+}
+
+type testEnv struct {
+	service               *gettingPointsService
+	accrualClient         *accrualClient
+	orderRepository       repository.OrderRepositoryInterface
+	scoreRepository       repository.ScoreRepositoryInterface
+	transactionRepository repository.TransactionRepositoryInterface
+}
+
+func newTestEnv(t *testing.T, rules string) *testEnv {
+	t.Helper()
+
+	log := logger.Init()
+	storage := memoryrepository.NewStorage()
+	orderRepository := memoryrepository.NewOrderRepository(storage, log)
+	transactionRepository := memoryrepository.NewTransactionRepository(storage, log)
+	unitOfWork := memoryrepository.NewUnitOfWork(storage, log)
+	eventBus := eventbus.New(16, log)
+
+	bonusEngine, errRules := bonusrules.Parse([]byte(rules))
+	if errRules != nil {
+		t.Fatalf("parsing the bonus rules: %v", errRules)
+	}
+
+	tierService := tierservice.New(bonusEngine.Tiers(), memoryrepository.NewTierRepository(storage, log), transactionRepository, log)
+	reversalService := reversalservice.New(
+		models.ReversalPolicyClamp,
+		orderRepository,
+		transactionRepository,
+		unitOfWork,
+		tierService,
+		eventBus,
+		log,
+	)
+
+	env := &testEnv{
+		accrualClient:         &accrualClient{},
+		orderRepository:       orderRepository,
+		scoreRepository:       memoryrepository.NewScoreRepository(storage, log),
+		transactionRepository: transactionRepository,
+	}
+
+	env.service = New(
+		env.accrualClient,
+		createorderservice.New(orderRepository, transactionRepository, log),
+		orderRepository,
+		unitOfWork,
+		bonusEngine,
+		tierService,
+		reversalService,
+		eventBus,
+		log,
+	)
+
+	return env
+}
+
+// createOrder uploads the order and returns it the way the accrual worker claims it.
+func (env *testEnv) createOrder(t *testing.T, number string, userID uuid.UUID) models.Order {
+	t.Helper()
+
+	errHandle := env.service.Handle(context.Background(), number, userID)
+	if errHandle != nil {
+		t.Fatalf("uploading the order: %v", errHandle)
+	}
+
+	return env.getOrder(t, number, userID)
+}
+
+func (env *testEnv) getOrder(t *testing.T, number string, userID uuid.UUID) models.Order {
+	t.Helper()
+
+	orders, _, errOrders := env.orderRepository.GetAllByUserID(context.Background(), userID, models.ListFilter{})
+	if errOrders != nil {
+		t.Fatalf("getting the orders: %v", errOrders)
+	}
+
+	for _, order := range orders {
+		if order.Number == number {
+			return order
+		}
+	}
+
+	t.Fatalf("order %v not found", number)
+
+	return models.Order{}
+}
+
+func (env *testEnv) checkBalance(t *testing.T, userID uuid.UUID, want models.Points) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	score, errScore := env.scoreRepository.GetScoreByUserID(ctx, userID)
+	if errScore != nil {
+		t.Fatalf("getting the score: %v", errScore)
+	}
+
+	var balance models.Points
+	if score != nil {
+		balance = score.Total
+	}
+
+	if balance != want {
+		t.Errorf("balance = %v, want %v", balance, want)
+	}
+
+	ledger, errLedger := env.transactionRepository.GetLedgerBalance(ctx, userID)
+	if errLedger != nil {
+		t.Fatalf("getting the ledger balance: %v", errLedger)
+	}
+
+	if ledger != want {
+		t.Errorf("ledger balance = %v, want %v", ledger, want)
+	}
+}
+
+func TestProcessOrder(t *testing.T) {
+	tests := []struct {
+		name        string
+		response    *models.AccrualOrder
+		err         error
+		wantFinal   bool
+		wantErr     error
+		wantStatus  string
+		wantAccrual models.Points
+	}{
+		{
+			name:        "processed",
+			response:    &models.AccrualOrder{Status: models.OrderStatusProcessed, Points: 50050},
+			wantFinal:   true,
+			wantStatus:  models.OrderStatusProcessed,
+			wantAccrual: 50050,
+		},
+		{
+			name:       "processed without points",
+			response:   &models.AccrualOrder{Status: models.OrderStatusProcessed},
+			wantFinal:  true,
+			wantStatus: models.OrderStatusProcessed,
+		},
+		{
+			name:       "invalid",
+			response:   &models.AccrualOrder{Status: models.OrderStatusInvalid},
+			wantFinal:  true,
+			wantStatus: models.OrderStatusInvalid,
+		},
+		{
+			name:       "processing",
+			response:   &models.AccrualOrder{Status: models.OrderStatusProcessing},
+			wantStatus: models.OrderStatusProcessing,
+		},
+		{
+			name:       "registered",
+			response:   &models.AccrualOrder{Status: accrualStatusRegistered},
+			wantStatus: models.OrderStatusNew,
+		},
+		{
+			name:       "not registered",
+			err:        accrualclient.ErrOrderNotRegistered,
+			wantStatus: models.OrderStatusNew,
+		},
+		{
+			name:       "accrual system is unavailable",
+			err:        errAccrualUnavailable,
+			wantErr:    errAccrualUnavailable,
+			wantStatus: models.OrderStatusNew,
+		},
+		{
+			name:       "unknown status",
+			response:   &models.AccrualOrder{Status: "LOST"},
+			wantErr:    ErrUnknownStatus,
+			wantStatus: models.OrderStatusNew,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, `{}`)
+			env.accrualClient.response = tt.response
+			env.accrualClient.err = tt.err
+
+			userID := uuid.New()
+			order := env.createOrder(t, "12345678903", userID)
+
+			isFinal, err := env.service.ProcessOrder(context.Background(), order)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ProcessOrder() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if isFinal != tt.wantFinal {
+				t.Errorf("ProcessOrder() = %v, want %v", isFinal, tt.wantFinal)
+			}
+
+			saved := env.getOrder(t, order.Number, userID)
+			if saved.Status != tt.wantStatus {
+				t.Errorf("status = %v, want %v", saved.Status, tt.wantStatus)
+			}
+
+			if saved.Points != tt.wantAccrual {
+				t.Errorf("accrual = %v, want %v", saved.Points, tt.wantAccrual)
+			}
+
+			env.checkBalance(t, userID, tt.wantAccrual)
+		})
+	}
+}
+
+func TestProcessOrderCreditsOnce(t *testing.T) {
+	env := newTestEnv(t, `{}`)
+	env.accrualClient.response = &models.AccrualOrder{Status: models.OrderStatusProcessed, Points: 50000}
+
+	userID := uuid.New()
+	order := env.createOrder(t, "12345678903", userID)
+
+	// the order claimed by two workers is processed twice with the same non-final status
+	for i := 0; i < 2; i++ {
+		isFinal, err := env.service.ProcessOrder(context.Background(), order)
+		if err != nil {
+			t.Fatalf("ProcessOrder() #%d unexpected error: %v", i+1, err)
+		}
+
+		if !isFinal {
+			t.Errorf("ProcessOrder() #%d = false, want true", i+1)
+		}
+	}
+
+	env.checkBalance(t, userID, 50000)
+}
+
+func TestProcessOrderBonuses(t *testing.T) {
+	env := newTestEnv(t, `{"rules":[{"id":"welcome","type":"bonus","points":50,"first_order":true}]}`)
+	env.accrualClient.response = &models.AccrualOrder{Status: models.OrderStatusProcessed, Points: 50000}
+
+	userID := uuid.New()
+
+	// the default tiers don't multiply the accrual, only the first order gets the bonus
+	wantAccruals := map[string]models.Points{
+		"12345678903": 55000,
+		"2377225624":  50000,
+	}
+
+	for _, number := range []string{"12345678903", "2377225624"} {
+		order := env.createOrder(t, number, userID)
+
+		_, err := env.service.ProcessOrder(context.Background(), order)
+		if err != nil {
+			t.Fatalf("ProcessOrder(%v) unexpected error: %v", number, err)
+		}
+
+		saved := env.getOrder(t, number, userID)
+		if saved.Points != wantAccruals[number] {
+			t.Errorf("accrual of %v = %v, want %v", number, saved.Points, wantAccruals[number])
+		}
+	}
+
+	env.checkBalance(t, userID, 105000)
+}
+
+func TestAbandonOrder(t *testing.T) {
+	env := newTestEnv(t, `{}`)
+
+	userID := uuid.New()
+	order := env.createOrder(t, "12345678903", userID)
+
+	errAbandon := env.service.AbandonOrder(context.Background(), order)
+	if errAbandon != nil {
+		t.Fatalf("AbandonOrder() unexpected error: %v", errAbandon)
+	}
+
+	saved := env.getOrder(t, order.Number, userID)
+	if saved.Status != models.OrderStatusInvalid {
+		t.Errorf("status = %v, want %v", saved.Status, models.OrderStatusInvalid)
+	}
+
+	env.checkBalance(t, userID, 0)
+}
@@ -0,0 +1,167 @@
+package healthservice
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/client"
+	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.HealthServiceInterface = &healthService{}
+
+const (
+	componentDatabase   = "database"
+	componentMigrations = "migrations"
+	componentAccrual    = "accrual"
+
+	checkTimeout = 2 * time.Second
+)
+
+var (
+	ErrMigrationDirty    = errors.New("last migration has failed, the schema is dirty")
+	ErrMigrationOutdated = errors.New("migrations aren't applied")
+	ErrAccrualPaused     = errors.New("accrual system is throttling, requests are paused")
+)
+
+type healthService struct {
+	cfg                      *config.Config
+	schemaRepository         repository.SchemaRepositoryInterface
+	accrualClient            client.AccrualClientInterface
+	expectedMigrationVersion uint
+	logger                   logger.Logger
+
+	// the result of the accrual check is cached, so probes don't send a request to the accrual system every time
+	accrualMutex     sync.Mutex
+	accrualCheckedAt time.Time
+	accrualErr       error
+}
+
+func New(
+	cfg *config.Config,
+	schemaRepository repository.SchemaRepositoryInterface,
+	accrualClient client.AccrualClientInterface,
+	expectedMigrationVersion uint,
+	logger logger.Logger,
+) *healthService {
+	return &healthService{
+		cfg:                      cfg,
+		schemaRepository:         schemaRepository,
+		accrualClient:            accrualClient,
+		expectedMigrationVersion: expectedMigrationVersion,
+		logger:                   logger,
+	}
+}
+
+// Ready checks the database, the schema and the accrual system.
+// Without the accrual system orders are only queued, so it makes the service degraded,
+// unless the config requires it for readiness.
+func (service *healthService) Ready(ctx context.Context) *models.Health {
+	health := &models.Health{
+		Status:     models.HealthStatusUp,
+		Components: make(map[string]models.ComponentHealth, 3),
+	}
+
+	errDatabase := service.check(ctx, service.schemaRepository.Ping)
+	health.Components[componentDatabase] = componentHealth(errDatabase, models.HealthStatusDown)
+
+	if errDatabase != nil {
+		health.Components[componentMigrations] = componentHealth(errDatabase, models.HealthStatusDown)
+	} else {
+		errMigrations := service.check(ctx, service.checkMigrations)
+		health.Components[componentMigrations] = componentHealth(errMigrations, models.HealthStatusDown)
+	}
+
+	health.Components[componentAccrual] = service.accrualHealth(ctx)
+
+	for name, component := range health.Components {
+		switch {
+		case component.Status == models.HealthStatusDown:
+			health.Status = models.HealthStatusDown
+		case component.Status == models.HealthStatusDegraded && health.Status == models.HealthStatusUp:
+			health.Status = models.HealthStatusDegraded
+		}
+
+		if component.Status != models.HealthStatusUp {
+			service.logger.Warnf("=== healthService: %v is %v: %v", name, component.Status, component.Error)
+		}
+	}
+
+	return health
+}
+
+// accrualHealth reuses the result of the last check within the interval. The throttling accrual system is reachable,
+// so the pause makes the service degraded even when the accrual system is required, and it isn't pinged during the pause.
+func (service *healthService) accrualHealth(ctx context.Context) models.ComponentHealth {
+	currentDatetime := utils.GetCurrentDatetimeUTC()
+
+	pausedUntil := service.accrualClient.PausedUntil()
+	if pausedUntil.After(currentDatetime) {
+		return componentHealth(fmt.Errorf("%w until %v", ErrAccrualPaused, pausedUntil), models.HealthStatusDegraded)
+	}
+
+	accrualFailureStatus := models.HealthStatusDegraded
+	if service.cfg.Health.AccrualRequired {
+		accrualFailureStatus = models.HealthStatusDown
+	}
+
+	service.accrualMutex.Lock()
+	defer service.accrualMutex.Unlock()
+
+	if service.accrualCheckedAt.IsZero() ||
+		currentDatetime.Sub(service.accrualCheckedAt) >= service.cfg.Health.AccrualCheckInterval {
+		errAccrual := service.check(ctx, service.accrualClient.Ping)
+		if ctx.Err() != nil {
+			// the probe has gone, its result says nothing about the accrual system
+			return componentHealth(errAccrual, accrualFailureStatus)
+		}
+
+		service.accrualErr = errAccrual
+		service.accrualCheckedAt = currentDatetime
+	}
+
+	return componentHealth(service.accrualErr, accrualFailureStatus)
+}
+
+func (service *healthService) checkMigrations(ctx context.Context) error {
+	version, dirty, errVersion := service.schemaRepository.GetMigrationVersion(ctx)
+	if errVersion != nil {
+		return errVersion
+	}
+
+	if dirty {
+		return fmt.Errorf("%w: version %v", ErrMigrationDirty, version)
+	}
+
+	if version < service.expectedMigrationVersion {
+		return fmt.Errorf("%w: version %v, expected %v", ErrMigrationOutdated, version, service.expectedMigrationVersion)
+	}
+
+	return nil
+}
+
+func (service *healthService) check(ctx context.Context, fn func(ctx context.Context) error) error {
+	ctxTimeout, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	return fn(ctxTimeout)
+}
+
+func componentHealth(err error, failureStatus string) models.ComponentHealth {
+	if err != nil {
+		return models.ComponentHealth{
+			Status: failureStatus,
+			Error:  err.Error(),
+		}
+	}
+
+	return models.ComponentHealth{Status: models.HealthStatusUp}
+}
@@ -0,0 +1,152 @@
+package idempotencyservice
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.IdempotencyServiceInterface = &idempotencyService{}
+
+var (
+	ErrKeyReused         = errors.New("idempotency key has already been used for another request")
+	ErrRequestInProgress = errors.New("request with this idempotency key is in progress")
+	ErrResponseLost      = errors.New("request with this idempotency key has been executed, but its response is lost")
+)
+
+type idempotencyService struct {
+	cfg                   *config.Config
+	idempotencyRepository repository.IdempotencyRepositoryInterface
+	logger                logger.Logger
+}
+
+func New(
+	cfg *config.Config,
+	idempotencyRepository repository.IdempotencyRepositoryInterface,
+	logger logger.Logger,
+) *idempotencyService {
+	return &idempotencyService{
+		cfg:                   cfg,
+		idempotencyRepository: idempotencyRepository,
+		logger:                logger,
+	}
+}
+
+// Begin reserves the key for the request. It returns nil when the request has to be executed,
+// or the stored result of the first request with the same key. A retry takes over the key
+// of the first request which hasn't finished within the lease and hasn't committed, e.g. because the service crashed.
+func (service *idempotencyService) Begin(ctx context.Context, request models.IdempotencyKey) (*models.IdempotencyKey, error) {
+	currentDatetime := utils.GetCurrentDatetimeUTC()
+
+	errDeleteExpired := service.idempotencyRepository.DeleteExpired(ctx, currentDatetime.Add(-service.cfg.Idempotency.TTL))
+	if errDeleteExpired != nil {
+		return nil, errDeleteExpired
+	}
+
+	request.CreatedAt = currentDatetime
+	request.LeaseUntil = currentDatetime.Add(service.cfg.Idempotency.Lease)
+
+	isInserted, errInsert := service.idempotencyRepository.Insert(ctx, &request)
+	if errInsert != nil {
+		return nil, errInsert
+	}
+
+	if isInserted {
+		return nil, nil
+	}
+
+	stored, errGet := service.idempotencyRepository.Get(ctx, request.UserID, request.Key)
+	if errGet != nil {
+		return nil, errGet
+	}
+
+	if stored == nil {
+		// the key has expired between the insert and the select
+		return service.Begin(ctx, request)
+	}
+
+	if stored.RequestMethod != request.RequestMethod ||
+		stored.RequestPath != request.RequestPath ||
+		stored.RequestHash != request.RequestHash {
+		return nil, ErrKeyReused
+	}
+
+	if stored.IsAbandoned(currentDatetime) && stored.IsCommitted() {
+		// the service has crashed after the commit, the request mustn't be executed again
+		return nil, ErrResponseLost
+	}
+
+	if stored.IsAbandoned(currentDatetime) {
+		isTakenOver, errTakeOver := service.idempotencyRepository.TakeOver(
+			ctx,
+			request.UserID,
+			request.Key,
+			currentDatetime,
+			request.LeaseUntil,
+		)
+		if errTakeOver != nil {
+			return nil, errTakeOver
+		}
+
+		if isTakenOver {
+			service.logger.Warnf("idempotency key %s of user %s was abandoned, it is taken over", request.Key, request.UserID)
+			return nil, nil
+		}
+
+		// another retry has taken the key over or the first request has just finished
+		return service.Begin(ctx, request)
+	}
+
+	if !stored.IsCompleted() {
+		return nil, ErrRequestInProgress
+	}
+
+	return stored, nil
+}
+
+func (service *idempotencyService) Complete(
+	ctx context.Context,
+	userID uuid.UUID,
+	key string,
+	statusCode int,
+	contentType string,
+	body []byte,
+) error {
+	return service.idempotencyRepository.Complete(ctx, userID, key, statusCode, contentType, body)
+}
+
+// Fail ends the request which has failed, e.g. with an internal error. The key is freed, so the request
+// can be repeated with it, only when the request hasn't committed anything; otherwise the failed response
+// is kept and replayed, because a repeated request could e.g. debit the points twice.
+func (service *idempotencyService) Fail(
+	ctx context.Context,
+	userID uuid.UUID,
+	key string,
+	statusCode int,
+	contentType string,
+	body []byte,
+) error {
+	stored, errGet := service.idempotencyRepository.Get(ctx, userID, key)
+	if errGet != nil {
+		return errGet
+	}
+
+	if stored == nil {
+		return nil
+	}
+
+	if stored.IsCommitted() {
+		service.logger.Warnf("idempotency key %s of user %s has failed after the commit, it is kept", key, userID)
+		return service.idempotencyRepository.Complete(ctx, userID, key, statusCode, contentType, body)
+	}
+
+	return service.idempotencyRepository.Delete(ctx, userID, key)
+}
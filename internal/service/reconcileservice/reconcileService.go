@@ -0,0 +1,95 @@
+package reconcileservice
+
+import (
+	"context"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.ReconcileServiceInterface = &reconcileService{}
+
+type reconcileService struct {
+	transactionRepository repository.TransactionRepositoryInterface
+	unitOfWork            repository.UnitOfWorkInterface
+	logger                logger.Logger
+}
+
+func New(
+	transactionRepository repository.TransactionRepositoryInterface,
+	unitOfWork repository.UnitOfWorkInterface,
+	logger logger.Logger,
+) *reconcileService {
+	return &reconcileService{
+		transactionRepository: transactionRepository,
+		unitOfWork:            unitOfWork,
+		logger:                logger,
+	}
+}
+
+// Handle returns users whose score total differs from the sum of the ledger.
+// With repair the score total of each of them is replaced by the sum of the ledger.
+func (service *reconcileService) Handle(ctx context.Context, repair bool) ([]models.LedgerBalance, error) {
+	balances, errBalances := service.transactionRepository.GetAllLedgerBalances(ctx)
+	if errBalances != nil {
+		return nil, errBalances
+	}
+
+	drifts := make([]models.LedgerBalance, 0)
+
+	for _, balance := range balances {
+		if balance.Drift().IsZero() {
+			continue
+		}
+
+		service.logger.Warnf("=== reconcile: user %v: score %v, ledger %v, drift %v",
+			balance.UserID, balance.ScoreTotal, balance.LedgerTotal, balance.Drift())
+
+		if repair {
+			repaired, errRepair := service.repair(ctx, balance)
+			if errRepair != nil {
+				return drifts, errRepair
+			}
+
+			balance = repaired
+		}
+
+		drifts = append(drifts, balance)
+	}
+
+	return drifts, nil
+}
+
+// repair locks the score of the user first: a concurrent accrual or withdrawal either has already
+// written its ledger row or waits for the end of the repair.
+func (service *reconcileService) repair(ctx context.Context, balance models.LedgerBalance) (models.LedgerBalance, error) {
+	errRepair := service.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		score, errScore := repositories.Score.GetScoreByUserIDForUpdate(ctx, balance.UserID)
+		if errScore != nil {
+			return errScore
+		}
+
+		ledgerTotal, errLedger := repositories.Transaction.GetLedgerBalance(ctx, balance.UserID)
+		if errLedger != nil {
+			return errLedger
+		}
+
+		if score != nil && score.Total == ledgerTotal {
+			return nil
+		}
+
+		balance.LedgerTotal = ledgerTotal
+
+		return repositories.Score.Set(ctx, balance.UserID, ledgerTotal)
+	})
+	if errRepair != nil {
+		service.logger.Errorf("---> ERROR: reconcile: failed repair score of user %v: %v", balance.UserID, errRepair)
+		return balance, errRepair
+	}
+
+	service.logger.Infof("=== reconcile: user %v: score is set to %v", balance.UserID, balance.LedgerTotal)
+
+	return balance, nil
+}
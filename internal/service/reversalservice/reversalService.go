@@ -0,0 +1,350 @@
+package reversalservice
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.ReversalServiceInterface = &reversalService{}
+
+// maxReasonLength - size of transactions.reason
+const maxReasonLength = 255
+
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrOrderNotFound       = errors.New("order not found")
+	ErrNotReversible       = errors.New("transaction can't be reversed")
+	ErrAlreadyReversed     = errors.New("transaction has already been reversed")
+	ErrOwnAccount          = errors.New("admin can't reverse transactions of the own account")
+	ErrWrongReason         = errors.New("reason of reversal is required and must be up to 255 characters")
+	ErrInsufficientFunds   = errors.New("insufficient funds")
+)
+
+type reversalService struct {
+	policy                string
+	orderRepository       repository.OrderRepositoryInterface
+	transactionRepository repository.TransactionRepositoryInterface
+	unitOfWork            repository.UnitOfWorkInterface
+	tierService           service.TierServiceInterface
+	eventPublisher        eventbus.PublisherInterface
+	logger                logger.Logger
+}
+
+func New(
+	policy string,
+	orderRepository repository.OrderRepositoryInterface,
+	transactionRepository repository.TransactionRepositoryInterface,
+	unitOfWork repository.UnitOfWorkInterface,
+	tierService service.TierServiceInterface,
+	eventPublisher eventbus.PublisherInterface,
+	logger logger.Logger,
+) *reversalService {
+	return &reversalService{
+		policy:                policy,
+		orderRepository:       orderRepository,
+		transactionRepository: transactionRepository,
+		unitOfWork:            unitOfWork,
+		tierService:           tierService,
+		eventPublisher:        eventPublisher,
+		logger:                logger,
+	}
+}
+
+// ReverseTransaction writes the compensating transaction of the transaction. operatorID is nil
+// when the reversal isn't made by an admin.
+func (service *reversalService) ReverseTransaction(
+	ctx context.Context,
+	transactionID uuid.UUID,
+	operatorID *uuid.UUID,
+	reason string,
+) (*models.ReversalResult, error) {
+	if !isValidReason(reason) {
+		return nil, ErrWrongReason
+	}
+
+	original, errOriginal := service.transactionRepository.GetByID(ctx, transactionID)
+	if errOriginal != nil {
+		return nil, errOriginal
+	}
+
+	if original == nil {
+		return nil, ErrTransactionNotFound
+	}
+
+	if _, isReversible := models.ReversalType(original.Type); !isReversible {
+		return nil, ErrNotReversible
+	}
+
+	if original.IsReversed() {
+		return nil, ErrAlreadyReversed
+	}
+
+	return service.reverse(ctx, original.UserID, []models.Transaction{*original}, operatorID, reason)
+}
+
+// ReverseOrder reverses every transaction of the order which hasn't been reversed yet:
+// the accrual with the bonuses of a cancelled order or the withdrawal for an order which was never placed.
+// Besides the admin API, the accrual worker calls it without an operator for the orders which become INVALID.
+func (service *reversalService) ReverseOrder(
+	ctx context.Context,
+	number string,
+	operatorID *uuid.UUID,
+	reason string,
+) (*models.ReversalResult, error) {
+	if !isValidReason(reason) {
+		return nil, ErrWrongReason
+	}
+
+	isExists, orderID, userID, errOrder := service.orderRepository.IsExists(ctx, number)
+	if errOrder != nil {
+		return nil, errOrder
+	}
+
+	if !isExists {
+		return nil, ErrOrderNotFound
+	}
+
+	transactions, errTransactions := service.transactionRepository.GetAllByOrderID(ctx, *orderID)
+	if errTransactions != nil {
+		return nil, errTransactions
+	}
+
+	originals := make([]models.Transaction, 0, len(transactions))
+	isReversed := false
+
+	for _, transaction := range transactions {
+		if _, isReversible := models.ReversalType(transaction.Type); !isReversible {
+			continue
+		}
+
+		if transaction.IsReversed() {
+			isReversed = true
+			continue
+		}
+
+		originals = append(originals, transaction)
+	}
+
+	if len(originals) == 0 {
+		if isReversed {
+			return nil, ErrAlreadyReversed
+		}
+
+		return nil, ErrNotReversible
+	}
+
+	return service.reverse(ctx, *userID, originals, operatorID, reason)
+}
+
+// reverse writes the compensating transactions in one transaction. Refunds come first,
+// so the debits of the same reversal can spend them.
+func (service *reversalService) reverse(
+	ctx context.Context,
+	userID uuid.UUID,
+	originals []models.Transaction,
+	operatorID *uuid.UUID,
+	reason string,
+) (*models.ReversalResult, error) {
+	if operatorID != nil && *operatorID == userID {
+		return nil, ErrOwnAccount
+	}
+
+	sort.SliceStable(originals, func(i, j int) bool {
+		return !models.IsDebitPointsType(originals[i].Type) && models.IsDebitPointsType(originals[j].Type)
+	})
+
+	result := &models.ReversalResult{Reversals: make([]models.Transaction, 0, len(originals))}
+
+	errReverse := service.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		result.Reversals = result.Reversals[:0]
+
+		for _, original := range originals {
+			reversal, current, errReversal := service.reverseOne(ctx, repositories, original, operatorID, reason)
+			if errReversal != nil {
+				return errReversal
+			}
+
+			result.Reversals = append(result.Reversals, *reversal)
+			result.Current = current
+		}
+
+		return nil
+	})
+	if errReverse != nil {
+		switch {
+		case errors.Is(errReverse, repository.ErrInsufficientFunds):
+			return nil, ErrInsufficientFunds
+		case errors.Is(errReverse, repository.ErrAlreadyReversed):
+			return nil, ErrAlreadyReversed
+		default:
+			return nil, errReverse
+		}
+	}
+
+	var change models.Points
+	isAccrualReversed := false
+
+	for index, reversal := range result.Reversals {
+		change = change.Add(models.SignedPoints(reversal.Points, reversal.Type))
+
+		metricType := "credit"
+		if reversal.Type == models.ReversalDebitType {
+			metricType = "debit"
+		}
+
+		metrics.PointsReversedTotal.WithLabelValues(metricType).Add(reversal.Points.Float64())
+
+		if originals[index].Type == models.IncreasePointsType {
+			isAccrualReversed = true
+		}
+
+		service.logger.Infof("=== reversalService: transaction %v of user %v is reversed by %v: %v",
+			originals[index].ID, userID, reversal.ID, reason)
+	}
+
+	if isAccrualReversed {
+		_, errRecalculate := service.tierService.Recalculate(ctx, userID)
+		if errRecalculate != nil {
+			service.logger.Errorf("---> ERROR: reversalService: user %v: recalculating tier: %v", userID, errRecalculate)
+		}
+	}
+
+	service.eventPublisher.Publish(userID, models.UserEventBalance, models.BalanceEventData{
+		Current: result.Current,
+		Change:  change,
+	})
+
+	return result, nil
+}
+
+// reverseOne changes the score and the lots of the user back and writes the compensating transaction
+// with its outbox event. It returns the reversal and the balance after it.
+func (service *reversalService) reverseOne(
+	ctx context.Context,
+	repositories *repository.Repositories,
+	original models.Transaction,
+	operatorID *uuid.UUID,
+	reason string,
+) (*models.Transaction, models.Points, error) {
+	var current models.Points
+	var errScore error
+
+	points := original.Points
+	isDebit := !models.IsDebitPointsType(original.Type)
+
+	if isDebit {
+		points, current, errScore = service.debit(ctx, repositories, original.UserID, points)
+	} else {
+		current, errScore = repositories.Score.Increase(ctx, original.UserID, points)
+	}
+
+	if errScore != nil {
+		return nil, 0, errScore
+	}
+
+	reversal, errInsert := repositories.Transaction.InsertReversal(ctx, original, points, reason, operatorID)
+	if errInsert != nil {
+		return nil, 0, errInsert
+	}
+
+	errLots := service.reverseLots(ctx, repositories, original, points)
+	if errLots != nil {
+		return nil, 0, errLots
+	}
+
+	event, errEvent := models.NewOutboxEvent(original.UserID, models.EventPointsReversed, models.ReversalEventData{
+		Transaction: original.ID,
+		Order:       original.OrderNumber,
+		Sum:         models.SignedPoints(points, reversal.Type),
+		Reason:      reason,
+	}, utils.GetCurrentDatetimeUTC())
+	if errEvent != nil {
+		return nil, 0, errEvent
+	}
+
+	errOutbox := repositories.Outbox.Insert(ctx, event)
+	if errOutbox != nil {
+		return nil, 0, errOutbox
+	}
+
+	return reversal, current, nil
+}
+
+// debit takes the points back per the negative balance policy and returns the debited points
+// and the balance after the debit.
+func (service *reversalService) debit(
+	ctx context.Context,
+	repositories *repository.Repositories,
+	userID uuid.UUID,
+	points models.Points,
+) (models.Points, models.Points, error) {
+	switch service.policy {
+	case models.ReversalPolicyDebt:
+		current, errIncrease := repositories.Score.Increase(ctx, userID, -points)
+
+		return points, current, errIncrease
+	case models.ReversalPolicyClamp:
+		// the increase by 0 locks the score, so the balance can't change before the decrease
+		total, errLock := repositories.Score.Increase(ctx, userID, 0)
+		if errLock != nil {
+			return 0, 0, errLock
+		}
+
+		if total < points {
+			points = total
+		}
+
+		if points <= 0 {
+			return 0, total, nil
+		}
+	}
+
+	current, errDecrease := repositories.Score.Decrease(ctx, userID, points)
+
+	return points, current, errDecrease
+}
+
+// reverseLots keeps the lots in step with the score: a reversed accrual or bonus is taken from the lot
+// of its order first, a refunded withdrawal gets a new lot. Adjustments aren't put into lots,
+// so a reversed debit adjustment gets no lot either.
+func (service *reversalService) reverseLots(
+	ctx context.Context,
+	repositories *repository.Repositories,
+	original models.Transaction,
+	points models.Points,
+) error {
+	switch original.Type {
+	case models.DecreasePointsType:
+		return repositories.PointLot.Insert(ctx, original.UserID, original.OrderID, points)
+	case models.AdjustmentDebitType:
+		return nil
+	case models.IncreasePointsType, models.BonusPointsType:
+		consumed, errConsume := repositories.PointLot.ConsumeOrder(ctx, original.UserID, original.OrderID, points)
+		if errConsume != nil {
+			return errConsume
+		}
+
+		points = points.Sub(consumed)
+	}
+
+	if points <= 0 {
+		return nil
+	}
+
+	return repositories.PointLot.Consume(ctx, original.UserID, points)
+}
+
+func isValidReason(reason string) bool {
+	return len(reason) > 0 && len([]rune(reason)) <= maxReasonLength
+}
@@ -0,0 +1,308 @@
+package reversalservice
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/repository/memoryrepository"
+	"github.com/lexizz/cumloys/internal/service/tierservice"
+)
+
+const (
+	accrualOrder    = "12345678903"
+	withdrawalOrder = "2377225624"
+	testReason      = "order is cancelled"
+)
+
+type testEnv struct {
+	service               *reversalService
+	unitOfWork            repository.UnitOfWorkInterface
+	orderRepository       repository.OrderRepositoryInterface
+	scoreRepository       repository.ScoreRepositoryInterface
+	transactionRepository repository.TransactionRepositoryInterface
+}
+
+func newTestEnv(policy string) *testEnv {
+	log := logger.Init()
+	storage := memoryrepository.NewStorage()
+	orderRepository := memoryrepository.NewOrderRepository(storage, log)
+	transactionRepository := memoryrepository.NewTransactionRepository(storage, log)
+	unitOfWork := memoryrepository.NewUnitOfWork(storage, log)
+	tierService := tierservice.New(models.DefaultTiers(), memoryrepository.NewTierRepository(storage, log), transactionRepository, log)
+
+	return &testEnv{
+		service: New(
+			policy,
+			orderRepository,
+			transactionRepository,
+			unitOfWork,
+			tierService,
+			eventbus.New(16, log),
+			log,
+		),
+		unitOfWork:            unitOfWork,
+		orderRepository:       orderRepository,
+		scoreRepository:       memoryrepository.NewScoreRepository(storage, log),
+		transactionRepository: transactionRepository,
+	}
+}
+
+// accrue credits the user with the points of the processed order the way the accrual worker does.
+func (env *testEnv) accrue(t *testing.T, userID uuid.UUID, number string, points models.Points) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	orderID, errOrder := env.orderRepository.Insert(ctx, number, userID, false)
+	if errOrder != nil {
+		t.Fatalf("inserting the order: %v", errOrder)
+	}
+
+	errAccrue := env.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		errUpdate := repositories.Order.Update(ctx, number, userID, models.OrderStatusProcessed, points)
+		if errUpdate != nil {
+			return errUpdate
+		}
+
+		_, errIncrease := repositories.Score.Increase(ctx, userID, points)
+		if errIncrease != nil {
+			return errIncrease
+		}
+
+		errInsert := repositories.Transaction.Insert(ctx, userID, *orderID, points, models.IncreasePointsType)
+		if errInsert != nil {
+			return errInsert
+		}
+
+		return repositories.PointLot.Insert(ctx, userID, *orderID, points)
+	})
+	if errAccrue != nil {
+		t.Fatalf("accruing the points: %v", errAccrue)
+	}
+}
+
+// withdraw spends the points for the order the way the withdrawal service does.
+func (env *testEnv) withdraw(t *testing.T, userID uuid.UUID, number string, points models.Points) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	orderID, errOrder := env.orderRepository.Insert(ctx, number, userID, true)
+	if errOrder != nil {
+		t.Fatalf("inserting the order: %v", errOrder)
+	}
+
+	errWithdraw := env.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		_, errDecrease := repositories.Score.Decrease(ctx, userID, points)
+		if errDecrease != nil {
+			return errDecrease
+		}
+
+		errInsert := repositories.Transaction.Insert(ctx, userID, *orderID, points, models.DecreasePointsType)
+		if errInsert != nil {
+			return errInsert
+		}
+
+		return repositories.PointLot.Consume(ctx, userID, points)
+	})
+	if errWithdraw != nil {
+		t.Fatalf("withdrawing the points: %v", errWithdraw)
+	}
+}
+
+func (env *testEnv) checkBalance(t *testing.T, userID uuid.UUID, want models.Points) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	score, errScore := env.scoreRepository.GetScoreByUserID(ctx, userID)
+	if errScore != nil {
+		t.Fatalf("getting the score: %v", errScore)
+	}
+
+	if score == nil || score.Total != want {
+		t.Errorf("balance = %v, want %v", score, want)
+	}
+
+	ledger, errLedger := env.transactionRepository.GetLedgerBalance(ctx, userID)
+	if errLedger != nil {
+		t.Fatalf("getting the ledger balance: %v", errLedger)
+	}
+
+	if ledger != want {
+		t.Errorf("ledger balance = %v, want %v", ledger, want)
+	}
+}
+
+func TestReverseOrder(t *testing.T) {
+	tests := []struct {
+		name          string
+		policy        string
+		withdraw      models.Points
+		number        string
+		wantErr       error
+		wantReversal  models.Points
+		wantType      int
+		wantBalance   models.Points
+		wantWithdrawn models.Points
+	}{
+		{
+			name:         "unspent accrual",
+			policy:       models.ReversalPolicyBlock,
+			number:       accrualOrder,
+			wantReversal: 50000,
+			wantType:     models.ReversalDebitType,
+			wantBalance:  0,
+		},
+		{
+			name:          "spent accrual is blocked",
+			policy:        models.ReversalPolicyBlock,
+			withdraw:      30000,
+			number:        accrualOrder,
+			wantErr:       ErrInsufficientFunds,
+			wantBalance:   20000,
+			wantWithdrawn: 30000,
+		},
+		{
+			name:          "spent accrual is clamped",
+			policy:        models.ReversalPolicyClamp,
+			withdraw:      30000,
+			number:        accrualOrder,
+			wantReversal:  20000,
+			wantType:      models.ReversalDebitType,
+			wantBalance:   0,
+			wantWithdrawn: 30000,
+		},
+		{
+			name:          "spent accrual makes a debt",
+			policy:        models.ReversalPolicyDebt,
+			withdraw:      30000,
+			number:        accrualOrder,
+			wantReversal:  50000,
+			wantType:      models.ReversalDebitType,
+			wantBalance:   -30000,
+			wantWithdrawn: 30000,
+		},
+		{
+			name:          "withdrawal is refunded",
+			policy:        models.ReversalPolicyBlock,
+			withdraw:      30000,
+			number:        withdrawalOrder,
+			wantReversal:  30000,
+			wantType:      models.ReversalCreditType,
+			wantBalance:   50000,
+			wantWithdrawn: 0,
+		},
+		{
+			name:        "unknown order",
+			policy:      models.ReversalPolicyBlock,
+			number:      "79927398713",
+			wantErr:     ErrOrderNotFound,
+			wantBalance: 50000,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			env := newTestEnv(tt.policy)
+			userID := uuid.New()
+
+			env.accrue(t, userID, accrualOrder, 50000)
+
+			if tt.withdraw > 0 {
+				env.withdraw(t, userID, withdrawalOrder, tt.withdraw)
+			}
+
+			result, err := env.service.ReverseOrder(ctx, tt.number, nil, testReason)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReverseOrder() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if len(result.Reversals) != 1 {
+					t.Fatalf("ReverseOrder() reversals = %v, want 1", len(result.Reversals))
+				}
+
+				reversal := result.Reversals[0]
+				if reversal.Points != tt.wantReversal || reversal.Type != tt.wantType {
+					t.Errorf("reversal = %v of type %v, want %v of type %v",
+						reversal.Points, reversal.Type, tt.wantReversal, tt.wantType)
+				}
+
+				if result.Current != tt.wantBalance {
+					t.Errorf("ReverseOrder() current = %v, want %v", result.Current, tt.wantBalance)
+				}
+			}
+
+			env.checkBalance(t, userID, tt.wantBalance)
+
+			withdrawn, errWithdrawn := env.transactionRepository.GetSumFundsWithdrawn(ctx, userID)
+			if errWithdrawn != nil {
+				t.Fatalf("getting the withdrawn sum: %v", errWithdrawn)
+			}
+
+			if withdrawn != tt.wantWithdrawn {
+				t.Errorf("withdrawn = %v, want %v", withdrawn, tt.wantWithdrawn)
+			}
+		})
+	}
+}
+
+func TestReverseOrderTwice(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(models.ReversalPolicyBlock)
+	userID := uuid.New()
+
+	env.accrue(t, userID, accrualOrder, 50000)
+
+	_, errFirst := env.service.ReverseOrder(ctx, accrualOrder, nil, testReason)
+	if errFirst != nil {
+		t.Fatalf("ReverseOrder() unexpected error: %v", errFirst)
+	}
+
+	_, errSecond := env.service.ReverseOrder(ctx, accrualOrder, nil, testReason)
+	if !errors.Is(errSecond, ErrAlreadyReversed) {
+		t.Fatalf("second ReverseOrder() error = %v, want %v", errSecond, ErrAlreadyReversed)
+	}
+
+	env.checkBalance(t, userID, 0)
+}
+
+func TestReverseOrderRefused(t *testing.T) {
+	userID := uuid.New()
+
+	tests := []struct {
+		name       string
+		operatorID *uuid.UUID
+		reason     string
+		wantErr    error
+	}{
+		{name: "empty reason", reason: "", wantErr: ErrWrongReason},
+		{name: "long reason", reason: strings.Repeat("a", maxReasonLength+1), wantErr: ErrWrongReason},
+		{name: "own account", operatorID: &userID, reason: testReason, wantErr: ErrOwnAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(models.ReversalPolicyBlock)
+
+			env.accrue(t, userID, accrualOrder, 50000)
+
+			_, err := env.service.ReverseOrder(context.Background(), accrualOrder, tt.operatorID, tt.reason)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("ReverseOrder() error = %v, want %v", err, tt.wantErr)
+			}
+
+			env.checkBalance(t, userID, 50000)
+		})
+	}
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/google/uuid"
 
@@ -17,6 +18,13 @@ type Services struct {
 	GettingPointsService      GettingPointsServiceInterface
 	WithdrawPointsService     WithdrawPointsServiceInterface
 	FindWithdrawPointsService FindWithdrawPointsServiceInterface
+	IdempotencyService        IdempotencyServiceInterface
+	SessionService            SessionServiceInterface
+	HealthService             HealthServiceInterface
+	WebhookService            WebhookServiceInterface
+	TierService               TierServiceInterface
+	AdminService              AdminServiceInterface
+	ReversalService           ReversalServiceInterface
 }
 
 type (
@@ -29,11 +37,11 @@ type (
 	}
 
 	CreateOrderServiceInterface interface {
-		Handle(ctx context.Context, numberOrder string, userID uuid.UUID) (*models.Order, error)
+		Handle(ctx context.Context, numberOrder string, userID uuid.UUID, isWithdrawal bool) (*models.Order, error)
 	}
 
 	FindOrderServiceInterface interface {
-		GetOrdersByUserID(ctx context.Context, userID uuid.UUID) ([]models.Order, error)
+		GetOrdersByUserID(ctx context.Context, userID uuid.UUID, filter models.ListFilter) ([]models.Order, *models.Cursor, error)
 		IsExistsOrder(ctx context.Context, numberOrder string) (bool, *uuid.UUID, *uuid.UUID)
 	}
 
@@ -43,13 +51,69 @@ type (
 
 	GettingPointsServiceInterface interface {
 		Handle(ctx context.Context, numberOrder string, userID uuid.UUID) error
+		ProcessOrder(ctx context.Context, order models.Order) (bool, error)
+		AbandonOrder(ctx context.Context, order models.Order) error
+	}
+
+	TierServiceInterface interface {
+		GetCurrentTier(ctx context.Context, userID uuid.UUID) (models.Tier, error)
+		Recalculate(ctx context.Context, userID uuid.UUID) (*models.UserTier, error)
+		GetProgress(ctx context.Context, userID uuid.UUID) (*models.TierProgress, error)
+	}
+
+	ExpirePointsServiceInterface interface {
+		ExpireByUserID(ctx context.Context, userID uuid.UUID, earnedBefore time.Time) (models.Points, error)
 	}
 
 	WithdrawPointsServiceInterface interface {
-		Handle(ctx context.Context, sumWithdrawPoints float32, orderID uuid.UUID, userID uuid.UUID) (bool, error)
+		Handle(ctx context.Context, sumWithdrawPoints models.Points, orderID uuid.UUID, numberOrder string, userID uuid.UUID) (bool, error)
 	}
 
 	FindWithdrawPointsServiceInterface interface {
-		Handle(ctx context.Context, userID uuid.UUID) []models.ScoreWithdraw
+		Handle(ctx context.Context, userID uuid.UUID, filter models.ListFilter) ([]models.ScoreWithdraw, *models.Cursor, error)
+	}
+
+	IdempotencyServiceInterface interface {
+		Begin(ctx context.Context, request models.IdempotencyKey) (*models.IdempotencyKey, error)
+		Complete(ctx context.Context, userID uuid.UUID, key string, statusCode int, contentType string, body []byte) error
+		Fail(ctx context.Context, userID uuid.UUID, key string, statusCode int, contentType string, body []byte) error
+	}
+
+	SessionServiceInterface interface {
+		Create(ctx context.Context, userID uuid.UUID) (*models.TokenPair, error)
+		Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+		Revoke(ctx context.Context, sessionID uuid.UUID) error
+		CheckActive(ctx context.Context, sessionID uuid.UUID) error
+	}
+
+	HealthServiceInterface interface {
+		Ready(ctx context.Context) *models.Health
+	}
+
+	WebhookServiceInterface interface {
+		Create(ctx context.Context, userID uuid.UUID, rawURL string) (*models.Webhook, error)
+		GetWebhooks(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error)
+		Delete(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) error
+		GetDeliveries(ctx context.Context, userID uuid.UUID, status string) ([]models.WebhookDelivery, error)
+		Redeliver(ctx context.Context, userID uuid.UUID, deliveryID uuid.UUID) error
+	}
+
+	AdminServiceInterface interface {
+		SearchUsers(ctx context.Context, login string, limit int) ([]models.User, error)
+		GetUser(ctx context.Context, userID uuid.UUID) (*models.User, error)
+		GetTransactions(ctx context.Context, userID uuid.UUID, filter models.ListFilter) ([]models.Transaction, *models.Cursor, error)
+		Block(ctx context.Context, userID uuid.UUID, operatorID uuid.UUID) error
+		Unblock(ctx context.Context, userID uuid.UUID, operatorID uuid.UUID) error
+		ResetPassword(ctx context.Context, userID uuid.UUID, password string) error
+		Adjust(ctx context.Context, userID uuid.UUID, operatorID uuid.UUID, points models.Points, reason string) (models.Points, error)
+	}
+
+	ReversalServiceInterface interface {
+		ReverseTransaction(ctx context.Context, transactionID uuid.UUID, operatorID *uuid.UUID, reason string) (*models.ReversalResult, error)
+		ReverseOrder(ctx context.Context, number string, operatorID *uuid.UUID, reason string) (*models.ReversalResult, error)
+	}
+
+	ReconcileServiceInterface interface {
+		Handle(ctx context.Context, repair bool) ([]models.LedgerBalance, error)
 	}
 )
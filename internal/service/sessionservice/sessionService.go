@@ -0,0 +1,199 @@
+package sessionservice
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.SessionServiceInterface = &sessionService{}
+
+const refreshTokenBytes = 32
+
+var (
+	ErrInvalidRefreshToken = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token has already been used, session is revoked")
+	ErrSessionRevoked      = errors.New("session is revoked")
+	ErrUserBlocked         = errors.New("account is blocked")
+)
+
+type sessionService struct {
+	cfg               *config.Config
+	sessionRepository repository.SessionRepositoryInterface
+	userRepository    repository.UserRepositoryInterface
+	jwt               *models.JWT
+	logger            logger.Logger
+}
+
+func New(
+	cfg *config.Config,
+	sessionRepository repository.SessionRepositoryInterface,
+	userRepository repository.UserRepositoryInterface,
+	jwt *models.JWT,
+	logger logger.Logger,
+) *sessionService {
+	return &sessionService{
+		cfg:               cfg,
+		sessionRepository: sessionRepository,
+		userRepository:    userRepository,
+		jwt:               jwt,
+		logger:            logger,
+	}
+}
+
+// Create starts a new session of the user and issues the first pair of tokens.
+// ErrUserBlocked is returned for a blocked user.
+func (service *sessionService) Create(ctx context.Context, userID uuid.UUID) (*models.TokenPair, error) {
+	user, errUser := service.getActiveUser(ctx, userID)
+	if errUser != nil {
+		return nil, errUser
+	}
+
+	sessionID, errInsert := service.sessionRepository.Insert(ctx, userID)
+	if errInsert != nil {
+		return nil, errInsert
+	}
+
+	return service.issueTokens(ctx, user, *sessionID)
+}
+
+// Refresh rotates the refresh token. A token which has already been rotated means that it was stolen,
+// so the whole session is revoked.
+func (service *sessionService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	storedToken, errGet := service.sessionRepository.GetRefreshToken(ctx, hashToken(refreshToken))
+	if errGet != nil {
+		return nil, errGet
+	}
+
+	if storedToken == nil || storedToken.ExpiresAt.Before(utils.GetCurrentDatetimeUTC()) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	session, errSession := service.sessionRepository.GetByID(ctx, storedToken.SessionID)
+	if errSession != nil {
+		return nil, errSession
+	}
+
+	if session == nil || session.IsRevoked() {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	isMarked, errMark := service.sessionRepository.MarkRefreshTokenUsed(ctx, storedToken.ID)
+	if errMark != nil {
+		return nil, errMark
+	}
+
+	if !isMarked {
+		service.logger.Warnf("=== sessionService: reuse of refresh token detected, session %v is revoked", session.ID)
+
+		errRevoke := service.sessionRepository.Revoke(ctx, session.ID)
+		if errRevoke != nil {
+			return nil, errRevoke
+		}
+
+		return nil, ErrRefreshTokenReused
+	}
+
+	// the role is read again, so a refreshed token has the current role of the user
+	user, errUser := service.getActiveUser(ctx, session.UserID)
+	if errUser != nil {
+		return nil, errUser
+	}
+
+	return service.issueTokens(ctx, user, session.ID)
+}
+
+func (service *sessionService) Revoke(ctx context.Context, sessionID uuid.UUID) error {
+	return service.sessionRepository.Revoke(ctx, sessionID)
+}
+
+// CheckActive returns ErrSessionRevoked when access tokens of the session mustn't be accepted any more.
+func (service *sessionService) CheckActive(ctx context.Context, sessionID uuid.UUID) error {
+	session, errSession := service.sessionRepository.GetByID(ctx, sessionID)
+	if errSession != nil {
+		return errSession
+	}
+
+	if session == nil || session.IsRevoked() {
+		return ErrSessionRevoked
+	}
+
+	return nil
+}
+
+func (service *sessionService) getActiveUser(ctx context.Context, userID uuid.UUID) (*models.User, error) {
+	user, errUser := service.userRepository.GetByID(ctx, userID)
+	if errUser != nil {
+		return nil, errUser
+	}
+
+	// the user has been deleted after the session was started
+	if user == nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if user.IsBlocked() {
+		return nil, ErrUserBlocked
+	}
+
+	return user, nil
+}
+
+func (service *sessionService) issueTokens(ctx context.Context, user *models.User, sessionID uuid.UUID) (*models.TokenPair, error) {
+	refreshToken, errGenerate := generateRefreshToken()
+	if errGenerate != nil {
+		return nil, errGenerate
+	}
+
+	expiresAt := utils.GetCurrentDatetimeUTC().Add(service.cfg.JWT.RefreshExpiryIn)
+
+	errInsert := service.sessionRepository.InsertRefreshToken(ctx, sessionID, hashToken(refreshToken), expiresAt)
+	if errInsert != nil {
+		return nil, errInsert
+	}
+
+	claims := map[string]interface{}{
+		"user_id": user.ID.String(),
+		"sid":     sessionID.String(),
+		"role":    user.Role,
+	}
+
+	accessToken, errToken := service.jwt.Encode(claims)
+	if errToken != nil {
+		return nil, errToken
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+	}, nil
+}
+
+func generateRefreshToken() (string, error) {
+	buf := make([]byte, refreshTokenBytes)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,152 @@
+package tierservice
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.TierServiceInterface = &tierService{}
+
+// tierService keeps the tier of the user as of the last accrual. Accruals only raise the points of the period,
+// so the tier is recalculated on every accrual; until the next one the user keeps the tier reached before,
+// but not longer than the period: a tier calculated before the start of the period is calculated again.
+type tierService struct {
+	tiers                 []models.Tier
+	tierRepository        repository.TierRepositoryInterface
+	transactionRepository repository.TransactionRepositoryInterface
+	logger                logger.Logger
+}
+
+// New gets tiers sorted by MinPoints, the first one starts from 0.
+func New(
+	tiers []models.Tier,
+	tierRepository repository.TierRepositoryInterface,
+	transactionRepository repository.TransactionRepositoryInterface,
+	logger logger.Logger,
+) *tierService {
+	return &tierService{
+		tiers:                 tiers,
+		tierRepository:        tierRepository,
+		transactionRepository: transactionRepository,
+		logger:                logger,
+	}
+}
+
+// GetCurrentTier returns the tier whose multiplier applies to the next accrual of the user.
+// The tier of a user who has never been calculated, e.g. registered before tiers, or who has had no accruals
+// during the whole period is calculated now.
+func (service *tierService) GetCurrentTier(ctx context.Context, userID uuid.UUID) (models.Tier, error) {
+	userTier, errGet := service.tierRepository.Get(ctx, userID)
+	if errGet != nil {
+		return models.Tier{}, errGet
+	}
+
+	if userTier == nil || userTier.UpdatedAt.Before(models.TierPeriodStart(utils.GetCurrentDatetimeUTC())) {
+		var errRecalculate error
+
+		userTier, errRecalculate = service.Recalculate(ctx, userID)
+		if errRecalculate != nil {
+			return models.Tier{}, errRecalculate
+		}
+	}
+
+	return service.tierOf(userTier), nil
+}
+
+func (service *tierService) Recalculate(ctx context.Context, userID uuid.UUID) (*models.UserTier, error) {
+	now := utils.GetCurrentDatetimeUTC()
+
+	accruedPoints, errSum := service.transactionRepository.GetSumAccrued(ctx, userID, models.TierPeriodStart(now))
+	if errSum != nil {
+		return nil, errSum
+	}
+
+	userTier := &models.UserTier{
+		UserID:        userID,
+		Tier:          models.TierFor(service.tiers, accruedPoints).Name,
+		AccruedPoints: accruedPoints,
+		UpdatedAt:     now,
+	}
+
+	errSave := service.tierRepository.Save(ctx, userTier)
+	if errSave != nil {
+		return nil, errSave
+	}
+
+	return userTier, nil
+}
+
+// GetProgress returns the current tier of the user and the points left to the next one
+// counted from the points accrued during the period by now.
+func (service *tierService) GetProgress(ctx context.Context, userID uuid.UUID) (*models.TierProgress, error) {
+	tier, errTier := service.GetCurrentTier(ctx, userID)
+	if errTier != nil {
+		return nil, errTier
+	}
+
+	accruedPoints, errSum := service.transactionRepository.GetSumAccrued(
+		ctx,
+		userID,
+		models.TierPeriodStart(utils.GetCurrentDatetimeUTC()),
+	)
+	if errSum != nil {
+		return nil, errSum
+	}
+
+	progress := &models.TierProgress{
+		Tier:            tier.Name,
+		Factor:          tier.Factor,
+		AccruedPoints:   accruedPoints,
+		ProgressPercent: 100,
+	}
+
+	next := service.nextTier(tier)
+	if next == nil {
+		return progress, nil
+	}
+
+	progress.NextTier = next.Name
+	progress.PointsToNextTier = next.MinPoints.Sub(accruedPoints)
+	progress.ProgressPercent = int(int64(accruedPoints.Sub(tier.MinPoints)) * 100 / int64(next.MinPoints.Sub(tier.MinPoints)))
+
+	if progress.PointsToNextTier < 0 {
+		progress.PointsToNextTier = 0
+	}
+
+	if progress.ProgressPercent < 0 {
+		progress.ProgressPercent = 0
+	} else if progress.ProgressPercent > 100 {
+		progress.ProgressPercent = 100
+	}
+
+	return progress, nil
+}
+
+// tierOf finds the saved tier among the configured ones; a tier removed from the configuration
+// is replaced by the one which the saved points reach.
+func (service *tierService) tierOf(userTier *models.UserTier) models.Tier {
+	for _, tier := range service.tiers {
+		if tier.Name == userTier.Tier {
+			return tier
+		}
+	}
+
+	return models.TierFor(service.tiers, userTier.AccruedPoints)
+}
+
+func (service *tierService) nextTier(current models.Tier) *models.Tier {
+	for index, tier := range service.tiers {
+		if tier.Name == current.Name && index+1 < len(service.tiers) {
+			return &service.tiers[index+1]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,163 @@
+package webhookservice
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+var _ service.WebhookServiceInterface = &webhookService{}
+
+const (
+	maxWebhooksPerUser = 10
+	secretBytes        = 32
+)
+
+var (
+	ErrWrongURL           = errors.New("url of webhook must be absolute http or https url")
+	ErrPrivateAddress     = errors.New("url of webhook must point to a public address")
+	ErrTooManyWebhooks    = errors.New("too many webhooks")
+	ErrWebhookNotFound    = errors.New("webhook not found")
+	ErrDeliveryNotFound   = errors.New("dead delivery not found")
+	ErrWrongDeliveryState = errors.New("unknown status of delivery")
+)
+
+type webhookService struct {
+	webhookRepository repository.WebhookRepositoryInterface
+	isPrivateAllowed  bool
+	logger            logger.Logger
+}
+
+// New - unless isPrivateAllowed, webhooks must point to public addresses, see webhookclient.NewHTTPClient.
+func New(webhookRepository repository.WebhookRepositoryInterface, isPrivateAllowed bool, logger logger.Logger) *webhookService {
+	return &webhookService{
+		webhookRepository: webhookRepository,
+		isPrivateAllowed:  isPrivateAllowed,
+		logger:            logger,
+	}
+}
+
+// Create registers the webhook with a new secret, the secret is returned only here.
+func (service *webhookService) Create(ctx context.Context, userID uuid.UUID, rawURL string) (*models.Webhook, error) {
+	parsedURL, errParse := url.Parse(rawURL)
+	if errParse != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) == 0 {
+		return nil, ErrWrongURL
+	}
+
+	if !service.isPrivateAllowed {
+		errAddress := checkPublicHost(ctx, parsedURL.Hostname())
+		if errAddress != nil {
+			return nil, errAddress
+		}
+	}
+
+	webhooks, errGet := service.webhookRepository.GetAllByUserID(ctx, userID)
+	if errGet != nil {
+		return nil, errGet
+	}
+
+	if len(webhooks) >= maxWebhooksPerUser {
+		return nil, ErrTooManyWebhooks
+	}
+
+	secret, errSecret := generateSecret()
+	if errSecret != nil {
+		return nil, errSecret
+	}
+
+	webhook := &models.Webhook{
+		UserID:    userID,
+		URL:       parsedURL.String(),
+		Secret:    secret,
+		CreatedAt: utils.GetCurrentDatetimeUTC(),
+	}
+
+	errInsert := service.webhookRepository.Insert(ctx, webhook)
+	if errInsert != nil {
+		return nil, errInsert
+	}
+
+	return webhook, nil
+}
+
+func (service *webhookService) GetWebhooks(ctx context.Context, userID uuid.UUID) ([]models.Webhook, error) {
+	return service.webhookRepository.GetAllByUserID(ctx, userID)
+}
+
+func (service *webhookService) Delete(ctx context.Context, userID uuid.UUID, webhookID uuid.UUID) error {
+	isDeleted, errDelete := service.webhookRepository.Delete(ctx, userID, webhookID)
+	if errDelete != nil {
+		return errDelete
+	}
+
+	if !isDeleted {
+		return ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// GetDeliveries returns deliveries with the status, empty status - any status.
+// DEAD deliveries are the dead letters: events which weren't accepted by the webhook.
+func (service *webhookService) GetDeliveries(ctx context.Context, userID uuid.UUID, status string) ([]models.WebhookDelivery, error) {
+	switch status {
+	case "", models.DeliveryStatusPending, models.DeliveryStatusDelivered, models.DeliveryStatusDead:
+	default:
+		return nil, ErrWrongDeliveryState
+	}
+
+	return service.webhookRepository.GetDeliveriesByUserID(ctx, userID, status)
+}
+
+func (service *webhookService) Redeliver(ctx context.Context, userID uuid.UUID, deliveryID uuid.UUID) error {
+	isQueued, errRedeliver := service.webhookRepository.Redeliver(ctx, userID, deliveryID)
+	if errRedeliver != nil {
+		return errRedeliver
+	}
+
+	if !isQueued {
+		return ErrDeliveryNotFound
+	}
+
+	return nil
+}
+
+// checkPublicHost rejects a host with any non-public address. It only gives an early answer to the user,
+// the addresses are checked again on every delivery.
+func checkPublicHost(ctx context.Context, host string) error {
+	addresses, errLookup := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if errLookup != nil {
+		return fmt.Errorf("%w: failed resolve %v", ErrWrongURL, host)
+	}
+
+	for _, address := range addresses {
+		if !utils.IsPublicIP(address.IP) {
+			return ErrPrivateAddress
+		}
+	}
+
+	return nil
+}
+
+func generateSecret() (string, error) {
+	buf := make([]byte, secretBytes)
+
+	_, err := rand.Read(buf)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
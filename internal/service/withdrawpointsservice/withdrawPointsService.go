@@ -7,58 +7,93 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/repository"
 )
 
-var ErrBalanceZero = errors.New("insufficient funds")
+var (
+	ErrBalanceZero = errors.New("insufficient funds")
+	ErrWrongSum    = errors.New("sum of withdrawal must be positive")
+)
 
 type withdrawPointsService struct {
-	orderRepository       repository.OrderRepositoryInterface
-	scoreRepository       repository.ScoreRepositoryInterface
-	transactionRepository repository.TransactionRepositoryInterface
-	logger                logger.Logger
+	unitOfWork     repository.UnitOfWorkInterface
+	eventPublisher eventbus.PublisherInterface
+	logger         logger.Logger
 }
 
 func New(
-	orderRepository repository.OrderRepositoryInterface,
-	scoreRepository repository.ScoreRepositoryInterface,
-	transactionRepository repository.TransactionRepositoryInterface,
+	unitOfWork repository.UnitOfWorkInterface,
+	eventPublisher eventbus.PublisherInterface,
 	logger logger.Logger,
 ) *withdrawPointsService {
 	return &withdrawPointsService{
-		orderRepository:       orderRepository,
-		scoreRepository:       scoreRepository,
-		transactionRepository: transactionRepository,
-		logger:                logger,
+		unitOfWork:     unitOfWork,
+		eventPublisher: eventPublisher,
+		logger:         logger,
 	}
 }
 
-func (service *withdrawPointsService) Handle(ctx context.Context, sumWithdrawPoints float32, orderID uuid.UUID, userID uuid.UUID) (bool, error) {
-	score, errGetPoints := service.scoreRepository.GetScoreByUserID(ctx, userID)
-	if errGetPoints != nil {
-		return false, errGetPoints
+// Handle debits the score, writes the ledger row, spends the oldest lots and writes the outbox event in one transaction.
+// The debit is conditional, so ErrBalanceZero is returned when a concurrent withdrawal has already spent the points.
+func (service *withdrawPointsService) Handle(
+	ctx context.Context,
+	sumWithdrawPoints models.Points,
+	orderID uuid.UUID,
+	numberOrder string,
+	userID uuid.UUID,
+) (bool, error) {
+	if sumWithdrawPoints <= 0 {
+		return false, ErrWrongSum
 	}
 
-	if score == nil {
-		return false, ErrBalanceZero
-	}
+	var currentBalance models.Points
 
-	if score.Total <= sumWithdrawPoints {
-		return false, ErrBalanceZero
-	}
+	errWithdraw := service.unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		var errDecrease error
 
-	resultScore := score.Total - sumWithdrawPoints
+		currentBalance, errDecrease = repositories.Score.Decrease(ctx, userID, sumWithdrawPoints)
+		if errDecrease != nil {
+			return errDecrease
+		}
 
-	errUpdateScore := service.scoreRepository.Update(ctx, userID, resultScore)
-	if errUpdateScore != nil {
-		return false, errUpdateScore
-	}
+		errInsert := repositories.Transaction.Insert(ctx, userID, orderID, sumWithdrawPoints, models.DecreasePointsType)
+		if errInsert != nil {
+			return errInsert
+		}
+
+		errConsume := repositories.PointLot.Consume(ctx, userID, sumWithdrawPoints)
+		if errConsume != nil {
+			return errConsume
+		}
 
-	errInsertTransaction := service.transactionRepository.Insert(ctx, userID, orderID, sumWithdrawPoints, models.DecreasePointsType)
-	if errInsertTransaction != nil {
-		return false, errInsertTransaction
+		event, errEvent := models.NewOutboxEvent(userID, models.EventPointsWithdrawn, models.WithdrawalEventData{
+			Order: numberOrder,
+			Sum:   sumWithdrawPoints,
+		}, utils.GetCurrentDatetimeUTC())
+		if errEvent != nil {
+			return errEvent
+		}
+
+		return repositories.Outbox.Insert(ctx, event)
+	})
+	if errWithdraw != nil {
+		if errors.Is(errWithdraw, repository.ErrInsufficientFunds) {
+			return false, ErrBalanceZero
+		}
+
+		return false, errWithdraw
 	}
 
+	metrics.PointsWithdrawnTotal.Add(sumWithdrawPoints.Float64())
+
+	service.eventPublisher.Publish(userID, models.UserEventBalance, models.BalanceEventData{
+		Current: currentBalance,
+		Change:  -sumWithdrawPoints,
+	})
+
 	return true, nil
 }
@@ -0,0 +1,141 @@
+package withdrawpointsservice
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/repository/memoryrepository"
+)
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name        string
+		accrued     models.Points
+		withdraw    models.Points
+		wantErr     error
+		wantBalance models.Points
+		wantLedger  models.Points
+	}{
+		{name: "part of the balance", accrued: 50050, withdraw: 20025, wantBalance: 30025, wantLedger: 30025},
+		{name: "whole balance", accrued: 50050, withdraw: 50050, wantBalance: 0, wantLedger: 0},
+		{name: "more than the balance", accrued: 50050, withdraw: 50051, wantErr: ErrBalanceZero, wantBalance: 50050, wantLedger: 50050},
+		{name: "empty balance", accrued: 0, withdraw: 1, wantErr: ErrBalanceZero, wantBalance: 0, wantLedger: 0},
+		{name: "zero sum", accrued: 50050, withdraw: 0, wantErr: ErrWrongSum, wantBalance: 50050, wantLedger: 50050},
+		{name: "negative sum", accrued: 50050, withdraw: -100, wantErr: ErrWrongSum, wantBalance: 50050, wantLedger: 50050},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			log := logger.Init()
+			storage := memoryrepository.NewStorage()
+			scoreRepository := memoryrepository.NewScoreRepository(storage, log)
+			transactionRepository := memoryrepository.NewTransactionRepository(storage, log)
+			orderRepository := memoryrepository.NewOrderRepository(storage, log)
+			unitOfWork := memoryrepository.NewUnitOfWork(storage, log)
+
+			userID := uuid.New()
+
+			if tt.accrued > 0 {
+				accrue(t, unitOfWork, orderRepository, userID, "12345678903", tt.accrued)
+			}
+
+			withdrawalOrderID, errOrder := orderRepository.Insert(ctx, "2377225624", userID, true)
+			if errOrder != nil {
+				t.Fatalf("inserting the order: %v", errOrder)
+			}
+
+			service := New(unitOfWork, eventbus.New(16, log), log)
+
+			isDone, err := service.Handle(ctx, tt.withdraw, *withdrawalOrderID, "2377225624", userID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Handle() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if isDone != (tt.wantErr == nil) {
+				t.Errorf("Handle() = %v, want %v", isDone, tt.wantErr == nil)
+			}
+
+			score, errScore := scoreRepository.GetScoreByUserID(ctx, userID)
+			if errScore != nil {
+				t.Fatalf("getting the score: %v", errScore)
+			}
+
+			var balance models.Points
+			if score != nil {
+				balance = score.Total
+			}
+
+			if balance != tt.wantBalance {
+				t.Errorf("balance = %v, want %v", balance, tt.wantBalance)
+			}
+
+			ledger, errLedger := transactionRepository.GetLedgerBalance(ctx, userID)
+			if errLedger != nil {
+				t.Fatalf("getting the ledger balance: %v", errLedger)
+			}
+
+			if ledger != tt.wantLedger {
+				t.Errorf("ledger balance = %v, want %v", ledger, tt.wantLedger)
+			}
+
+			withdrawn, errWithdrawn := transactionRepository.GetSumFundsWithdrawn(ctx, userID)
+			if errWithdrawn != nil {
+				t.Fatalf("getting the withdrawn sum: %v", errWithdrawn)
+			}
+
+			wantWithdrawn := tt.accrued - tt.wantBalance
+			if withdrawn != wantWithdrawn {
+				t.Errorf("withdrawn = %v, want %v", withdrawn, wantWithdrawn)
+			}
+		})
+	}
+}
+
+// accrue credits the user with the points of the processed order the way the accrual worker does.
+func accrue(
+	t *testing.T,
+	unitOfWork repository.UnitOfWorkInterface,
+	orderRepository repository.OrderRepositoryInterface,
+	userID uuid.UUID,
+	number string,
+	points models.Points,
+) {
+	t.Helper()
+
+	ctx := context.Background()
+
+	orderID, errOrder := orderRepository.Insert(ctx, number, userID, false)
+	if errOrder != nil {
+		t.Fatalf("inserting the order: %v", errOrder)
+	}
+
+	errAccrue := unitOfWork.Do(ctx, func(ctx context.Context, repositories *repository.Repositories) error {
+		errUpdate := repositories.Order.Update(ctx, number, userID, models.OrderStatusProcessed, points)
+		if errUpdate != nil {
+			return errUpdate
+		}
+
+		_, errIncrease := repositories.Score.Increase(ctx, userID, points)
+		if errIncrease != nil {
+			return errIncrease
+		}
+
+		errInsert := repositories.Transaction.Insert(ctx, userID, *orderID, points, models.IncreasePointsType)
+		if errInsert != nil {
+			return errInsert
+		}
+
+		return repositories.PointLot.Insert(ctx, userID, *orderID, points)
+	})
+	if errAccrue != nil {
+		t.Fatalf("accruing the points: %v", errAccrue)
+	}
+}
@@ -1,27 +1,33 @@
 package handler
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strings"
-	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
-	"github.com/go-chi/httprate"
 	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+	"github.com/lestrrat-go/jwx/jwt"
 
 	"github.com/lexizz/cumloys/internal/config"
 	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
 	"github.com/lexizz/cumloys/internal/pkg/logger"
 	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/sessionservice"
 	"github.com/lexizz/cumloys/internal/transport/http/handler/urlrouter"
 )
 
 type handler struct {
-	services *service.Services
-	config   *config.Config
-	logger   logger.Logger
-	jwt      *models.JWT
+	services    *service.Services
+	config      *config.Config
+	logger      logger.Logger
+	jwt         *models.JWT
+	eventBus    *eventbus.Bus
+	rateLimiter *RateLimiter
 }
 
 type Response struct {
@@ -30,12 +36,21 @@ type Response struct {
 	Token  string `json:"token,omitempty"`
 }
 
-func New(cfg *config.Config, logger logger.Logger, servicesList *service.Services, jwt *models.JWT) *handler {
+func New(
+	cfg *config.Config,
+	logger logger.Logger,
+	servicesList *service.Services,
+	jwt *models.JWT,
+	eventBus *eventbus.Bus,
+	rateLimiter *RateLimiter,
+) *handler {
 	return &handler{
-		services: servicesList,
-		config:   cfg,
-		logger:   logger,
-		jwt:      jwt,
+		services:    servicesList,
+		config:      cfg,
+		logger:      logger,
+		jwt:         jwt,
+		eventBus:    eventBus,
+		rateLimiter: rateLimiter,
 	}
 }
 
@@ -43,13 +58,14 @@ func (h *handler) Init() http.Handler {
 	router := chi.NewRouter()
 
 	router.Use(middleware.RequestID)
-	router.Use(middleware.Logger)
+	router.Use(RequestLogger(h.logger))
+	router.Use(Metrics)
 	router.Use(middleware.Recoverer)
 	router.Use(middleware.AllowContentType("application/json", "text/plain"))
 	router.Use(middleware.RealIP)
 	router.Use(middleware.CleanPath)
 	router.Use(middleware.Compress(9, "application/json", "text/plain"))
-	router.Use(httprate.LimitAll(60, 1*time.Minute))
+	router.Use(h.rateLimiter.Handler)
 
 	urlRoute := urlrouter.New(h.jwt, h.logger)
 
@@ -70,6 +86,10 @@ func (h *handler) Init() http.Handler {
 		r.NotFound(errFn)
 	})
 
+	router.Get("/.well-known/jwks.json", urlRoute.JWKSHandler())
+	router.Get("/healthz", urlRoute.LivenessHandler())
+	router.Get("/readyz", urlRoute.ReadinessHandler(h.services.HealthService, false))
+
 	router.Route("/api/user", func(routerAPI chi.Router) {
 		routerAPI.Group(func(r chi.Router) {
 			r.Route("/", func(r chi.Router) {
@@ -88,15 +108,18 @@ func (h *handler) Init() http.Handler {
 				r.NotFound(errFn)
 			})
 
-			r.Post("/login", urlRoute.AuthenticationHandler(h.services.FindUserService))
-			r.Post("/register", urlRoute.RegistrationHandler(h.services.CreateUserService))
+			r.Post("/login", urlRoute.AuthenticationHandler(h.services.FindUserService, h.services.SessionService))
+			r.Post("/register", urlRoute.RegistrationHandler(h.services.CreateUserService, h.services.SessionService))
+			r.Post("/token/refresh", urlRoute.RefreshTokenHandler(h.services.SessionService))
 		})
 
 		routerAPI.Group(func(r chi.Router) {
-			r.Use(Verifier(h.jwt.Auth))
+			r.Use(Verifier(h.jwt, h.services.SessionService))
 			r.Use(jwtauth.Authenticator)
 
-			r.Post("/orders", urlRoute.AddingOrdersHandler(
+			r.Post("/logout", urlRoute.LogoutHandler(h.services.SessionService))
+
+			r.With(Idempotency(h.services.IdempotencyService, h.logger)).Post("/orders", urlRoute.AddingOrdersHandler(
 				h.services.FindOrderService,
 				h.services.GettingPointsService,
 			))
@@ -104,7 +127,7 @@ func (h *handler) Init() http.Handler {
 
 			r.Route("/balance", func(routerBalance chi.Router) {
 				routerBalance.Get("/", urlRoute.GettingCurrentBalanceHandler(h.services.FindBalanceService))
-				routerBalance.Post("/withdraw", urlRoute.WithdrawPointsHandler(
+				routerBalance.With(Idempotency(h.services.IdempotencyService, h.logger)).Post("/withdraw", urlRoute.WithdrawPointsHandler(
 					h.services.CreateOrderService,
 					h.services.FindOrderService,
 					h.services.WithdrawPointsService,
@@ -112,16 +135,103 @@ func (h *handler) Init() http.Handler {
 			})
 
 			r.Get("/withdrawals", urlRoute.GettingInfoAboutBalanceHandler(h.services.FindWithdrawPointsService))
+			r.Get("/tier", urlRoute.GettingTierHandler(h.services.TierService))
+			r.Get("/events", urlRoute.EventsHandler(h.eventBus))
+
+			r.Route("/webhooks", func(routerWebhooks chi.Router) {
+				routerWebhooks.Post("/", urlRoute.CreatingWebhookHandler(h.services.WebhookService))
+				routerWebhooks.Get("/", urlRoute.GettingWebhooksHandler(h.services.WebhookService))
+				routerWebhooks.Delete("/{id}", urlRoute.DeletingWebhookHandler(h.services.WebhookService))
+				routerWebhooks.Get("/deliveries", urlRoute.GettingWebhookDeliveriesHandler(h.services.WebhookService))
+				routerWebhooks.Post("/deliveries/{id}/redeliver", urlRoute.RedeliveringWebhookHandler(h.services.WebhookService))
+			})
 		})
 	})
 
+	router.Route("/api/admin", func(routerAdmin chi.Router) {
+		routerAdmin.Use(Verifier(h.jwt, h.services.SessionService))
+		routerAdmin.Use(jwtauth.Authenticator)
+		routerAdmin.Use(RequireRole(models.RoleAdmin))
+
+		routerAdmin.Get("/users", urlRoute.SearchingUsersHandler(h.services.AdminService))
+
+		routerAdmin.Route("/users/{id}", func(routerUser chi.Router) {
+			routerUser.Get("/", urlRoute.GettingUserHandler(h.services.AdminService, h.services.FindBalanceService))
+			routerUser.Get("/orders", urlRoute.GettingUserOrdersHandler(h.services.AdminService, h.services.FindOrderService))
+			routerUser.Get("/transactions", urlRoute.GettingUserTransactionsHandler(h.services.AdminService))
+			routerUser.Post("/block", urlRoute.BlockingUserHandler(h.services.AdminService, true))
+			routerUser.Post("/unblock", urlRoute.BlockingUserHandler(h.services.AdminService, false))
+			routerUser.With(Idempotency(h.services.IdempotencyService, h.logger)).Post(
+				"/adjustments",
+				urlRoute.AdjustingBalanceHandler(h.services.AdminService),
+			)
+		})
+
+		routerAdmin.With(Idempotency(h.services.IdempotencyService, h.logger)).Post(
+			"/transactions/{id}/reversal",
+			urlRoute.ReversingTransactionHandler(h.services.ReversalService),
+		)
+		routerAdmin.With(Idempotency(h.services.IdempotencyService, h.logger)).Post(
+			"/orders/{number}/reversal",
+			urlRoute.ReversingOrderHandler(h.services.ReversalService),
+		)
+	})
+
 	return router
 }
 
-func Verifier(ja *jwtauth.JWTAuth) func(http.Handler) http.Handler {
+// RequireRole lets through only tokens with the role in the `role` claim, it goes after jwtauth.Authenticator.
+// Tokens issued before roles were introduced have no claim and get 403 Forbidden.
+func RequireRole(role string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			_, claims, err := jwtauth.FromContext(request.Context())
+			if err != nil || fmt.Sprint(claims["role"]) != role {
+				http.Error(writer, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(writer, request)
+		})
+	}
+}
+
+// Verifier verifies the token like jwtauth.Verify, but with any key of the key set, and also rejects
+// tokens of revoked sessions. The result is put into the context for jwtauth.Authenticator,
+// user_id of a valid token is added to the fields of the request log.
+func Verifier(auth *models.JWT, sessionService service.SessionServiceInterface) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
-		return jwtauth.Verify(ja, TokenFromHeader)(next)
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			token, err := auth.Verify(TokenFromHeader(request))
+			if err == nil {
+				err = checkSession(request.Context(), token, sessionService)
+			}
+
+			if err == nil {
+				if userID, isFound := token.Get("user_id"); isFound {
+					logger.AddContextField(request.Context(), "user_id", userID)
+				}
+			}
+
+			ctx := jwtauth.NewContext(request.Context(), token, err)
+
+			next.ServeHTTP(writer, request.WithContext(ctx))
+		})
+	}
+}
+
+func checkSession(ctx context.Context, token jwt.Token, sessionService service.SessionServiceInterface) error {
+	sessionFromToken, ok := token.Get("sid")
+	if !ok {
+		return sessionservice.ErrSessionRevoked
 	}
+
+	sessionID, errParse := uuid.Parse(fmt.Sprint(sessionFromToken))
+	if errParse != nil {
+		return errParse
+	}
+
+	return sessionService.CheckActive(ctx, sessionID)
 }
 
 func TokenFromHeader(r *http.Request) string {
@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/jwtauth/v5"
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/idempotencyservice"
+	"github.com/lexizz/cumloys/internal/transport/http/handler/urlrouter"
+)
+
+const (
+	IdempotencyKeyHeader       = "Idempotency-Key"
+	IdempotentReplayedHeader   = "Idempotent-Replayed"
+	maxIdempotencyKeyLength    = 255
+	idempotencyCompleteTimeout = 5 * time.Second
+)
+
+// Idempotency replays the stored response when a request is repeated with the same Idempotency-Key header.
+// Requests without the header are passed through. It must be used after jwtauth.Authenticator.
+func Idempotency(idempotencyService service.IdempotencyServiceInterface, logger logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			key := request.Header.Get(IdempotencyKeyHeader)
+			if key == "" {
+				next.ServeHTTP(writer, request)
+				return
+			}
+
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(writer, "idempotency key is too long", http.StatusBadRequest)
+				return
+			}
+
+			userID, errUserID := userIDFromContext(request.Context())
+			if errUserID != nil {
+				logger.Errorf("---> ERROR: Idempotency: getting user id from token: %v", errUserID)
+				http.Error(writer, urlrouter.ErrInternalServer.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			body, errBody := io.ReadAll(request.Body)
+			if errBody != nil {
+				logger.Errorf("---> ERROR: Idempotency: readAll body: %v\n", errBody)
+				http.Error(writer, urlrouter.ErrInternalServer.Error(), http.StatusInternalServerError)
+				return
+			}
+
+			request.Body = io.NopCloser(bytes.NewReader(body))
+
+			bodyHash := sha256.Sum256(body)
+
+			stored, errBegin := idempotencyService.Begin(request.Context(), models.IdempotencyKey{
+				UserID:        userID,
+				Key:           key,
+				RequestMethod: request.Method,
+				RequestPath:   request.URL.Path,
+				RequestHash:   hex.EncodeToString(bodyHash[:]),
+			})
+			if errBegin != nil {
+				switch {
+				case errors.Is(errBegin, idempotencyservice.ErrKeyReused):
+					http.Error(writer, errBegin.Error(), http.StatusUnprocessableEntity)
+				case errors.Is(errBegin, idempotencyservice.ErrRequestInProgress),
+					errors.Is(errBegin, idempotencyservice.ErrResponseLost):
+					http.Error(writer, errBegin.Error(), http.StatusConflict)
+				default:
+					logger.Errorf("---> ERROR: Idempotency: begin: %v", errBegin)
+					http.Error(writer, urlrouter.ErrInternalServer.Error(), http.StatusInternalServerError)
+				}
+
+				return
+			}
+
+			if stored != nil {
+				logger.Infof("=== Idempotency: replay response for key %v", key)
+
+				if stored.ContentType != "" {
+					writer.Header().Set("Content-Type", stored.ContentType)
+				}
+
+				writer.Header().Set(IdempotentReplayedHeader, "true")
+				writer.WriteHeader(stored.StatusCode)
+
+				_, errWrite := writer.Write(stored.ResponseBody)
+				if errWrite != nil {
+					logger.Errorf("---> ERROR: Idempotency: write: %v", errWrite)
+				}
+
+				return
+			}
+
+			recorder := &responseRecorder{ResponseWriter: writer, statusCode: http.StatusOK}
+
+			defer func() {
+				// the panicked request fails like one with 500, the panic goes on to middleware.Recoverer
+				if recovered := recover(); recovered != nil {
+					failIdempotencyKey(idempotencyService, userID, key, http.StatusInternalServerError, "text/plain; charset=utf-8",
+						[]byte(urlrouter.ErrInternalServer.Error()+"\n"), logger)
+					panic(recovered)
+				}
+			}()
+
+			// units of work of the request mark the key committed together with their changes
+			next.ServeHTTP(recorder, request.WithContext(models.WithIdempotencyKey(request.Context(), userID, key)))
+
+			if recorder.statusCode >= http.StatusInternalServerError {
+				failIdempotencyKey(idempotencyService, userID, key, recorder.statusCode, writer.Header().Get("Content-Type"),
+					recorder.body.Bytes(), logger)
+				return
+			}
+
+			// the response has to be saved even if the client has already gone
+			ctx, cancel := context.WithTimeout(context.Background(), idempotencyCompleteTimeout)
+			defer cancel()
+
+			errComplete := idempotencyService.Complete(
+				ctx,
+				userID,
+				key,
+				recorder.statusCode,
+				writer.Header().Get("Content-Type"),
+				recorder.body.Bytes(),
+			)
+			if errComplete != nil {
+				logger.Errorf("---> ERROR: Idempotency: complete key %v: %v", key, errComplete)
+			}
+		})
+	}
+}
+
+// failIdempotencyKey ends the failed request even if the client has already gone: the key is freed,
+// so the request can be retried, unless the request has committed its changes.
+func failIdempotencyKey(
+	idempotencyService service.IdempotencyServiceInterface,
+	userID uuid.UUID,
+	key string,
+	statusCode int,
+	contentType string,
+	body []byte,
+	logger logger.Logger,
+) {
+	ctx, cancel := context.WithTimeout(context.Background(), idempotencyCompleteTimeout)
+	defer cancel()
+
+	errFail := idempotencyService.Fail(ctx, userID, key, statusCode, contentType, body)
+	if errFail != nil {
+		logger.Errorf("---> ERROR: Idempotency: fail key %v: %v", key, errFail)
+	}
+}
+
+type responseRecorder struct {
+	http.ResponseWriter
+	body        bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+}
+
+func (recorder *responseRecorder) WriteHeader(statusCode int) {
+	if !recorder.wroteHeader {
+		recorder.statusCode = statusCode
+		recorder.wroteHeader = true
+	}
+
+	recorder.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (recorder *responseRecorder) Write(data []byte) (int, error) {
+	recorder.wroteHeader = true
+	recorder.body.Write(data)
+
+	return recorder.ResponseWriter.Write(data)
+}
+
+func userIDFromContext(ctx context.Context) (uuid.UUID, error) {
+	_, claims, errToken := jwtauth.FromContext(ctx)
+	if errToken != nil {
+		return uuid.UUID{}, errToken
+	}
+
+	userID, ok := claims["user_id"]
+	if !ok {
+		return uuid.UUID{}, errors.New("failed getting user id from token")
+	}
+
+	return uuid.Parse(fmt.Sprint(userID))
+}
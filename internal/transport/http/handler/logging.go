@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+)
+
+// RequestLogger logs every request in one structured record when the request is served.
+// It must be used after middleware.RequestID: the request id is put into the fields of the request,
+// so the records of handlers made with WithContext have it too, and user_id added by Verifier.
+func RequestLogger(log logger.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+			startedAt := time.Now()
+
+			ctx := logger.NewContext(request.Context())
+			logger.AddContextField(ctx, "request_id", middleware.GetReqID(ctx))
+
+			wrappedWriter := middleware.NewWrapResponseWriter(writer, request.ProtoMajor)
+			requestWithFields := request.WithContext(ctx)
+
+			next.ServeHTTP(wrappedWriter, requestWithFields)
+
+			status := wrappedWriter.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			// RemoteAddr is read after the handler, middleware.RealIP changes it in requestWithFields
+			log.WithContext(ctx).WithFields(logger.Fields{
+				"method":      request.Method,
+				"path":        request.URL.Path,
+				"status":      status,
+				"bytes":       wrappedWriter.BytesWritten(),
+				"duration_ms": time.Since(startedAt).Milliseconds(),
+				"remote":      requestWithFields.RemoteAddr,
+			}).Info("request served")
+		})
+	}
+}
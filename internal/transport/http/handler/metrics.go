@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/transport/http/handler/urlrouter"
+)
+
+const unmatchedRoute = "unmatched"
+
+// Metrics counts requests and their duration by the chi route pattern,
+// so the labels don't depend on order numbers and other values from the path.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		startedAt := time.Now()
+
+		wrappedWriter := middleware.NewWrapResponseWriter(writer, request.ProtoMajor)
+
+		next.ServeHTTP(wrappedWriter, request)
+
+		route := unmatchedRoute
+		if routeContext := chi.RouteContext(request.Context()); routeContext != nil && routeContext.RoutePattern() != "" {
+			route = routeContext.RoutePattern()
+		}
+
+		status := wrappedWriter.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		metrics.HTTPRequestsTotal.WithLabelValues(request.Method, route, strconv.Itoa(status)).Inc()
+		metrics.HTTPRequestDuration.WithLabelValues(request.Method, route).Observe(time.Since(startedAt).Seconds())
+	})
+}
+
+// InitAdmin returns the router of the admin listener, it mustn't be reachable from outside.
+// Health checks are duplicated here for orchestrators which probe the admin port.
+func (h *handler) InitAdmin() http.Handler {
+	router := chi.NewRouter()
+
+	router.Use(middleware.Recoverer)
+
+	urlRoute := urlrouter.New(h.jwt, h.logger)
+
+	router.Handle("/metrics", promhttp.Handler())
+	router.Get("/healthz", urlRoute.LivenessHandler())
+	router.Get("/readyz", urlRoute.ReadinessHandler(h.services.HealthService, true))
+
+	return router
+}
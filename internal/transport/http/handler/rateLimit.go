@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+
+	"github.com/lexizz/cumloys/internal/config"
+)
+
+// RateLimiter limits requests of every client address with a token bucket of cfg.RPS and cfg.Burst.
+// The buckets of clients idle longer than cfg.TTL are dropped.
+type RateLimiter struct {
+	mutex     sync.Mutex
+	cfg       config.LimiterConfig
+	clients   map[string]*rateLimitedClient
+	cleanedAt time.Time
+}
+
+type rateLimitedClient struct {
+	limiter    *rate.Limiter
+	lastSeenAt time.Time
+}
+
+func NewRateLimiter(cfg config.LimiterConfig) *RateLimiter {
+	return &RateLimiter{
+		cfg:       cfg,
+		clients:   make(map[string]*rateLimitedClient),
+		cleanedAt: time.Now(),
+	}
+}
+
+// SetConfig changes the limits, the buckets of the known clients get them at once.
+func (limiter *RateLimiter) SetConfig(cfg config.LimiterConfig) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	limiter.cfg = cfg
+
+	for _, client := range limiter.clients {
+		client.limiter.SetLimit(rate.Limit(cfg.RPS))
+		client.limiter.SetBurst(cfg.Burst)
+	}
+}
+
+// Handler must be used after middleware.RealIP, so clients behind a proxy get buckets of their own.
+func (limiter *RateLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		if !limiter.allow(clientAddress(request)) {
+			http.Error(writer, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(writer, request)
+	})
+}
+
+func (limiter *RateLimiter) allow(address string) bool {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(limiter.cleanedAt) > limiter.cfg.TTL {
+		for clientAddress, client := range limiter.clients {
+			if now.Sub(client.lastSeenAt) > limiter.cfg.TTL {
+				delete(limiter.clients, clientAddress)
+			}
+		}
+
+		limiter.cleanedAt = now
+	}
+
+	client, isFound := limiter.clients[address]
+	if !isFound {
+		client = &rateLimitedClient{limiter: rate.NewLimiter(rate.Limit(limiter.cfg.RPS), limiter.cfg.Burst)}
+		limiter.clients[address] = client
+	}
+
+	client.lastSeenAt = now
+
+	return client.limiter.AllowN(now, 1)
+}
+
+func clientAddress(request *http.Request) string {
+	host, _, errSplit := net.SplitHostPort(request.RemoteAddr)
+	if errSplit != nil {
+		return request.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,315 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/adminservice"
+)
+
+const defaultUsersSearchLimit = 50
+
+// adminUser - the user as support staff see it, without the password hash.
+type adminUser struct {
+	ID        uuid.UUID                  `json:"id"`
+	Login     string                     `json:"login"`
+	Role      string                     `json:"role"`
+	BlockedAt *time.Time                 `json:"blockedAt,omitempty"`
+	CreatedAt time.Time                  `json:"createdAt"`
+	Balance   *models.TotalScoreWithdraw `json:"balance,omitempty"`
+}
+
+type adjustmentRequest struct {
+	Points models.Points `json:"sum"`
+	Reason string        `json:"reason"`
+}
+
+type adjustmentResponse struct {
+	Current models.Points `json:"current"`
+}
+
+func newAdminUser(user models.User) adminUser {
+	return adminUser{
+		ID:        user.ID,
+		Login:     user.Login,
+		Role:      user.Role,
+		BlockedAt: user.BlockedAt,
+		CreatedAt: time.Unix(user.CreatedAt.Unix(), 0).UTC(),
+	}
+}
+
+// SearchingUsersHandler finds users by a part of the login: `?login=ivan&limit=10`.
+func (route *urlRouter) SearchingUsersHandler(adminService service.AdminServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		limit := defaultUsersSearchLimit
+
+		if value := request.URL.Query().Get("limit"); len(value) > 0 {
+			parsedLimit, errLimit := strconv.Atoi(value)
+			if errLimit != nil || parsedLimit < 1 || parsedLimit > models.MaxPageLimit {
+				http.Error(writer, ErrWrongLimit.Error(), http.StatusBadRequest)
+				return
+			}
+
+			limit = parsedLimit
+		}
+
+		users, errSearch := adminService.SearchUsers(request.Context(), request.URL.Query().Get("login"), limit)
+		if errSearch != nil {
+			log.Errorf("---> ERROR: SearchingUsersHandler: search: %v", errSearch)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(users) == 0 {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		usersForResponse := make([]adminUser, 0, len(users))
+		for _, user := range users {
+			usersForResponse = append(usersForResponse, newAdminUser(user))
+		}
+
+		route.sendJSON(writer, request, usersForResponse, http.StatusOK, "SearchingUsersHandler")
+	}
+}
+
+// GettingUserHandler returns the user with the current balance.
+func (route *urlRouter) GettingUserHandler(
+	adminService service.AdminServiceInterface,
+	findBalanceService service.FindBalanceServiceInterface,
+) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		user, ok := route.getPathUser(writer, request, adminService)
+		if !ok {
+			return
+		}
+
+		userForResponse := newAdminUser(*user)
+		userForResponse.Balance = findBalanceService.GetBalanceByUserID(request.Context(), user.ID)
+
+		route.sendJSON(writer, request, userForResponse, http.StatusOK, "GettingUserHandler")
+	}
+}
+
+// GettingUserOrdersHandler lists orders of any user with the same query parameters as `/api/user/orders`.
+func (route *urlRouter) GettingUserOrdersHandler(
+	adminService service.AdminServiceInterface,
+	findOrderService service.FindOrderServiceInterface,
+) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		user, ok := route.getPathUser(writer, request, adminService)
+		if !ok {
+			return
+		}
+
+		filter, errFilter := parseListFilter(request, models.OrderStatuses())
+		if errFilter != nil {
+			http.Error(writer, errFilter.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orders, next, errOrders := findOrderService.GetOrdersByUserID(request.Context(), user.ID, filter)
+		if errOrders != nil {
+			log.Errorf("---> ERROR: GettingUserOrdersHandler: getting orders: %v", errOrders)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(orders) == 0 {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		setNextPageHeaders(writer, request, next)
+		route.sendJSON(writer, request, orders, http.StatusOK, "GettingUserOrdersHandler")
+	}
+}
+
+// GettingUserTransactionsHandler lists the whole ledger of the user: accruals, withdrawals, expirations,
+// bonuses and adjustments.
+func (route *urlRouter) GettingUserTransactionsHandler(adminService service.AdminServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userID, ok := route.getPathUserID(writer, request)
+		if !ok {
+			return
+		}
+
+		filter, errFilter := parseListFilter(request, nil)
+		if errFilter != nil {
+			http.Error(writer, errFilter.Error(), http.StatusBadRequest)
+			return
+		}
+
+		transactions, next, errTransactions := adminService.GetTransactions(request.Context(), userID, filter)
+		if errTransactions != nil {
+			if errors.Is(errTransactions, adminservice.ErrUserNotFound) {
+				http.Error(writer, errTransactions.Error(), http.StatusNotFound)
+				return
+			}
+
+			log.Errorf("---> ERROR: GettingUserTransactionsHandler: getting transactions: %v", errTransactions)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(transactions) == 0 {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		setNextPageHeaders(writer, request, next)
+		route.sendJSON(writer, request, transactions, http.StatusOK, "GettingUserTransactionsHandler")
+	}
+}
+
+// BlockingUserHandler blocks the user when isBlocked is true and unblocks otherwise.
+func (route *urlRouter) BlockingUserHandler(adminService service.AdminServiceInterface, isBlocked bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		operatorUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: BlockingUserHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		userID, ok := route.getPathUserID(writer, request)
+		if !ok {
+			return
+		}
+
+		var errBlock error
+
+		if isBlocked {
+			errBlock = adminService.Block(request.Context(), userID, *operatorUUID)
+		} else {
+			errBlock = adminService.Unblock(request.Context(), userID, *operatorUUID)
+		}
+
+		if errBlock != nil {
+			switch {
+			case errors.Is(errBlock, adminservice.ErrUserNotFound):
+				http.Error(writer, errBlock.Error(), http.StatusNotFound)
+			case errors.Is(errBlock, adminservice.ErrOwnAccount):
+				http.Error(writer, errBlock.Error(), http.StatusConflict)
+			default:
+				log.Errorf("---> ERROR: BlockingUserHandler: %v", errBlock)
+				http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// AdjustingBalanceHandler credits a positive `sum` and debits a negative one, `reason` is required.
+func (route *urlRouter) AdjustingBalanceHandler(adminService service.AdminServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		operatorUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: AdjustingBalanceHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		userID, ok := route.getPathUserID(writer, request)
+		if !ok {
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			log.Errorf("---> ERROR: AdjustingBalanceHandler: readAll body: %v\n", err)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		adjustment := adjustmentRequest{}
+
+		errDecode := json.Unmarshal(body, &adjustment)
+		if errDecode != nil {
+			log.Errorf("---> ERROR: AdjustingBalanceHandler: json decode: %v\n", errDecode)
+			http.Error(writer, ErrRequireFieldsMissing.Error(), http.StatusBadRequest)
+			return
+		}
+
+		currentBalance, errAdjust := adminService.Adjust(request.Context(), userID, *operatorUUID, adjustment.Points, adjustment.Reason)
+		if errAdjust != nil {
+			switch {
+			case errors.Is(errAdjust, adminservice.ErrUserNotFound):
+				http.Error(writer, errAdjust.Error(), http.StatusNotFound)
+			case errors.Is(errAdjust, adminservice.ErrWrongAdjustment), errors.Is(errAdjust, adminservice.ErrWrongReason):
+				http.Error(writer, errAdjust.Error(), http.StatusUnprocessableEntity)
+			case errors.Is(errAdjust, adminservice.ErrOwnAccount):
+				http.Error(writer, errAdjust.Error(), http.StatusConflict)
+			case errors.Is(errAdjust, adminservice.ErrInsufficientFunds):
+				http.Error(writer, errAdjust.Error(), http.StatusPaymentRequired)
+			default:
+				log.Errorf("---> ERROR: AdjustingBalanceHandler: adjust: %v", errAdjust)
+				http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		route.sendJSON(writer, request, adjustmentResponse{Current: currentBalance}, http.StatusOK, "AdjustingBalanceHandler")
+	}
+}
+
+// getPathUserID answers 404 itself when `{id}` of the path isn't a uuid.
+func (route *urlRouter) getPathUserID(writer http.ResponseWriter, request *http.Request) (uuid.UUID, bool) {
+	userID, errParse := uuid.Parse(chi.URLParam(request, "id"))
+	if errParse != nil {
+		http.Error(writer, adminservice.ErrUserNotFound.Error(), http.StatusNotFound)
+		return uuid.Nil, false
+	}
+
+	return userID, true
+}
+
+func (route *urlRouter) getPathUser(
+	writer http.ResponseWriter,
+	request *http.Request,
+	adminService service.AdminServiceInterface,
+) (*models.User, bool) {
+	userID, ok := route.getPathUserID(writer, request)
+	if !ok {
+		return nil, false
+	}
+
+	user, errUser := adminService.GetUser(request.Context(), userID)
+	if errUser != nil {
+		if errors.Is(errUser, adminservice.ErrUserNotFound) {
+			http.Error(writer, errUser.Error(), http.StatusNotFound)
+			return nil, false
+		}
+
+		route.requestLogger(request).Errorf("---> ERROR: getting user %v: %v", userID, errUser)
+		http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+
+		return nil, false
+	}
+
+	return user, true
+}
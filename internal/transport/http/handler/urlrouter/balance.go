@@ -15,12 +15,11 @@ import (
 
 func (route *urlRouter) GettingCurrentBalanceHandler(findBalanceService service.FindBalanceServiceInterface) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected /api/user/balance` (GET) === ")
+		log := route.requestLogger(request)
 
 		userUUID, errUUID := route.getUserUUID(request)
 		if errUUID != nil {
-			route.logger.Errorf("---> ERROR: GettingCurrentBalanceHandler: getting user id from token: %v", errUUID)
+			log.Errorf("---> ERROR: GettingCurrentBalanceHandler: getting user id from token: %v", errUUID)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -29,14 +28,14 @@ func (route *urlRouter) GettingCurrentBalanceHandler(findBalanceService service.
 
 		totalBalanceForResponse, errEncode := json.Marshal(totalScoreWithdraw)
 		if errEncode != nil {
-			route.logger.Errorf("---> ERROR: GettingOrdersHandler: failed encode to json: %v", errEncode)
+			log.Errorf("---> ERROR: GettingOrdersHandler: failed encode to json: %v", errEncode)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		writer.Header().Set("Content-Type", "application/json")
 
-		sendResponse(writer, totalBalanceForResponse, http.StatusOK, route.logger)
+		sendResponse(writer, totalBalanceForResponse, http.StatusOK, log)
 	}
 }
 
@@ -46,29 +45,28 @@ func (route *urlRouter) WithdrawPointsHandler(
 	withdrawPointsService service.WithdrawPointsServiceInterface,
 ) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected /api/user/balance/withdraw` === ")
+		log := route.requestLogger(request)
 
 		userUUID, errUUID := route.getUserUUID(request)
 		if errUUID != nil {
-			route.logger.Errorf("---> ERROR: WithdrawPointsHandler: getting user id from token: %v", errUUID)
+			log.Errorf("---> ERROR: WithdrawPointsHandler: getting user id from token: %v", errUUID)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		body, err := io.ReadAll(request.Body)
 		if err != nil {
-			route.logger.Errorf("---> ERROR: WithdrawPointsHandler: readAll body: %v\n", err)
+			log.Errorf("---> ERROR: WithdrawPointsHandler: readAll body: %v\n", err)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		bodyInString := string(body)
-		route.logger.Info("=== Body: ", bodyInString)
+		log.Debugf("=== Body: %v", bodyInString)
 
 		errBodyEmpty := checkBodyOnEmpty(body)
 		if errBodyEmpty != nil {
-			route.logger.Errorf("---> ERROR: WithdrawPointsHandler: Body empty: %v\n", bodyInString)
+			log.Errorf("---> ERROR: WithdrawPointsHandler: Body empty: %v\n", bodyInString)
 			http.Error(writer, errBodyEmpty.Error(), http.StatusBadRequest)
 			return
 		}
@@ -77,14 +75,14 @@ func (route *urlRouter) WithdrawPointsHandler(
 
 		errDecode := json.Unmarshal(body, &withdrawPointData)
 		if errDecode != nil {
-			route.logger.Errorf("---> ERROR: WithdrawPointsHandler: json decode: %v\n", errDecode)
+			log.Errorf("---> ERROR: WithdrawPointsHandler: json decode: %v\n", errDecode)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		isValidNumber := utils.CheckNumberOrder(withdrawPointData.NumberOrder)
 		if !isValidNumber {
-			route.logger.Errorf("---> ERROR: WithdrawPointsHandler: failed number of order: %v", withdrawPointData.NumberOrder)
+			log.Errorf("---> ERROR: WithdrawPointsHandler: failed number of order: %v", withdrawPointData.NumberOrder)
 			http.Error(writer, "wrong number of order", http.StatusUnprocessableEntity)
 			return
 		}
@@ -93,11 +91,11 @@ func (route *urlRouter) WithdrawPointsHandler(
 
 		isExistsOrder, orderExistsID, _ := findOrderService.IsExistsOrder(request.Context(), withdrawPointData.NumberOrder)
 		if !isExistsOrder {
-			route.logger.Errorf("---> ERROR: WithdrawPointsHandler: order not found: %v", withdrawPointData.NumberOrder)
+			log.Errorf("---> ERROR: WithdrawPointsHandler: order not found: %v", withdrawPointData.NumberOrder)
 
-			order, errCreateOrder := createOrderService.Handle(request.Context(), withdrawPointData.NumberOrder, *userUUID)
+			order, errCreateOrder := createOrderService.Handle(request.Context(), withdrawPointData.NumberOrder, *userUUID, true)
 			if errCreateOrder != nil {
-				route.logger.Errorf("---> ERROR: WithdrawPointsHandler: error creating order: %v", withdrawPointData.NumberOrder)
+				log.Errorf("---> ERROR: WithdrawPointsHandler: error creating order: %v", withdrawPointData.NumberOrder)
 				http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 				return
 			}
@@ -107,20 +105,32 @@ func (route *urlRouter) WithdrawPointsHandler(
 			orderID = *orderExistsID
 		}
 
-		_, errWithdraw := withdrawPointsService.Handle(request.Context(), withdrawPointData.Points, orderID, *userUUID)
+		_, errWithdraw := withdrawPointsService.Handle(
+			request.Context(),
+			withdrawPointData.Points,
+			orderID,
+			withdrawPointData.NumberOrder,
+			*userUUID,
+		)
 		if errWithdraw != nil {
 			if errors.Is(errWithdraw, withdrawpointsservice.ErrBalanceZero) {
-				route.logger.Errorf("---> ERROR: balance has already zero: %v", errWithdraw)
+				log.Errorf("---> ERROR: balance has already zero: %v", errWithdraw)
 				http.Error(writer, errWithdraw.Error(), http.StatusPaymentRequired)
 				return
 			}
 
-			route.logger.Errorf("---> ERROR: handle withdraw points: %v", errWithdraw)
+			if errors.Is(errWithdraw, withdrawpointsservice.ErrWrongSum) {
+				log.Errorf("---> ERROR: wrong sum of withdrawal: %v", withdrawPointData.Points)
+				http.Error(writer, errWithdraw.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+
+			log.Errorf("---> ERROR: handle withdraw points: %v", errWithdraw)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		sendResponse(writer, []byte("ok"), http.StatusOK, route.logger)
+		sendResponse(writer, []byte("ok"), http.StatusOK, log)
 	}
 }
 
@@ -128,32 +138,45 @@ func (route *urlRouter) GettingInfoAboutBalanceHandler(
 	findWithdrawPointsService service.FindWithdrawPointsServiceInterface,
 ) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected /api/user/withdrawals` === ")
+		log := route.requestLogger(request)
 
 		userUUID, errUUID := route.getUserUUID(request)
 		if errUUID != nil {
-			route.logger.Errorf("---> ERROR: GettingInfoAboutBalanceHandler: getting user id from token: %v", errUUID)
+			log.Errorf("---> ERROR: GettingInfoAboutBalanceHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		filter, errFilter := parseListFilter(request, nil)
+		if errFilter != nil {
+			log.Errorf("---> ERROR: GettingInfoAboutBalanceHandler: wrong query: %v", errFilter)
+			http.Error(writer, errFilter.Error(), http.StatusBadRequest)
+			return
+		}
+
+		scoreWithdrawals, next, errWithdrawals := findWithdrawPointsService.Handle(request.Context(), *userUUID, filter)
+		if errWithdrawals != nil {
+			log.Errorf("---> ERROR: GettingInfoAboutBalanceHandler: getting withdrawals: %v", errWithdrawals)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		scoreWithdrawals := findWithdrawPointsService.Handle(request.Context(), *userUUID)
 		if len(scoreWithdrawals) == 0 {
-			route.logger.Error("---> ERROR: GettingInfoAboutBalanceHandler: withdrawals not found: %v")
+			log.Error("---> ERROR: GettingInfoAboutBalanceHandler: withdrawals not found: %v")
 			http.Error(writer, " withdrawals not found", http.StatusNoContent)
 			return
 		}
 
 		scoreWithdrawalsForResponse, errEncode := json.Marshal(scoreWithdrawals)
 		if errEncode != nil {
-			route.logger.Errorf("---> ERROR: GettingOrdersHandler: failed encode to json: %v", errEncode)
+			log.Errorf("---> ERROR: GettingOrdersHandler: failed encode to json: %v", errEncode)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		setNextPageHeaders(writer, request, next)
 		writer.Header().Set("Content-Type", "application/json")
 
-		sendResponse(writer, scoreWithdrawalsForResponse, http.StatusOK, route.logger)
+		sendResponse(writer, scoreWithdrawalsForResponse, http.StatusOK, log)
 	}
 }
@@ -0,0 +1,109 @@
+package urlrouter
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/eventbus"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	// reconnectDelay - how long EventSource of the browser waits before it reconnects
+	reconnectDelay = 3 * time.Second
+)
+
+// EventsHandler streams order and balance updates of the user as Server-Sent Events.
+// A reconnected stream sends Last-Event-ID and gets the events it missed; when they are lost,
+// the stream starts with `resync` and the client reloads the orders and the balance.
+func (route *urlRouter) EventsHandler(eventBus *eventbus.Bus) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: EventsHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		flusher, ok := writer.(http.Flusher)
+		if !ok {
+			log.Error("---> ERROR: EventsHandler: streaming isn't supported by the response writer")
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var lastEventID uint64
+
+		if header := request.Header.Get("Last-Event-ID"); len(header) > 0 {
+			value, errParse := strconv.ParseUint(header, 10, 64)
+			if errParse != nil {
+				http.Error(writer, "Last-Event-ID must be an id of an event", http.StatusBadRequest)
+				return
+			}
+
+			lastEventID = value
+		}
+
+		subscription, missed, isComplete := eventBus.Subscribe(*userUUID, lastEventID)
+		defer subscription.Close()
+
+		writer.Header().Set("Content-Type", "text/event-stream")
+		writer.Header().Set("Cache-Control", "no-cache")
+		writer.Header().Set("Connection", "keep-alive")
+		writer.Header().Set("X-Accel-Buffering", "no")
+		writer.WriteHeader(http.StatusOK)
+
+		_, errWrite := fmt.Fprintf(writer, "retry: %d\n\n", reconnectDelay.Milliseconds())
+
+		if errWrite == nil && !isComplete {
+			_, errWrite = fmt.Fprintf(writer, "event: %v\ndata: {}\n\n", models.UserEventResync)
+		}
+
+		for index := 0; errWrite == nil && index < len(missed); index++ {
+			errWrite = writeEvent(writer, missed[index])
+		}
+
+		if errWrite != nil {
+			log.Errorf("---> ERROR: EventsHandler: write: %v", errWrite)
+			return
+		}
+
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(heartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-request.Context().Done():
+				return
+			case <-heartbeat.C:
+				_, errWrite = fmt.Fprint(writer, ": heartbeat\n\n")
+			case event, isOpen := <-subscription.Events:
+				if !isOpen {
+					return
+				}
+
+				errWrite = writeEvent(writer, event)
+			}
+
+			if errWrite != nil {
+				log.Errorf("---> ERROR: EventsHandler: write: %v", errWrite)
+				return
+			}
+
+			flusher.Flush()
+		}
+	}
+}
+
+func writeEvent(writer http.ResponseWriter, event models.UserEvent) error {
+	_, err := fmt.Fprintf(writer, "id: %d\nevent: %v\ndata: %s\n\n", event.ID, event.Type, event.Data)
+
+	return err
+}
@@ -0,0 +1,59 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+// LivenessHandler answers while the process is able to serve requests, it doesn't check dependencies.
+func (route *urlRouter) LivenessHandler() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		body, errEncode := json.Marshal(models.ComponentHealth{Status: models.HealthStatusUp})
+		if errEncode != nil {
+			log.Errorf("---> ERROR: LivenessHandler: json encode: %v\n", errEncode)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		sendResponse(writer, body, http.StatusOK, log)
+	}
+}
+
+// ReadinessHandler returns 503 Service Unavailable when a required dependency is down,
+// a degraded service is still ready. The components and their errors are shown only when isDetailed is set,
+// i.e. on the admin listener; the public answer has the overall status only.
+func (route *urlRouter) ReadinessHandler(healthService service.HealthServiceInterface, isDetailed bool) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		health := healthService.Ready(request.Context())
+
+		var response interface{} = models.ComponentHealth{Status: health.Status}
+		if isDetailed {
+			response = health
+		}
+
+		body, errEncode := json.Marshal(response)
+		if errEncode != nil {
+			log.Errorf("---> ERROR: ReadinessHandler: json encode: %v\n", errEncode)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		statusCode := http.StatusOK
+		if !health.IsReady() {
+			statusCode = http.StatusServiceUnavailable
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		sendResponse(writer, body, statusCode, log)
+	}
+}
@@ -0,0 +1,25 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// JWKSHandler publishes the public keys, so other services can verify access tokens without the secret.
+func (route *urlRouter) JWKSHandler() http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		body, errEncode := json.Marshal(route.jwt.PublicKeys())
+		if errEncode != nil {
+			log.Errorf("---> ERROR: JWKSHandler: json encode: %v\n", errEncode)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/jwk-set+json")
+		writer.Header().Set("Cache-Control", "public, max-age=300")
+
+		sendResponse(writer, body, http.StatusOK, log)
+	}
+}
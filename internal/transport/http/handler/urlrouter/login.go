@@ -9,26 +9,26 @@ import (
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/service"
 	"github.com/lexizz/cumloys/internal/service/finduserservice"
+	"github.com/lexizz/cumloys/internal/service/sessionservice"
 )
 
-func (route *urlRouter) AuthenticationHandler(findUserService service.FindUserServiceInterface) http.HandlerFunc {
+func (route *urlRouter) AuthenticationHandler(
+	findUserService service.FindUserServiceInterface,
+	sessionService service.SessionServiceInterface,
+) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected `/login` === ")
+		log := route.requestLogger(request)
 
 		body, err := io.ReadAll(request.Body)
 		if err != nil {
-			route.logger.Errorf("---> ERROR: readAll body: %v\n", err)
+			log.Errorf("---> ERROR: readAll body: %v\n", err)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		bodyInString := string(body)
-		route.logger.Info("=== Body: ", bodyInString)
-
 		errBodyEmpty := checkBodyOnEmpty(body)
 		if errBodyEmpty != nil {
-			route.logger.Errorf("---> ERROR: Body empty: %v\n", bodyInString)
+			log.Error("---> ERROR: Body empty")
 			http.Error(writer, errBodyEmpty.Error(), http.StatusBadRequest)
 			return
 		}
@@ -37,14 +37,14 @@ func (route *urlRouter) AuthenticationHandler(findUserService service.FindUserSe
 
 		errDecode := json.Unmarshal(body, &authorizationData)
 		if errDecode != nil {
-			route.logger.Errorf("---> ERROR: json decode: %v\n", errDecode)
+			log.Errorf("---> ERROR: json decode: %v\n", errDecode)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		errRequireFields := checkLoginAndPasswordOnEmpty(authorizationData)
 		if errRequireFields != nil {
-			route.logger.Errorf("---> ERROR: empty fields login or pwd: %v\n", errRequireFields)
+			log.Errorf("---> ERROR: empty fields login or pwd: %v\n", errRequireFields)
 			http.Error(writer, errRequireFields.Error(), http.StatusBadRequest)
 			return
 		}
@@ -52,38 +52,41 @@ func (route *urlRouter) AuthenticationHandler(findUserService service.FindUserSe
 		userFromDB, errLogin := findUserService.GetUserByLogin(request.Context(), authorizationData.Login)
 		if errLogin != nil {
 			if errors.Is(errLogin, finduserservice.ErrInternal) {
-				route.logger.Errorf("---> ERROR: getting user: %v\n", errLogin)
+				log.Errorf("---> ERROR: getting user: %v\n", errLogin)
 				http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 				return
 			}
 
-			route.logger.Errorf("---> ERROR: user not found: %v\n", errLogin)
+			log.Errorf("---> ERROR: user not found: %v\n", errLogin)
 			http.Error(writer, ErrWrongLoginOrPassword.Error(), http.StatusUnauthorized)
 			return
 		}
 
 		isValidPwd := utils.IsValidPassword(authorizationData.Password, userFromDB.Password)
 		if !isValidPwd {
-			route.logger.Errorf("---> ERROR: wrong password: %v\n", authorizationData.Password)
+			log.Errorf("---> ERROR: wrong password of user: %v\n", authorizationData.Login)
 			http.Error(writer, ErrWrongLoginOrPassword.Error(), http.StatusUnauthorized)
 			return
 		}
 
-		claims := map[string]interface{}{
-			"user_id": userFromDB.ID.String(),
+		tokens, errSession := sessionService.Create(request.Context(), userFromDB.ID)
+		if errors.Is(errSession, sessionservice.ErrUserBlocked) {
+			log.Errorf("---> ERROR: user is blocked: %v\n", userFromDB.Login)
+			http.Error(writer, errSession.Error(), http.StatusForbidden)
+			return
 		}
 
-		resToken, errToken := route.jwt.Encode(claims)
-		if errToken != nil {
-			route.logger.Errorf("---> ERROR: encode token: %v\n", errToken.Error())
+		if errSession != nil {
+			log.Errorf("---> ERROR: create session: %v\n", errSession)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		route.logger.Infof("=== Token: %v\n", resToken)
+		log.WithField("login", authorizationData.Login).Info("=== Session is created ===")
 
-		writer.Header().Set("Authorization", resToken)
+		writer.Header().Set("Authorization", tokens.AccessToken)
+		writer.Header().Set(RefreshTokenHeader, tokens.RefreshToken)
 
-		sendResponse(writer, []byte("ok"), http.StatusOK, route.logger)
+		sendResponse(writer, []byte("ok"), http.StatusOK, log)
 	}
 }
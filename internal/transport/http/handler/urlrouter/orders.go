@@ -6,39 +6,53 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/lexizz/cumloys/internal/models"
 	"github.com/lexizz/cumloys/internal/pkg/utils"
 	"github.com/lexizz/cumloys/internal/service"
 )
 
 func (route *urlRouter) GettingOrdersHandler(findOrderService service.FindOrderServiceInterface) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected `/api/user/orders` (GET) === ")
+		log := route.requestLogger(request)
 
 		userUUID, errUUID := route.getUserUUID(request)
 		if errUUID != nil {
-			route.logger.Errorf("---> ERROR: GettingOrdersHandler: getting user id from token: %v", errUUID)
+			log.Errorf("---> ERROR: GettingOrdersHandler: getting user id from token: %v", errUUID)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		orders, err := findOrderService.GetOrdersByUserID(request.Context(), *userUUID)
+		filter, errFilter := parseListFilter(request, models.OrderStatuses())
+		if errFilter != nil {
+			log.Errorf("---> ERROR: GettingOrdersHandler: wrong query: %v", errFilter)
+			http.Error(writer, errFilter.Error(), http.StatusBadRequest)
+			return
+		}
+
+		orders, next, err := findOrderService.GetOrdersByUserID(request.Context(), *userUUID, filter)
 		if err != nil {
-			route.logger.Errorf("---> ERROR: GettingOrdersHandler: getting orders: %v", err)
+			log.Errorf("---> ERROR: GettingOrdersHandler: getting orders: %v", err)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		if len(orders) == 0 {
+			log.Info("=== GettingOrdersHandler: orders not found")
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
 		ordersForResponse, errEncode := json.Marshal(orders)
 		if errEncode != nil {
-			route.logger.Errorf("---> ERROR: GettingOrdersHandler: failed encode to json: %v", errEncode)
+			log.Errorf("---> ERROR: GettingOrdersHandler: failed encode to json: %v", errEncode)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
+		setNextPageHeaders(writer, request, next)
 		writer.Header().Set("Content-Type", "application/json")
 
-		sendResponse(writer, ordersForResponse, http.StatusOK, route.logger)
+		sendResponse(writer, ordersForResponse, http.StatusOK, log)
 	}
 }
 
@@ -47,22 +61,21 @@ func (route *urlRouter) AddingOrdersHandler(
 	gettingPointsService service.GettingPointsServiceInterface,
 ) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected `/api/user/orders` (POST) === ")
+		log := route.requestLogger(request)
 
 		body, err := io.ReadAll(request.Body)
 		if err != nil {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler: readAll body: %v\n", err)
+			log.Errorf("---> ERROR: AddingOrdersHandler: readAll body: %v\n", err)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		bodyInString := string(body)
-		route.logger.Info("=== Body, order:", bodyInString)
+		log.Debugf("=== Body, order: %v", bodyInString)
 
 		errBodyEmpty := checkBodyOnEmpty(body)
 		if errBodyEmpty != nil {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler: Body empty: %v\n", bodyInString)
+			log.Errorf("---> ERROR: AddingOrdersHandler: Body empty: %v\n", bodyInString)
 			http.Error(writer, errBodyEmpty.Error(), http.StatusBadRequest)
 			return
 		}
@@ -71,40 +84,40 @@ func (route *urlRouter) AddingOrdersHandler(
 
 		isValidNumber := utils.CheckNumberOrder(numberOrder)
 		if !isValidNumber {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler: failed number of order: %v", numberOrder)
+			log.Errorf("---> ERROR: AddingOrdersHandler: failed number of order: %v", numberOrder)
 			http.Error(writer, "wrong number of order", http.StatusUnprocessableEntity)
 			return
 		}
 
 		userUUID, errUUID := route.getUserUUID(request)
 		if errUUID != nil {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler: getting user id from token: %v", errUUID)
+			log.Errorf("---> ERROR: AddingOrdersHandler: getting user id from token: %v", errUUID)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		isExistsOrder, _, currentUserID := findOrderService.IsExistsOrder(request.Context(), numberOrder)
 		if isExistsOrder && userUUID.String() != currentUserID.String() {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler: order has already exists OTHER user: %v", numberOrder)
+			log.Errorf("---> ERROR: AddingOrdersHandler: order has already exists OTHER user: %v", numberOrder)
 			http.Error(writer, "this order has already exists", http.StatusConflict)
 			return
 		}
 
 		if isExistsOrder && userUUID.String() == currentUserID.String() {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler: order has already exists THIS user: %v", numberOrder)
+			log.Errorf("---> ERROR: AddingOrdersHandler: order has already exists THIS user: %v", numberOrder)
 
-			sendResponse(writer, []byte("this order has already exists"), http.StatusOK, route.logger)
+			sendResponse(writer, []byte("this order has already exists"), http.StatusOK, log)
 
 			return
 		}
 
 		errPoints := gettingPointsService.Handle(request.Context(), numberOrder, *userUUID)
 		if errPoints != nil {
-			route.logger.Errorf("---> ERROR: AddingOrdersHandler handle points: %v", errPoints)
+			log.Errorf("---> ERROR: AddingOrdersHandler handle points: %v", errPoints)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		sendResponse(writer, []byte("ok orders"), http.StatusAccepted, route.logger)
+		sendResponse(writer, []byte("ok orders"), http.StatusAccepted, log)
 	}
 }
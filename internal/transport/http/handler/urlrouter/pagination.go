@@ -0,0 +1,139 @@
+package urlrouter
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/models"
+)
+
+// NextCursorHeader - header of a list response with the cursor of the next page, it is absent on the last page.
+const NextCursorHeader = "X-Next-Cursor"
+
+const dateLayout = "2006-01-02"
+
+var (
+	ErrWrongLimit      = fmt.Errorf("limit must be from 1 to %d", models.MaxPageLimit)
+	ErrWrongSort       = errors.New("sort must be asc or desc")
+	ErrWrongDate       = errors.New("date must be in RFC 3339 format or YYYY-MM-DD")
+	ErrWrongStatus     = errors.New("unknown status of order")
+	ErrStatusForbidden = errors.New("filter by status isn't supported")
+)
+
+// parseListFilter reads `limit`, `cursor`, `status`, `from`, `to` and `sort` query parameters.
+// Without them the filter returns the whole list from the oldest to the newest row.
+func parseListFilter(request *http.Request, statuses []string) (models.ListFilter, error) {
+	query := request.URL.Query()
+
+	filter := models.ListFilter{
+		Sort: models.SortAsc,
+	}
+
+	if limit := query.Get("limit"); len(limit) > 0 {
+		value, errLimit := strconv.Atoi(limit)
+		if errLimit != nil || value < 1 || value > models.MaxPageLimit {
+			return filter, ErrWrongLimit
+		}
+
+		filter.Limit = value
+	}
+
+	if cursor := query.Get("cursor"); len(cursor) > 0 {
+		value, errCursor := models.DecodeCursor(cursor)
+		if errCursor != nil {
+			return filter, errCursor
+		}
+
+		filter.Cursor = value
+	}
+
+	for _, values := range query["status"] {
+		for _, status := range strings.Split(values, ",") {
+			status = strings.ToUpper(strings.TrimSpace(status))
+
+			if len(statuses) == 0 {
+				return filter, ErrStatusForbidden
+			}
+
+			if !containsStatus(statuses, status) {
+				return filter, fmt.Errorf("%w: %v", ErrWrongStatus, status)
+			}
+
+			filter.Statuses = append(filter.Statuses, status)
+		}
+	}
+
+	for name, bound := range map[string]**time.Time{"from": &filter.From, "to": &filter.To} {
+		value := query.Get(name)
+		if len(value) == 0 {
+			continue
+		}
+
+		date, errDate := parseDate(value)
+		if errDate != nil {
+			return filter, errDate
+		}
+
+		*bound = &date
+	}
+
+	if sort := query.Get("sort"); len(sort) > 0 {
+		sort = strings.ToLower(sort)
+		if sort != models.SortAsc && sort != models.SortDesc {
+			return filter, ErrWrongSort
+		}
+
+		filter.Sort = sort
+	}
+
+	return filter, nil
+}
+
+// setNextPageHeaders adds the RFC 8288 link to the next page with the same parameters and X-Next-Cursor.
+func setNextPageHeaders(writer http.ResponseWriter, request *http.Request, next *models.Cursor) {
+	if next == nil {
+		return
+	}
+
+	cursor := next.Encode()
+
+	query := request.URL.Query()
+	query.Set("cursor", cursor)
+
+	nextURL := url.URL{
+		Path:     request.URL.Path,
+		RawQuery: query.Encode(),
+	}
+
+	writer.Header().Set("Link", fmt.Sprintf("<%v>; rel=\"next\"", nextURL.String()))
+	writer.Header().Set(NextCursorHeader, cursor)
+}
+
+func parseDate(value string) (time.Time, error) {
+	date, errParse := time.Parse(time.RFC3339, value)
+	if errParse == nil {
+		return date.UTC(), nil
+	}
+
+	date, errParse = time.Parse(dateLayout, value)
+	if errParse == nil {
+		return date, nil
+	}
+
+	return time.Time{}, ErrWrongDate
+}
+
+func containsStatus(statuses []string, status string) bool {
+	for _, current := range statuses {
+		if current == status {
+			return true
+		}
+	}
+
+	return false
+}
@@ -10,24 +10,23 @@ import (
 	"github.com/lexizz/cumloys/internal/service/createuserservice"
 )
 
-func (route *urlRouter) RegistrationHandler(createUserSrv service.CreateUserServiceInterface) http.HandlerFunc {
+func (route *urlRouter) RegistrationHandler(
+	createUserSrv service.CreateUserServiceInterface,
+	sessionService service.SessionServiceInterface,
+) http.HandlerFunc {
 	return func(writer http.ResponseWriter, request *http.Request) {
-		route.logger.Infof("%v | %v | %v", request.Method, request.Host, request.URL.Path)
-		route.logger.Info("=== Part url was detected `/register` === ")
+		log := route.requestLogger(request)
 
 		body, err := io.ReadAll(request.Body)
 		if err != nil {
-			route.logger.Errorf("---> ERROR ReadAll body: %v\n", err)
+			log.Errorf("---> ERROR ReadAll body: %v\n", err)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		bodyInString := string(body)
-		route.logger.Info("=== Body: ", bodyInString)
-
 		errBodyEmpty := checkBodyOnEmpty(body)
 		if errBodyEmpty != nil {
-			route.logger.Errorf("---> ERROR: Body empty: %+v\n", bodyInString)
+			log.Error("---> ERROR: Body empty")
 			http.Error(writer, errBodyEmpty.Error(), http.StatusBadRequest)
 			return
 		}
@@ -36,14 +35,14 @@ func (route *urlRouter) RegistrationHandler(createUserSrv service.CreateUserServ
 
 		errDecode := json.Unmarshal(body, &authorizationData)
 		if errDecode != nil {
-			route.logger.Errorf("---> ERROR JSON: %+v; BODY:[%v]\n", errDecode, bodyInString)
+			log.Errorf("---> ERROR JSON: %+v\n", errDecode)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
 		errRequireFields := checkLoginAndPasswordOnEmpty(authorizationData)
 		if errRequireFields != nil {
-			route.logger.Errorf("---> ERROR: Empty fields: %+v\n", bodyInString)
+			log.Errorf("---> ERROR: Empty fields: %v\n", errRequireFields)
 			http.Error(writer, errRequireFields.Error(), http.StatusBadRequest)
 			return
 		}
@@ -55,26 +54,23 @@ func (route *urlRouter) RegistrationHandler(createUserSrv service.CreateUserServ
 				return
 			}
 
-			route.logger.Errorf("---> ERROR: createUserService: %v\n", errCUS.Error())
+			log.Errorf("---> ERROR: createUserService: %v\n", errCUS.Error())
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		claims := map[string]interface{}{
-			"user_id": lastInsertID.String(),
-		}
-
-		resToken, errToken := route.jwt.Encode(claims)
-		if errToken != nil {
-			route.logger.Error("---> ERROR token: " + errToken.Error())
+		tokens, errSession := sessionService.Create(request.Context(), *lastInsertID)
+		if errSession != nil {
+			log.Errorf("---> ERROR: create session: %v\n", errSession)
 			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		route.logger.Infof("=== Token: %v === \n", resToken)
+		log.WithField("login", authorizationData.Login).Info("=== Session is created ===")
 
-		writer.Header().Set("Authorization", resToken)
+		writer.Header().Set("Authorization", tokens.AccessToken)
+		writer.Header().Set(RefreshTokenHeader, tokens.RefreshToken)
 
-		sendResponse(writer, []byte("ok"), http.StatusOK, route.logger)
+		sendResponse(writer, []byte("ok"), http.StatusOK, log)
 	}
 }
@@ -0,0 +1,103 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/reversalservice"
+)
+
+type reversalRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReversingTransactionHandler writes the compensating transaction of `/api/admin/transactions/{id}`.
+func (route *urlRouter) ReversingTransactionHandler(reversalService service.ReversalServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		transactionID, errParse := uuid.Parse(chi.URLParam(request, "id"))
+		if errParse != nil {
+			http.Error(writer, reversalservice.ErrTransactionNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		route.reverse(writer, request, "ReversingTransactionHandler", func(operatorID *uuid.UUID, reason string) (*models.ReversalResult, error) {
+			return reversalService.ReverseTransaction(request.Context(), transactionID, operatorID, reason)
+		})
+	}
+}
+
+// ReversingOrderHandler reverses every transaction of the order `/api/admin/orders/{number}`
+// which hasn't been reversed yet.
+func (route *urlRouter) ReversingOrderHandler(reversalService service.ReversalServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		number := chi.URLParam(request, "number")
+
+		route.reverse(writer, request, "ReversingOrderHandler", func(operatorID *uuid.UUID, reason string) (*models.ReversalResult, error) {
+			return reversalService.ReverseOrder(request.Context(), number, operatorID, reason)
+		})
+	}
+}
+
+// reverse reads the reason of the reversal and answers with the result of reverseFunc.
+func (route *urlRouter) reverse(
+	writer http.ResponseWriter,
+	request *http.Request,
+	handlerName string,
+	reverseFunc func(operatorID *uuid.UUID, reason string) (*models.ReversalResult, error),
+) {
+	log := route.requestLogger(request)
+
+	operatorUUID, errUUID := route.getUserUUID(request)
+	if errUUID != nil {
+		log.Errorf("---> ERROR: %v: getting user id from token: %v", handlerName, errUUID)
+		http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		log.Errorf("---> ERROR: %v: readAll body: %v\n", handlerName, err)
+		http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	reversal := reversalRequest{}
+
+	errDecode := json.Unmarshal(body, &reversal)
+	if errDecode != nil {
+		log.Errorf("---> ERROR: %v: json decode: %v\n", handlerName, errDecode)
+		http.Error(writer, ErrRequireFieldsMissing.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, errReverse := reverseFunc(operatorUUID, reversal.Reason)
+	if errReverse != nil {
+		switch {
+		case errors.Is(errReverse, reversalservice.ErrTransactionNotFound),
+			errors.Is(errReverse, reversalservice.ErrOrderNotFound):
+			http.Error(writer, errReverse.Error(), http.StatusNotFound)
+		case errors.Is(errReverse, reversalservice.ErrAlreadyReversed),
+			errors.Is(errReverse, reversalservice.ErrOwnAccount):
+			http.Error(writer, errReverse.Error(), http.StatusConflict)
+		case errors.Is(errReverse, reversalservice.ErrNotReversible),
+			errors.Is(errReverse, reversalservice.ErrWrongReason):
+			http.Error(writer, errReverse.Error(), http.StatusUnprocessableEntity)
+		case errors.Is(errReverse, reversalservice.ErrInsufficientFunds):
+			http.Error(writer, errReverse.Error(), http.StatusPaymentRequired)
+		default:
+			log.Errorf("---> ERROR: %v: reverse: %v", handlerName, errReverse)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+		}
+
+		return
+	}
+
+	route.sendJSON(writer, request, result, http.StatusOK, handlerName)
+}
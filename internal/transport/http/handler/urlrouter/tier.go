@@ -0,0 +1,40 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/lexizz/cumloys/internal/service"
+)
+
+// GettingTierHandler returns the loyalty tier of the user and the progress to the next one.
+func (route *urlRouter) GettingTierHandler(tierService service.TierServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: GettingTierHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		progress, errProgress := tierService.GetProgress(request.Context(), *userUUID)
+		if errProgress != nil {
+			log.Errorf("---> ERROR: GettingTierHandler: getting tier: %v", errProgress)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		response, errEncode := json.Marshal(progress)
+		if errEncode != nil {
+			log.Errorf("---> ERROR: GettingTierHandler: failed encode to json: %v", errEncode)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Content-Type", "application/json")
+
+		sendResponse(writer, response, http.StatusOK, log)
+	}
+}
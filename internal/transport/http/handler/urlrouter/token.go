@@ -0,0 +1,99 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/sessionservice"
+)
+
+type refreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func (route *urlRouter) RefreshTokenHandler(sessionService service.SessionServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			log.Errorf("---> ERROR: RefreshTokenHandler: readAll body: %v\n", err)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		errBodyEmpty := checkBodyOnEmpty(body)
+		if errBodyEmpty != nil {
+			log.Error("---> ERROR: RefreshTokenHandler: Body empty")
+			http.Error(writer, errBodyEmpty.Error(), http.StatusBadRequest)
+			return
+		}
+
+		refreshData := refreshTokenRequest{}
+
+		errDecode := json.Unmarshal(body, &refreshData)
+		if errDecode != nil || len(refreshData.RefreshToken) == 0 {
+			log.Errorf("---> ERROR: RefreshTokenHandler: json decode: %v\n", errDecode)
+			http.Error(writer, ErrRequireFieldsMissing.Error(), http.StatusBadRequest)
+			return
+		}
+
+		tokens, errRefresh := sessionService.Refresh(request.Context(), refreshData.RefreshToken)
+		if errRefresh != nil {
+			if errors.Is(errRefresh, sessionservice.ErrInvalidRefreshToken) ||
+				errors.Is(errRefresh, sessionservice.ErrRefreshTokenReused) {
+				log.Errorf("---> ERROR: RefreshTokenHandler: %v", errRefresh)
+				http.Error(writer, errRefresh.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			if errors.Is(errRefresh, sessionservice.ErrUserBlocked) {
+				log.Errorf("---> ERROR: RefreshTokenHandler: %v", errRefresh)
+				http.Error(writer, errRefresh.Error(), http.StatusForbidden)
+				return
+			}
+
+			log.Errorf("---> ERROR: RefreshTokenHandler: refresh: %v", errRefresh)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		tokensForResponse, errEncode := json.Marshal(tokens)
+		if errEncode != nil {
+			log.Errorf("---> ERROR: RefreshTokenHandler: failed encode to json: %v", errEncode)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.Header().Set("Authorization", tokens.AccessToken)
+		writer.Header().Set(RefreshTokenHeader, tokens.RefreshToken)
+		writer.Header().Set("Content-Type", "application/json")
+
+		sendResponse(writer, tokensForResponse, http.StatusOK, log)
+	}
+}
+
+func (route *urlRouter) LogoutHandler(sessionService service.SessionServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		sessionUUID, errUUID := route.getSessionUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: LogoutHandler: getting session id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		errRevoke := sessionService.Revoke(request.Context(), *sessionUUID)
+		if errRevoke != nil {
+			log.Errorf("---> ERROR: LogoutHandler: revoke session: %v", errRevoke)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sendResponse(writer, []byte("ok"), http.StatusOK, log)
+	}
+}
@@ -22,10 +22,13 @@ type authorization struct {
 }
 
 type withdrawPoint struct {
-	NumberOrder string  `json:"order,omitempty"`
-	Points      float32 `json:"sum,omitempty"`
+	NumberOrder string        `json:"order,omitempty"`
+	Points      models.Points `json:"sum,omitempty"`
 }
 
+// RefreshTokenHeader - header of the response with a new refresh token after login, registration and refresh.
+const RefreshTokenHeader = "X-Refresh-Token"
+
 var (
 	ErrRequireFieldsMissing = errors.New("required fields are missing")
 	ErrInternalServer       = errors.New("internal server error")
@@ -39,6 +42,11 @@ func New(jwt *models.JWT, logger logger.Logger) *urlRouter {
 	}
 }
 
+// requestLogger returns the logger with the fields of the request, e.g. request_id and user_id.
+func (route *urlRouter) requestLogger(request *http.Request) logger.Logger {
+	return route.logger.WithContext(request.Context())
+}
+
 func checkBodyOnEmpty(body []byte) error {
 	if len(body) == 0 {
 		return ErrRequireFieldsMissing
@@ -56,28 +64,36 @@ func checkLoginAndPasswordOnEmpty(authorizationData authorization) error {
 }
 
 func (route *urlRouter) getUserUUID(request *http.Request) (*uuid.UUID, error) {
+	return route.getUUIDFromToken(request, "user_id")
+}
+
+func (route *urlRouter) getSessionUUID(request *http.Request) (*uuid.UUID, error) {
+	return route.getUUIDFromToken(request, "sid")
+}
+
+func (route *urlRouter) getUUIDFromToken(request *http.Request, claim string) (*uuid.UUID, error) {
 	token := request.Header.Get("Authorization")
 
 	tokenJWT, errParse := route.jwt.Parse(token)
 	if errParse != nil {
-		route.logger.Errorf("---> ERROR parse token: %v; Token: %v\n", errParse, token)
+		route.requestLogger(request).Errorf("---> ERROR parse token: %v\n", errParse)
 		return nil, errParse
 	}
 
-	userFromToken, ok := tokenJWT.Get("user_id")
-	userID := fmt.Sprint(userFromToken)
-	if !ok || len(userID) == 0 {
-		route.logger.Error("---> ERROR: failed getting user id from jwt")
-		return nil, errors.New("failed getting user id from token")
+	valueFromToken, ok := tokenJWT.Get(claim)
+	value := fmt.Sprint(valueFromToken)
+	if !ok || len(value) == 0 {
+		route.requestLogger(request).Errorf("---> ERROR: failed getting %v from jwt", claim)
+		return nil, errors.New("failed getting " + claim + " from token")
 	}
 
-	userUUID, errParseUUID := uuid.Parse(userID)
+	valueUUID, errParseUUID := uuid.Parse(value)
 	if errParseUUID != nil {
-		route.logger.Errorf("---> ERROR parse user id to uuid: %v", errParse)
+		route.requestLogger(request).Errorf("---> ERROR parse %v to uuid: %v", claim, errParseUUID)
 		return nil, errParseUUID
 	}
 
-	return &userUUID, nil
+	return &valueUUID, nil
 }
 
 func sendResponse(writer http.ResponseWriter, message []byte, statusCode int, logger logger.Logger) {
@@ -0,0 +1,209 @@
+package urlrouter
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/service/webhookservice"
+)
+
+type webhookRequest struct {
+	URL string `json:"url"`
+}
+
+func (route *urlRouter) CreatingWebhookHandler(webhookService service.WebhookServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: CreatingWebhookHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		body, err := io.ReadAll(request.Body)
+		if err != nil {
+			log.Errorf("---> ERROR: CreatingWebhookHandler: readAll body: %v\n", err)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		webhookData := webhookRequest{}
+
+		errDecode := json.Unmarshal(body, &webhookData)
+		if errDecode != nil || len(webhookData.URL) == 0 {
+			log.Errorf("---> ERROR: CreatingWebhookHandler: json decode: %v\n", errDecode)
+			http.Error(writer, ErrRequireFieldsMissing.Error(), http.StatusBadRequest)
+			return
+		}
+
+		webhook, errCreate := webhookService.Create(request.Context(), *userUUID, webhookData.URL)
+		if errCreate != nil {
+			switch {
+			case errors.Is(errCreate, webhookservice.ErrWrongURL),
+				errors.Is(errCreate, webhookservice.ErrPrivateAddress):
+				http.Error(writer, errCreate.Error(), http.StatusUnprocessableEntity)
+			case errors.Is(errCreate, webhookservice.ErrTooManyWebhooks):
+				http.Error(writer, errCreate.Error(), http.StatusConflict)
+			default:
+				log.Errorf("---> ERROR: CreatingWebhookHandler: create: %v", errCreate)
+				http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			}
+
+			return
+		}
+
+		route.sendJSON(writer, request, webhook, http.StatusCreated, "CreatingWebhookHandler")
+	}
+}
+
+func (route *urlRouter) GettingWebhooksHandler(webhookService service.WebhookServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: GettingWebhooksHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		webhooks, errGet := webhookService.GetWebhooks(request.Context(), *userUUID)
+		if errGet != nil {
+			log.Errorf("---> ERROR: GettingWebhooksHandler: getting webhooks: %v", errGet)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(webhooks) == 0 {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		route.sendJSON(writer, request, webhooks, http.StatusOK, "GettingWebhooksHandler")
+	}
+}
+
+func (route *urlRouter) DeletingWebhookHandler(webhookService service.WebhookServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: DeletingWebhookHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		webhookID, errParse := uuid.Parse(chi.URLParam(request, "id"))
+		if errParse != nil {
+			http.Error(writer, webhookservice.ErrWebhookNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		errDelete := webhookService.Delete(request.Context(), *userUUID, webhookID)
+		if errDelete != nil {
+			if errors.Is(errDelete, webhookservice.ErrWebhookNotFound) {
+				http.Error(writer, errDelete.Error(), http.StatusNotFound)
+				return
+			}
+
+			log.Errorf("---> ERROR: DeletingWebhookHandler: delete: %v", errDelete)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		writer.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// GettingWebhookDeliveriesHandler lists deliveries, `?status=DEAD` returns the dead letters.
+func (route *urlRouter) GettingWebhookDeliveriesHandler(webhookService service.WebhookServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: GettingWebhookDeliveriesHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		status := strings.ToUpper(request.URL.Query().Get("status"))
+
+		deliveries, errGet := webhookService.GetDeliveries(request.Context(), *userUUID, status)
+		if errGet != nil {
+			if errors.Is(errGet, webhookservice.ErrWrongDeliveryState) {
+				http.Error(writer, errGet.Error(), http.StatusBadRequest)
+				return
+			}
+
+			log.Errorf("---> ERROR: GettingWebhookDeliveriesHandler: getting deliveries: %v", errGet)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if len(deliveries) == 0 {
+			writer.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		route.sendJSON(writer, request, deliveries, http.StatusOK, "GettingWebhookDeliveriesHandler")
+	}
+}
+
+func (route *urlRouter) RedeliveringWebhookHandler(webhookService service.WebhookServiceInterface) http.HandlerFunc {
+	return func(writer http.ResponseWriter, request *http.Request) {
+		log := route.requestLogger(request)
+
+		userUUID, errUUID := route.getUserUUID(request)
+		if errUUID != nil {
+			log.Errorf("---> ERROR: RedeliveringWebhookHandler: getting user id from token: %v", errUUID)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		deliveryID, errParse := uuid.Parse(chi.URLParam(request, "id"))
+		if errParse != nil {
+			http.Error(writer, webhookservice.ErrDeliveryNotFound.Error(), http.StatusNotFound)
+			return
+		}
+
+		errRedeliver := webhookService.Redeliver(request.Context(), *userUUID, deliveryID)
+		if errRedeliver != nil {
+			if errors.Is(errRedeliver, webhookservice.ErrDeliveryNotFound) {
+				http.Error(writer, errRedeliver.Error(), http.StatusNotFound)
+				return
+			}
+
+			log.Errorf("---> ERROR: RedeliveringWebhookHandler: redeliver: %v", errRedeliver)
+			http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sendResponse(writer, []byte("ok"), http.StatusAccepted, log)
+	}
+}
+
+func (route *urlRouter) sendJSON(writer http.ResponseWriter, request *http.Request, value interface{}, statusCode int, handlerName string) {
+	log := route.requestLogger(request)
+
+	body, errEncode := json.Marshal(value)
+	if errEncode != nil {
+		log.Errorf("---> ERROR: %v: failed encode to json: %v", handlerName, errEncode)
+		http.Error(writer, ErrInternalServer.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+
+	sendResponse(writer, body, statusCode, log)
+}
@@ -0,0 +1,165 @@
+package accrualworker
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/client"
+	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/worker/batchrunner"
+)
+
+const (
+	// leaseDuration - how long a claimed order is hidden from other workers.
+	// If the process dies in the middle of processing, the order is polled again after this time.
+	leaseDuration = 30 * time.Second
+
+	processTimeout = 10 * time.Second
+)
+
+// AccrualWorker periodically polls the accrual system for all orders with a non-final status,
+// except the orders of withdrawals. An order is polled until the accrual system has answered MaxPollAttempts times.
+// The state of polling is kept in the orders table, so it survives restarts of the process.
+type AccrualWorker struct {
+	cfgMutex             sync.RWMutex
+	cfg                  config.AccrualConfig
+	runner               *batchrunner.Runner[models.Order]
+	accrualClient        client.AccrualClientInterface
+	orderRepository      repository.OrderRepositoryInterface
+	gettingPointsService service.GettingPointsServiceInterface
+	logger               logger.Logger
+}
+
+func New(
+	cfg *config.Config,
+	accrualClient client.AccrualClientInterface,
+	orderRepository repository.OrderRepositoryInterface,
+	gettingPointsService service.GettingPointsServiceInterface,
+	logger logger.Logger,
+) *AccrualWorker {
+	worker := &AccrualWorker{
+		cfg:                  cfg.Accrual,
+		accrualClient:        accrualClient,
+		orderRepository:      orderRepository,
+		gettingPointsService: gettingPointsService,
+		logger:               logger,
+	}
+
+	worker.runner = batchrunner.New("Accrual", worker.settings, worker.isNotPaused, worker.claim, worker.processOrder, logger)
+
+	return worker
+}
+
+func (worker *AccrualWorker) Start(ctx context.Context) {
+	worker.runner.Start(ctx)
+}
+
+// Stop stops claiming new orders and waits until the orders already taken are processed.
+func (worker *AccrualWorker) Stop() {
+	worker.runner.Stop()
+}
+
+// SetConfig changes the polling of the running worker, the batch being processed keeps the previous settings.
+func (worker *AccrualWorker) SetConfig(cfg config.AccrualConfig) {
+	worker.cfgMutex.Lock()
+	worker.cfg = cfg
+	worker.cfgMutex.Unlock()
+
+	worker.runner.SettingsChanged()
+}
+
+func (worker *AccrualWorker) config() config.AccrualConfig {
+	worker.cfgMutex.RLock()
+	defer worker.cfgMutex.RUnlock()
+
+	return worker.cfg
+}
+
+func (worker *AccrualWorker) settings() batchrunner.Settings {
+	cfg := worker.config()
+
+	return batchrunner.Settings{
+		Interval:  cfg.PollInterval,
+		BatchSize: cfg.PollBatchSize,
+		Workers:   cfg.PollWorkers,
+	}
+}
+
+// isNotPaused skips the polling while the accrual system asks to wait.
+func (worker *AccrualWorker) isNotPaused(_ context.Context) bool {
+	return !worker.accrualClient.PausedUntil().After(utils.GetCurrentDatetimeUTC())
+}
+
+func (worker *AccrualWorker) claim(ctx context.Context, batchSize int) ([]models.Order, error) {
+	leaseUntil := utils.GetCurrentDatetimeUTC().Add(leaseDuration)
+
+	orders, errClaim := worker.orderRepository.ClaimForPolling(ctx, batchSize, leaseUntil)
+	if errClaim != nil {
+		worker.logger.Errorf("---> ERROR: accrualWorker: failed claim orders: %v", errClaim)
+		return nil, errClaim
+	}
+
+	if len(orders) > 0 {
+		worker.logger.Infof("=== accrualWorker: orders for polling: %v", len(orders))
+	}
+
+	return orders, nil
+}
+
+// processOrder isn't bound to the context of the worker: an order which was taken
+// is processed up to the end even when the worker is stopping.
+// A failed order is rescheduled, so it never stops the batches.
+func (worker *AccrualWorker) processOrder(_ context.Context, order models.Order) error {
+	ctx, cancel := context.WithTimeout(context.Background(), processTimeout)
+	defer cancel()
+
+	isFinal, errProcess := worker.gettingPointsService.ProcessOrder(ctx, order)
+	if errProcess != nil {
+		worker.logger.Errorf("---> ERROR: accrualWorker: order %v: %v", order.Number, errProcess)
+	}
+
+	if isFinal {
+		return nil
+	}
+
+	// only the polls which the accrual system has answered are counted, a failed request says nothing about the order
+	isAnswered := errProcess == nil
+
+	// SchedulePolling below counts this answer
+	if isAnswered && order.AnsweredPolls+1 >= worker.config().MaxPollAttempts {
+		worker.logger.Warnf("=== accrualWorker: order %v has no final status after %v answers, it becomes INVALID",
+			order.Number, order.AnsweredPolls+1)
+
+		errAbandon := worker.gettingPointsService.AbandonOrder(ctx, order)
+		if errAbandon == nil {
+			return nil
+		}
+
+		worker.logger.Errorf("---> ERROR: accrualWorker: order %v: failed make it INVALID: %v", order.Number, errAbandon)
+	}
+
+	nextPollAt := utils.GetCurrentDatetimeUTC().Add(worker.backoff(order.PollAttempts))
+	if pausedUntil := worker.accrualClient.PausedUntil(); pausedUntil.After(nextPollAt) {
+		nextPollAt = pausedUntil
+	}
+
+	errSchedule := worker.orderRepository.SchedulePolling(ctx, order.ID, nextPollAt, isAnswered)
+	if errSchedule != nil {
+		worker.logger.Errorf("---> ERROR: accrualWorker: order %v: failed schedule polling: %v", order.Number, errSchedule)
+	}
+
+	return nil
+}
+
+// backoff doubles the poll interval for each unsuccessful attempt up to MaxPollBackoff.
+func (worker *AccrualWorker) backoff(attempts int) time.Duration {
+	cfg := worker.config()
+
+	return batchrunner.Backoff(cfg.PollInterval, cfg.MaxPollBackoff, attempts)
+}
@@ -0,0 +1,183 @@
+package batchrunner
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+)
+
+const maxBackoffDoubles = 16
+
+// Settings of the runner are read before every batch, so they may change while the runner works.
+type Settings struct {
+	Interval  time.Duration
+	BatchSize int
+	Workers   int
+}
+
+// Runner claims batches of items on every tick and processes the items of a batch in parallel.
+// The next batch is claimed at once while the batches are full, so a backlog is drained without waiting for ticks.
+type Runner[T any] struct {
+	name            string
+	settings        func() Settings
+	prepare         func(ctx context.Context) bool
+	claim           func(ctx context.Context, batchSize int) ([]T, error)
+	process         func(ctx context.Context, item T) error
+	settingsChanged chan struct{}
+	logger          logger.Logger
+	cancel          context.CancelFunc
+	wg              sync.WaitGroup
+}
+
+// New returns the runner:
+//   - prepare runs on every tick before the batches, the tick is skipped when it returns false; it may be nil;
+//   - claim takes up to batchSize items, it logs its own errors;
+//   - process handles one item; an error means that the item is left as it was and would be claimed again,
+//     so the runner waits for the next tick instead of claiming the next batch.
+func New[T any](
+	name string,
+	settings func() Settings,
+	prepare func(ctx context.Context) bool,
+	claim func(ctx context.Context, batchSize int) ([]T, error),
+	process func(ctx context.Context, item T) error,
+	logger logger.Logger,
+) *Runner[T] {
+	return &Runner[T]{
+		name:            name,
+		settings:        settings,
+		prepare:         prepare,
+		claim:           claim,
+		process:         process,
+		settingsChanged: make(chan struct{}, 1),
+		logger:          logger,
+	}
+}
+
+func (runner *Runner[T]) Start(ctx context.Context) {
+	ctxRunner, cancel := context.WithCancel(ctx)
+	runner.cancel = cancel
+
+	runner.wg.Add(1)
+
+	go func() {
+		defer runner.wg.Done()
+
+		runner.run(ctxRunner)
+	}()
+
+	runner.logger.Infof("=== %v worker started ===", runner.name)
+}
+
+// Stop stops claiming new items and waits until the items already taken are processed.
+func (runner *Runner[T]) Stop() {
+	if runner.cancel == nil {
+		return
+	}
+
+	runner.cancel()
+	runner.wg.Wait()
+
+	runner.logger.Infof("=== %v worker stopped ===", runner.name)
+}
+
+// SettingsChanged makes the running runner apply the new interval at once.
+func (runner *Runner[T]) SettingsChanged() {
+	select {
+	case runner.settingsChanged <- struct{}{}:
+	default:
+	}
+}
+
+func (runner *Runner[T]) run(ctx context.Context) {
+	ticker := time.NewTicker(runner.settings().Interval)
+	defer ticker.Stop()
+
+	for {
+		runner.tick(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-runner.settingsChanged:
+			ticker.Reset(runner.settings().Interval)
+		case <-ticker.C:
+		}
+	}
+}
+
+func (runner *Runner[T]) tick(ctx context.Context) {
+	if runner.prepare != nil && !runner.prepare(ctx) {
+		return
+	}
+
+	for ctx.Err() == nil {
+		settings := runner.settings()
+
+		numberOfItems, isFailed := runner.processBatch(ctx, settings)
+		if numberOfItems < settings.BatchSize || isFailed {
+			return
+		}
+	}
+}
+
+func (runner *Runner[T]) processBatch(ctx context.Context, settings Settings) (int, bool) {
+	items, errClaim := runner.claim(ctx, settings.BatchSize)
+	if errClaim != nil || len(items) == 0 {
+		return 0, errClaim != nil
+	}
+
+	itemsChannel := make(chan T)
+
+	var (
+		wg       sync.WaitGroup
+		mutex    sync.Mutex
+		isFailed bool
+	)
+
+	for i := 0; i < settings.Workers; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for item := range itemsChannel {
+				errProcess := runner.process(ctx, item)
+				if errProcess != nil {
+					mutex.Lock()
+					isFailed = true
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+
+	// the claimed items are handed out even when the runner is stopping, they have been taken already
+	for _, item := range items {
+		itemsChannel <- item
+	}
+
+	close(itemsChannel)
+
+	wg.Wait()
+
+	return len(items), isFailed
+}
+
+// Backoff doubles the interval for each failed attempt up to maxBackoff.
+func Backoff(interval time.Duration, maxBackoff time.Duration, attempts int) time.Duration {
+	switch {
+	case attempts < 0:
+		attempts = 0
+	case attempts > maxBackoffDoubles:
+		attempts = maxBackoffDoubles
+	}
+
+	delay := interval << attempts
+	if delay > maxBackoff || delay <= 0 {
+		return maxBackoff
+	}
+
+	return delay
+}
@@ -0,0 +1,109 @@
+package expirationworker
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/service"
+	"github.com/lexizz/cumloys/internal/worker/batchrunner"
+)
+
+const batchSize = 100
+
+// ExpirationWorker periodically writes off the points which weren't spent during the expiration period.
+// It runs only when the expiration is enabled by POINTS_EXPIRATION_MONTHS.
+type ExpirationWorker struct {
+	cfg                 config.ExpirationConfig
+	runner              *batchrunner.Runner[expiredUser]
+	pointLotRepository  repository.PointLotRepositoryInterface
+	expirePointsService service.ExpirePointsServiceInterface
+	logger              logger.Logger
+}
+
+// expiredUser - the user has lots which were earned before earnedBefore and haven't been spent
+type expiredUser struct {
+	userID       uuid.UUID
+	earnedBefore time.Time
+}
+
+func New(
+	cfg *config.Config,
+	pointLotRepository repository.PointLotRepositoryInterface,
+	expirePointsService service.ExpirePointsServiceInterface,
+	logger logger.Logger,
+) *ExpirationWorker {
+	worker := &ExpirationWorker{
+		cfg:                 cfg.Expiration,
+		pointLotRepository:  pointLotRepository,
+		expirePointsService: expirePointsService,
+		logger:              logger,
+	}
+
+	worker.runner = batchrunner.New("Expiration", worker.settings, nil, worker.claim, worker.expire, logger)
+
+	return worker
+}
+
+func (worker *ExpirationWorker) Start(ctx context.Context) {
+	if worker.cfg.Months <= 0 {
+		worker.logger.Info("=== Expiration worker is disabled: points never expire ===")
+		return
+	}
+
+	worker.runner.Start(ctx)
+}
+
+// Stop stops the worker and waits until the users being processed are done.
+func (worker *ExpirationWorker) Stop() {
+	worker.runner.Stop()
+}
+
+// settings - users are processed one by one, so the expiration doesn't compete with requests for the database.
+func (worker *ExpirationWorker) settings() batchrunner.Settings {
+	return batchrunner.Settings{
+		Interval:  worker.cfg.Interval,
+		BatchSize: batchSize,
+		Workers:   1,
+	}
+}
+
+func (worker *ExpirationWorker) claim(ctx context.Context, batchSize int) ([]expiredUser, error) {
+	earnedBefore := models.EarnedBeforeToExpire(utils.GetCurrentDatetimeUTC(), worker.cfg.Months)
+
+	userIDs, errUsers := worker.pointLotRepository.GetUserIDsWithExpired(ctx, earnedBefore, batchSize)
+	if errUsers != nil {
+		worker.logger.Errorf("---> ERROR: expirationWorker: failed get users with expired points: %v", errUsers)
+		return nil, errUsers
+	}
+
+	users := make([]expiredUser, 0, len(userIDs))
+	for _, userID := range userIDs {
+		users = append(users, expiredUser{userID: userID, earnedBefore: earnedBefore})
+	}
+
+	return users, nil
+}
+
+// expire writes off the expired points of the user. A user which fails keeps the lots and is retried on the next tick,
+// so the next batch isn't requested after a failure.
+func (worker *ExpirationWorker) expire(ctx context.Context, user expiredUser) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	expiredPoints, errExpire := worker.expirePointsService.ExpireByUserID(ctx, user.userID, user.earnedBefore)
+	if errExpire != nil {
+		return errExpire
+	}
+
+	worker.logger.Infof("=== expirationWorker: user %v: %v points expired", user.userID, expiredPoints)
+
+	return nil
+}
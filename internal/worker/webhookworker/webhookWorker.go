@@ -0,0 +1,157 @@
+package webhookworker
+
+import (
+	"context"
+	"time"
+
+	"github.com/lexizz/cumloys/internal/client"
+	"github.com/lexizz/cumloys/internal/config"
+	"github.com/lexizz/cumloys/internal/models"
+	"github.com/lexizz/cumloys/internal/pkg/logger"
+	"github.com/lexizz/cumloys/internal/pkg/metrics"
+	"github.com/lexizz/cumloys/internal/pkg/utils"
+	"github.com/lexizz/cumloys/internal/repository"
+	"github.com/lexizz/cumloys/internal/worker/batchrunner"
+)
+
+const (
+	batchSize       = 100
+	deliveryWorkers = 4
+
+	// leaseExtra - a claimed delivery is hidden from other dispatchers for the timeout of the request and this time
+	leaseExtra     = 30 * time.Second
+	maxErrorLength = 1024
+)
+
+// WebhookWorker drains the outbox: it creates deliveries of new events for the webhooks of their users
+// and sends pending deliveries, retrying failed ones with exponential backoff until MaxAttempts.
+type WebhookWorker struct {
+	cfg               config.WebhookConfig
+	runner            *batchrunner.Runner[models.WebhookDelivery]
+	outboxRepository  repository.OutboxRepositoryInterface
+	webhookRepository repository.WebhookRepositoryInterface
+	webhookClient     client.WebhookClientInterface
+	logger            logger.Logger
+}
+
+func New(
+	cfg *config.Config,
+	outboxRepository repository.OutboxRepositoryInterface,
+	webhookRepository repository.WebhookRepositoryInterface,
+	webhookClient client.WebhookClientInterface,
+	logger logger.Logger,
+) *WebhookWorker {
+	worker := &WebhookWorker{
+		cfg:               cfg.Webhook,
+		outboxRepository:  outboxRepository,
+		webhookRepository: webhookRepository,
+		webhookClient:     webhookClient,
+		logger:            logger,
+	}
+
+	worker.runner = batchrunner.New("Webhook", worker.settings, worker.fanOut, worker.claim, worker.deliver, logger)
+
+	return worker
+}
+
+func (worker *WebhookWorker) Start(ctx context.Context) {
+	worker.runner.Start(ctx)
+}
+
+// Stop stops draining the outbox and waits until the deliveries already taken are sent.
+func (worker *WebhookWorker) Stop() {
+	worker.runner.Stop()
+}
+
+func (worker *WebhookWorker) settings() batchrunner.Settings {
+	return batchrunner.Settings{
+		Interval:  worker.cfg.DispatchInterval,
+		BatchSize: batchSize,
+		Workers:   deliveryWorkers,
+	}
+}
+
+// fanOut creates the deliveries of new events before they are claimed.
+func (worker *WebhookWorker) fanOut(ctx context.Context) bool {
+	for ctx.Err() == nil {
+		numberOfEvents, errFanOut := worker.outboxRepository.FanOut(ctx, batchSize)
+		if errFanOut != nil {
+			worker.logger.Errorf("---> ERROR: webhookWorker: failed fan out events: %v", errFanOut)
+			break
+		}
+
+		if numberOfEvents < batchSize {
+			break
+		}
+	}
+
+	return true
+}
+
+func (worker *WebhookWorker) claim(ctx context.Context, batchSize int) ([]models.WebhookDelivery, error) {
+	leaseUntil := utils.GetCurrentDatetimeUTC().Add(worker.cfg.Timeout + leaseExtra)
+
+	deliveries, errClaim := worker.webhookRepository.ClaimDeliveries(ctx, batchSize, leaseUntil)
+	if errClaim != nil {
+		worker.logger.Errorf("---> ERROR: webhookWorker: failed claim deliveries: %v", errClaim)
+		return nil, errClaim
+	}
+
+	return deliveries, nil
+}
+
+// deliver isn't bound to the context of the worker: a delivery which was taken
+// is sent and saved even when the worker is stopping. A failed delivery is rescheduled, so it never stops the batches.
+func (worker *WebhookWorker) deliver(_ context.Context, delivery models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), worker.cfg.Timeout)
+	defer cancel()
+
+	statusCode, errSend := worker.webhookClient.Send(ctx, &delivery)
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	delivery.NextAttemptAt = utils.GetCurrentDatetimeUTC()
+
+	switch {
+	case errSend == nil:
+		delivery.Status = models.DeliveryStatusDelivered
+		delivery.LastError = ""
+
+		metrics.WebhookDeliveriesTotal.WithLabelValues("delivered").Inc()
+	case delivery.Attempts >= worker.cfg.MaxAttempts:
+		delivery.Status = models.DeliveryStatusDead
+		delivery.LastError = truncate(errSend.Error())
+
+		worker.logger.Errorf("---> ERROR: webhookWorker: delivery %v is dead after %v attempts: %v", delivery.ID, delivery.Attempts, errSend)
+		metrics.WebhookDeliveriesTotal.WithLabelValues("dead").Inc()
+	default:
+		delivery.LastError = truncate(errSend.Error())
+		delivery.NextAttemptAt = delivery.NextAttemptAt.Add(worker.backoff(delivery.Attempts))
+
+		worker.logger.Warnf("=== webhookWorker: delivery %v failed, attempt %v: %v", delivery.ID, delivery.Attempts, errSend)
+		metrics.WebhookDeliveriesTotal.WithLabelValues("failed").Inc()
+	}
+
+	ctxSave, cancelSave := context.WithTimeout(context.Background(), worker.cfg.Timeout)
+	defer cancelSave()
+
+	errUpdate := worker.webhookRepository.UpdateDelivery(ctxSave, &delivery)
+	if errUpdate != nil {
+		worker.logger.Errorf("---> ERROR: webhookWorker: delivery %v: failed save: %v", delivery.ID, errUpdate)
+	}
+
+	return nil
+}
+
+// backoff doubles the dispatch interval for each failed attempt up to MaxBackoff.
+func (worker *WebhookWorker) backoff(attempts int) time.Duration {
+	return batchrunner.Backoff(worker.cfg.DispatchInterval, worker.cfg.MaxBackoff, attempts-1)
+}
+
+func truncate(message string) string {
+	if len(message) > maxErrorLength {
+		return message[:maxErrorLength]
+	}
+
+	return message
+}